@@ -1,11 +1,21 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"log/slog"
 	"os"
+	"time"
 
 	"github.com/ritchie-gr8/7solution-be/internal/config"
 	databases "github.com/ritchie-gr8/7solution-be/internal/database"
+	"github.com/ritchie-gr8/7solution-be/internal/lifecycle"
+	"github.com/ritchie-gr8/7solution-be/internal/logging"
+	"github.com/ritchie-gr8/7solution-be/internal/migrations"
 	"github.com/ritchie-gr8/7solution-be/internal/servers"
+	"github.com/ritchie-gr8/7solution-be/internal/tracing"
+	"github.com/ritchie-gr8/7solution-be/internal/users"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 func envPath() string {
@@ -16,12 +26,61 @@ func envPath() string {
 	return os.Args[1]
 }
 
+// migrate applies the pending migrations before any request can see the
+// schema they change. An instance starting while another one migrates waits
+// for it to finish.
+func migrate(db *mongo.Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	migrator := migrations.NewMigrator(db, migrations.All())
+	for {
+		applied, err := migrator.Up(ctx)
+		if errors.Is(err, migrations.ErrLocked) && ctx.Err() == nil {
+			slog.Info("waiting for another instance to migrate")
+			time.Sleep(2 * time.Second)
+			continue
+		}
+		if err != nil {
+			logging.Fatal("migrate failed", "error", err)
+		}
+		slog.Info("migrations up to date", "applied", len(applied))
+		return
+	}
+}
+
 func main() {
 	cfg := config.LoadConfig(envPath())
+	logging.Setup(cfg.Log().Format(), cfg.Log().Level())
+
+	lc := lifecycle.NewManager(cfg.App().ShutdownTimeout())
 
-	db := databases.DbConnect(cfg.DB())
-	defer databases.DbDisconnect(db)
+	tp := tracing.Setup(cfg.App(), cfg.Tracing())
+	// DB_USER_STORE=memory runs without any database.
+	var db *mongo.Client
+	if cfg.DB().UserStore() == users.StorePostgres && cfg.DB().Host() == "" {
+		logging.Fatal("DB_USER_STORE=postgres only moves users, sessions, token revocations, login throttling and reset tokens still need MongoDB: set DB_HOST")
+	}
+	if cfg.DB().UserStore() != users.StoreMemory {
+		db = databases.DbConnect(cfg.DB())
+		if cfg.DB().MigrateOnStart() {
+			migrate(db)
+		}
+	}
 
-	server := servers.NewServer(cfg, db)
+	server := servers.NewServer(cfg, db, lc)
 	server.Start()
+
+	// The server's phases are registered first, so Mongo is only closed once
+	// requests and workers no longer use it.
+	lc.OnShutdown("mongo", func(ctx context.Context) error {
+		return databases.DbDisconnect(ctx, db)
+	})
+	lc.OnShutdown("tracing", func(ctx context.Context) error {
+		return tracing.Shutdown(ctx, tp)
+	})
+
+	if err := lc.Wait(); err != nil {
+		os.Exit(1)
+	}
 }
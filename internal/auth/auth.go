@@ -8,5 +8,6 @@ import (
 type IAuthenticator interface {
 	GenerateToken(claims jwt.MapClaims) (string, error)
 	ValidateToken(token string) (*jwt.Token, error)
-	GenerateClaims(id primitive.ObjectID) jwt.MapClaims
+	GenerateClaims(id primitive.ObjectID, roles []string) jwt.MapClaims
+	JWKS() *JWKSet
 }
@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ritchie-gr8/7solution-be/pkg/response"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("auth: invalid refresh token")
+	ErrRefreshTokenExpired = errors.New("auth: refresh token expired")
+	ErrRefreshTokenReused  = errors.New("auth: refresh token reused")
+	ErrGeneratingToken     = errors.New("auth: could not generate token")
+	ErrUnsupportedKey      = errors.New("auth: unsupported key type")
+	ErrInvalidPEM          = errors.New("auth: invalid PEM data")
+	ErrUnknownKeyID        = errors.New("auth: unknown key id")
+	ErrInvalidIssuedAt     = errors.New("auth: invalid issued at claim")
+)
+
+// ErrorCatalog is how the errors above are shown to clients. The codes are
+// part of the API; change a detail freely, but never a code.
+var ErrorCatalog = response.Catalog{
+	{Err: ErrInvalidRefreshToken, Status: fiber.StatusUnauthorized, Code: "invalid_refresh_token", Title: "Invalid Refresh Token", Detail: "Invalid refresh token."},
+	{Err: ErrRefreshTokenExpired, Status: fiber.StatusUnauthorized, Code: "refresh_token_expired", Title: "Refresh Token Expired", Detail: "Refresh token has expired."},
+	{Err: ErrRefreshTokenReused, Status: fiber.StatusUnauthorized, Code: "refresh_token_reused", Title: "Refresh Token Reused", Detail: "Refresh token has already been used. Please log in again."},
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ritchie-gr8/7solution-be/pkg/response"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type IAuthHandler interface {
+	Refresh(c *fiber.Ctx) error
+	Logout(c *fiber.Ctx) error
+	LogoutAll(c *fiber.Ctx) error
+	JWKS(c *fiber.Ctx) error
+}
+
+type authHandler struct {
+	service ITokenService
+	jwt     IAuthenticator
+}
+
+func NewAuthHandler(service ITokenService, jwt IAuthenticator) IAuthHandler {
+	return &authHandler{service: service, jwt: jwt}
+}
+
+func (ah *authHandler) Refresh(c *fiber.Ctx) error {
+	req := *c.Locals("body").(*RefreshTokenRequest)
+
+	tokens, err := ah.service.Refresh(c.UserContext(), req.RefreshToken)
+	if err != nil {
+		return err
+	}
+	return response.NewResponse(c).Success(fiber.StatusOK, tokens).Response()
+}
+
+func (ah *authHandler) Logout(c *fiber.Ctx) error {
+	var req LogoutRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return response.NewResponse(c).Error(fiber.StatusBadRequest, err.Error()).Response()
+		}
+	}
+
+	userId := c.Locals("userId").(string)
+	jti := c.Locals("tokenId").(string)
+	expiresAt := c.Locals("tokenExpiresAt").(time.Time)
+
+	if err := ah.service.Logout(c.UserContext(), userId, jti, expiresAt, req.RefreshToken); err != nil {
+		return err
+	}
+	return response.NewResponse(c).Success(fiber.StatusOK, "Logged out successfully").Response()
+}
+
+func (ah *authHandler) LogoutAll(c *fiber.Ctx) error {
+	userId := c.Locals("userId").(string)
+	objectID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return response.NewResponse(c).Error(fiber.StatusUnauthorized, "Invalid token claims").Response()
+	}
+
+	if err := ah.service.RevokeUserTokens(c.UserContext(), objectID); err != nil {
+		return err
+	}
+	return response.NewResponse(c).Success(fiber.StatusOK, "Logged out from all sessions successfully").Response()
+}
+
+func (ah *authHandler) JWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return response.NewResponse(c).Success(fiber.StatusOK, ah.jwt.JWKS()).Response()
+}
@@ -1,7 +1,9 @@
 package auth
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -9,15 +11,19 @@ import (
 )
 
 type JWTAuthenticator struct {
-	secret    string
+	keys      *KeySet
 	audience  string
 	issuer    string
 	expiresAt time.Duration
 }
 
 func NewJWTAuthenticator(secret, audience, issuer string, expiresAt time.Duration) *JWTAuthenticator {
+	return NewJWTAuthenticatorWithKeys(NewKeySet(NewHMACKey("", []byte(secret))), audience, issuer, expiresAt)
+}
+
+func NewJWTAuthenticatorWithKeys(keys *KeySet, audience, issuer string, expiresAt time.Duration) *JWTAuthenticator {
 	return &JWTAuthenticator{
-		secret:    secret,
+		keys:      keys,
 		audience:  audience,
 		issuer:    issuer,
 		expiresAt: expiresAt,
@@ -25,9 +31,13 @@ func NewJWTAuthenticator(secret, audience, issuer string, expiresAt time.Duratio
 }
 
 func (a *JWTAuthenticator) GenerateToken(claims jwt.MapClaims) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	key := a.keys.signing
+	token := jwt.NewWithClaims(key.Method, claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
 
-	tokenString, err := token.SignedString([]byte(a.secret))
+	tokenString, err := token.SignedString(key.Private)
 	if err != nil {
 		return "", err
 	}
@@ -35,27 +45,73 @@ func (a *JWTAuthenticator) GenerateToken(claims jwt.MapClaims) (string, error) {
 	return tokenString, nil
 }
 
+// ValidateToken picks the verification key by the kid header so tokens signed
+// with a key that is being rotated out stay valid until they expire. Tokens
+// without a kid are checked against the current signing key. The algorithm
+// has to match the key, which rules out alg confusion attacks such as an
+// RS256 public key being used as an HS256 secret.
 func (a *JWTAuthenticator) ValidateToken(token string) (*jwt.Token, error) {
 	return jwt.Parse(token, func(t *jwt.Token) (any, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+		key := a.keys.signing
+		if kid, ok := t.Header["kid"].(string); ok && kid != "" {
+			if key, ok = a.keys.verification[kid]; !ok {
+				return nil, ErrUnknownKeyID
+			}
+		}
+
+		if t.Method.Alg() != key.Method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
 		}
 
-		return []byte(a.secret), nil
+		return key.Public, nil
 	},
 		jwt.WithExpirationRequired(),
 		jwt.WithAudience(a.audience),
 		jwt.WithIssuer(a.issuer),
-		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}))
+		jwt.WithValidMethods(a.keys.methods()))
 }
 
-func (a *JWTAuthenticator) GenerateClaims(id primitive.ObjectID) jwt.MapClaims {
+// GenerateClaims sets iat with microsecond precision, so a token issued right
+// after a "logout all" or a password change in the same second is told apart
+// from the tokens the cutoff revoked.
+func (a *JWTAuthenticator) GenerateClaims(id primitive.ObjectID, roles []string) jwt.MapClaims {
+	roles = NormalizeRoles(roles)
+	now := time.Now()
 	return jwt.MapClaims{
-		"sub": id.Hex(),
-		"exp": time.Now().Add(a.expiresAt).Unix(),
-		"iat": time.Now().Unix(),
-		"nbf": time.Now().Unix(),
-		"iss": a.issuer,
-		"aud": a.audience,
+		"sub":         id.Hex(),
+		"jti":         primitive.NewObjectID().Hex(),
+		"roles":       roles,
+		"permissions": PermissionsForRoles(roles),
+		"exp":         now.Add(a.expiresAt).Unix(),
+		"iat":         float64(now.UnixMicro()) / 1e6,
+		"nbf":         now.Unix(),
+		"iss":         a.issuer,
+		"aud":         a.audience,
+	}
+}
+
+// IssuedAt reads iat without the truncation to whole seconds that
+// claims.GetIssuedAt applies.
+func IssuedAt(claims jwt.MapClaims) (time.Time, error) {
+	var seconds float64
+	switch iat := claims["iat"].(type) {
+	case float64:
+		seconds = iat
+	case json.Number:
+		f, err := iat.Float64()
+		if err != nil {
+			return time.Time{}, ErrInvalidIssuedAt
+		}
+		seconds = f
+	default:
+		return time.Time{}, ErrInvalidIssuedAt
 	}
+	if seconds <= 0 {
+		return time.Time{}, ErrInvalidIssuedAt
+	}
+	return time.UnixMicro(int64(math.Round(seconds * 1e6))), nil
+}
+
+func (a *JWTAuthenticator) JWKS() *JWKSet {
+	return a.keys.JWKS()
 }
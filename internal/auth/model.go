@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type RefreshToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	FamilyID  primitive.ObjectID `bson:"family_id"`
+	TokenHash string             `bson:"token_hash"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	UsedAt    *time.Time         `bson:"used_at,omitempty"`
+	RevokedAt *time.Time         `bson:"revoked_at,omitempty"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+type RevokedToken struct {
+	ID        string    `bson:"_id"`
+	RevokedAt time.Time `bson:"revoked_at"`
+	// RevokedAtMicros is RevokedAt in microseconds, the precision of iat.
+	// BSON dates only keep milliseconds. Records written before it was added
+	// leave it at 0.
+	RevokedAtMicros int64     `bson:"revoked_at_us,omitempty"`
+	ExpiresAt       time.Time `bson:"expires_at"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type TokenPair struct {
+	AccessToken           string    `json:"access_token"`
+	AccessTokenExpiresAt  time.Time `json:"access_token_expires_at"`
+	RefreshToken          string    `json:"refresh_token"`
+	RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at"`
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Permission string
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+	// RoleUnverified replaces the roles of a user whose email address is not
+	// verified yet, when the verification policy restricts such accounts.
+	RoleUnverified = "unverified"
+
+	// PermissionUsersRead allows reading user profiles.
+	PermissionUsersRead Permission = "users:read"
+	// PermissionUsersWrite allows updating and deleting the caller's own account.
+	PermissionUsersWrite Permission = "users:write"
+	// PermissionUsersManage allows updating and deleting any account.
+	PermissionUsersManage Permission = "users:manage"
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleUser:       {PermissionUsersRead, PermissionUsersWrite},
+	RoleAdmin:      {PermissionUsersRead, PermissionUsersWrite, PermissionUsersManage},
+	RoleUnverified: {PermissionUsersRead},
+}
+
+// RoleResolver looks up the current roles of a user so a refreshed token picks
+// up role changes instead of copying them from the previous token.
+type RoleResolver func(ctx context.Context, userId primitive.ObjectID) ([]string, error)
+
+// NormalizeRoles gives accounts created before roles existed the default role.
+func NormalizeRoles(roles []string) []string {
+	if len(roles) == 0 {
+		return []string{RoleUser}
+	}
+	return roles
+}
+
+// HasPermission reports whether permission is among the granted ones.
+func HasPermission(granted []Permission, permission Permission) bool {
+	return slices.Contains(granted, permission)
+}
+
+func PermissionsForRoles(roles []string) []Permission {
+	var permissions []Permission
+	for _, role := range NormalizeRoles(roles) {
+		for _, permission := range rolePermissions[role] {
+			if !slices.Contains(permissions, permission) {
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	return permissions
+}
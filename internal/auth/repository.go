@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	databases "github.com/ritchie-gr8/7solution-be/internal/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoCollection interface {
+	FindOne(ctx context.Context, filter any, opts ...*options.FindOneOptions) *mongo.SingleResult
+	InsertOne(ctx context.Context, document any, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error)
+	FindOneAndUpdate(ctx context.Context, filter any, update any, opts ...*options.FindOneAndUpdateOptions) *mongo.SingleResult
+	UpdateOne(ctx context.Context, filter any, update any, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+	UpdateMany(ctx context.Context, filter any, update any, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+}
+
+type IRefreshTokenRepository interface {
+	CreateRefreshToken(ctx context.Context, token *RefreshToken) error
+	GetRefreshTokenByHash(ctx context.Context, hash string) (*RefreshToken, error)
+	MarkRefreshTokenUsed(ctx context.Context, id primitive.ObjectID) error
+	RevokeRefreshTokenFamily(ctx context.Context, familyID primitive.ObjectID) error
+	RevokeUserRefreshTokens(ctx context.Context, userID primitive.ObjectID) error
+}
+
+type refreshTokenRepository struct {
+	collection MongoCollection
+}
+
+func NewRefreshTokenRepository(db *mongo.Client) IRefreshTokenRepository {
+	database := db.Database("userdb")
+	collection := database.Collection("refresh_tokens")
+	databases.EnsureIndexes(collection, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "family_id", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return &refreshTokenRepository{collection: collection}
+}
+
+func NewRefreshTokenRepositoryWithCollection(collection MongoCollection) IRefreshTokenRepository {
+	return &refreshTokenRepository{collection: collection}
+}
+
+func (r *refreshTokenRepository) CreateRefreshToken(ctx context.Context, token *RefreshToken) error {
+	result, err := r.collection.InsertOne(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	token.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *refreshTokenRepository) GetRefreshTokenByHash(ctx context.Context, hash string) (*RefreshToken, error) {
+	var token RefreshToken
+	err := r.collection.FindOne(ctx, bson.M{"token_hash": hash}).Decode(&token)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkRefreshTokenUsed flags the token as consumed. The filter only matches an
+// unused, unrevoked token so two concurrent refreshes cannot both succeed.
+func (r *refreshTokenRepository) MarkRefreshTokenUsed(ctx context.Context, id primitive.ObjectID) error {
+	var token RefreshToken
+	err := r.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": id, "used_at": nil, "revoked_at": nil},
+		bson.M{"$set": bson.M{"used_at": time.Now()}},
+	).Decode(&token)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrRefreshTokenReused
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *refreshTokenRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID primitive.ObjectID) error {
+	_, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{"family_id": familyID, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+	)
+	return err
+}
+
+func (r *refreshTokenRepository) RevokeUserRefreshTokens(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{"user_id": userID, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+	)
+	return err
+}
+
+type memoryRefreshTokenRepository struct {
+	mu     sync.Mutex
+	tokens map[string]*RefreshToken
+}
+
+// NewMemoryRefreshTokenRepository keeps refresh tokens in process, by hash.
+func NewMemoryRefreshTokenRepository() IRefreshTokenRepository {
+	return &memoryRefreshTokenRepository{tokens: make(map[string]*RefreshToken)}
+}
+
+func (r *memoryRefreshTokenRepository) CreateRefreshToken(ctx context.Context, token *RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for hash, stored := range r.tokens {
+		if !stored.ExpiresAt.After(now) {
+			delete(r.tokens, hash)
+		}
+	}
+
+	token.ID = primitive.NewObjectID()
+	stored := *token
+	r.tokens[token.TokenHash] = &stored
+	return nil
+}
+
+func (r *memoryRefreshTokenRepository) GetRefreshTokenByHash(ctx context.Context, hash string) (*RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.tokens[hash]
+	if !ok {
+		return nil, ErrInvalidRefreshToken
+	}
+	found := *token
+	return &found, nil
+}
+
+// MarkRefreshTokenUsed flags the token as consumed. Only an unused, unrevoked
+// token matches, so two concurrent refreshes cannot both succeed.
+func (r *memoryRefreshTokenRepository) MarkRefreshTokenUsed(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, token := range r.tokens {
+		if token.ID != id {
+			continue
+		}
+		if token.UsedAt != nil || token.RevokedAt != nil {
+			return ErrRefreshTokenReused
+		}
+		now := time.Now()
+		token.UsedAt = &now
+		return nil
+	}
+	return ErrRefreshTokenReused
+}
+
+func (r *memoryRefreshTokenRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID primitive.ObjectID) error {
+	r.revoke(func(token *RefreshToken) bool { return token.FamilyID == familyID })
+	return nil
+}
+
+func (r *memoryRefreshTokenRepository) RevokeUserRefreshTokens(ctx context.Context, userID primitive.ObjectID) error {
+	r.revoke(func(token *RefreshToken) bool { return token.UserID == userID })
+	return nil
+}
+
+func (r *memoryRefreshTokenRepository) revoke(match func(token *RefreshToken) bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, token := range r.tokens {
+		if token.RevokedAt == nil && match(token) {
+			token.RevokedAt = &now
+		}
+	}
+}
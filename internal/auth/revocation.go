@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	databases "github.com/ritchie-gr8/7solution-be/internal/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	revocationCacheSweepSize = 10000
+	tokenRevocationPrefix    = "jti:"
+	userRevocationPrefix     = "user:"
+)
+
+type IRevocationStore interface {
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	RevokeUserTokens(ctx context.Context, userId string) error
+	IsRevoked(ctx context.Context, jti, userId string, issuedAt time.Time) (bool, error)
+}
+
+type revocationCacheEntry struct {
+	revokedAt time.Time
+	until     time.Time
+}
+
+// revocationStore keeps revocations in Mongo so they hold across instances and
+// caches lookups in process so ValidateToken does not hit the database on
+// every request. Revocations made by another instance become visible once the
+// cached "not revoked" answer expires after cacheTTL.
+type revocationStore struct {
+	collection      MongoCollection
+	accessExpiresAt time.Duration
+	cacheTTL        time.Duration
+
+	mu    sync.RWMutex
+	cache map[string]revocationCacheEntry
+}
+
+func NewRevocationStore(db *mongo.Client, accessExpiresAt, cacheTTL time.Duration) IRevocationStore {
+	database := db.Database("userdb")
+	collection := database.Collection("revoked_tokens")
+	databases.EnsureIndexes(collection, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return NewRevocationStoreWithCollection(collection, accessExpiresAt, cacheTTL)
+}
+
+func NewRevocationStoreWithCollection(collection MongoCollection, accessExpiresAt, cacheTTL time.Duration) IRevocationStore {
+	return &revocationStore{
+		collection:      collection,
+		accessExpiresAt: accessExpiresAt,
+		cacheTTL:        cacheTTL,
+		cache:           make(map[string]revocationCacheEntry),
+	}
+}
+
+// RevokeToken rejects a single access token. The record only needs to live
+// until the token would have expired anyway.
+func (s *revocationStore) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	now := time.Now()
+	if err := s.upsert(ctx, tokenRevocationKey(jti), now, expiresAt); err != nil {
+		return err
+	}
+
+	s.store(tokenRevocationKey(jti), revocationCacheEntry{revokedAt: now, until: expiresAt})
+	return nil
+}
+
+// RevokeUserTokens rejects every access token of the user issued before now,
+// to the microsecond, so a token issued right after in the same second, e.g.
+// by a password change, stays valid.
+func (s *revocationStore) RevokeUserTokens(ctx context.Context, userId string) error {
+	now := time.Now().Truncate(time.Microsecond)
+	if err := s.upsert(ctx, userRevocationKey(userId), now, now.Add(s.accessExpiresAt)); err != nil {
+		return err
+	}
+
+	s.store(userRevocationKey(userId), revocationCacheEntry{revokedAt: now, until: now.Add(s.cacheTTL)})
+	return nil
+}
+
+func (s *revocationStore) IsRevoked(ctx context.Context, jti, userId string, issuedAt time.Time) (bool, error) {
+	revokedAt, err := s.lookup(ctx, tokenRevocationKey(jti))
+	if err != nil {
+		return false, err
+	}
+	if !revokedAt.IsZero() {
+		return true, nil
+	}
+
+	cutoff, err := s.lookup(ctx, userRevocationKey(userId))
+	if err != nil {
+		return false, err
+	}
+	return issuedAt.Before(cutoff), nil
+}
+
+func (s *revocationStore) upsert(ctx context.Context, key string, revokedAt, expiresAt time.Time) error {
+	_, err := s.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": key},
+		bson.M{"$set": bson.M{"revoked_at": revokedAt, "revoked_at_us": revokedAt.UnixMicro(), "expires_at": expiresAt}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (s *revocationStore) lookup(ctx context.Context, key string) (time.Time, error) {
+	now := time.Now()
+
+	s.mu.RLock()
+	entry, ok := s.cache[key]
+	s.mu.RUnlock()
+	if ok && now.Before(entry.until) {
+		return entry.revokedAt, nil
+	}
+
+	var revoked RevokedToken
+	err := s.collection.FindOne(ctx, bson.M{"_id": key}).Decode(&revoked)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			s.store(key, revocationCacheEntry{until: now.Add(s.cacheTTL)})
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+
+	revokedAt := revoked.RevokedAt
+	if revoked.RevokedAtMicros != 0 {
+		revokedAt = time.UnixMicro(revoked.RevokedAtMicros)
+	}
+
+	until := revoked.ExpiresAt
+	if strings.HasPrefix(key, userRevocationPrefix) {
+		// A user cutoff can move forward on another instance, so it is only
+		// cached as long as a negative answer would be.
+		until = now.Add(s.cacheTTL)
+	}
+	s.store(key, revocationCacheEntry{revokedAt: revokedAt, until: until})
+	return revokedAt, nil
+}
+
+func (s *revocationStore) store(key string, entry revocationCacheEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.cache) >= revocationCacheSweepSize {
+		now := time.Now()
+		for k, e := range s.cache {
+			if now.After(e.until) {
+				delete(s.cache, k)
+			}
+		}
+	}
+	s.cache[key] = entry
+}
+
+type memoryRevocationStore struct {
+	accessExpiresAt time.Duration
+
+	mu      sync.Mutex
+	revoked map[string]RevokedToken
+}
+
+// NewMemoryRevocationStore keeps revocations in process. They only hold on
+// the instance they were made on.
+func NewMemoryRevocationStore(accessExpiresAt time.Duration) IRevocationStore {
+	return &memoryRevocationStore{accessExpiresAt: accessExpiresAt, revoked: make(map[string]RevokedToken)}
+}
+
+func (s *memoryRevocationStore) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	s.put(tokenRevocationKey(jti), time.Now(), expiresAt)
+	return nil
+}
+
+// RevokeUserTokens rejects every access token of the user issued before now,
+// to the microsecond like the MongoDB store.
+func (s *memoryRevocationStore) RevokeUserTokens(ctx context.Context, userId string) error {
+	now := time.Now().Truncate(time.Microsecond)
+	s.put(userRevocationKey(userId), now, now.Add(s.accessExpiresAt))
+	return nil
+}
+
+func (s *memoryRevocationStore) IsRevoked(ctx context.Context, jti, userId string, issuedAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if revoked, ok := s.revoked[tokenRevocationKey(jti)]; ok && revoked.ExpiresAt.After(now) {
+		return true, nil
+	}
+	if cutoff, ok := s.revoked[userRevocationKey(userId)]; ok && cutoff.ExpiresAt.After(now) {
+		return issuedAt.Before(cutoff.RevokedAt), nil
+	}
+	return false, nil
+}
+
+func (s *memoryRevocationStore) put(key string, revokedAt, expiresAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.revoked) >= revocationCacheSweepSize {
+		for k, revoked := range s.revoked {
+			if !revoked.ExpiresAt.After(revokedAt) {
+				delete(s.revoked, k)
+			}
+		}
+	}
+	s.revoked[key] = RevokedToken{ID: key, RevokedAt: revokedAt, ExpiresAt: expiresAt}
+}
+
+func tokenRevocationKey(jti string) string {
+	return tokenRevocationPrefix + jti
+}
+
+func userRevocationKey(userId string) string {
+	return userRevocationPrefix + userId
+}
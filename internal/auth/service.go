@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ITokenService interface {
+	IssueTokens(ctx context.Context, userId primitive.ObjectID, roles []string) (*TokenPair, error)
+	Refresh(ctx context.Context, refreshToken string) (*TokenPair, error)
+	Logout(ctx context.Context, userId, jti string, expiresAt time.Time, refreshToken string) error
+	RevokeUserTokens(ctx context.Context, userId primitive.ObjectID) error
+}
+
+type tokenService struct {
+	jwt              IAuthenticator
+	repo             IRefreshTokenRepository
+	revocations      IRevocationStore
+	roles            RoleResolver
+	refreshExpiresAt time.Duration
+}
+
+func NewTokenService(jwt IAuthenticator, repo IRefreshTokenRepository, revocations IRevocationStore, roles RoleResolver, refreshExpiresAt time.Duration) ITokenService {
+	return &tokenService{
+		jwt:              jwt,
+		repo:             repo,
+		revocations:      revocations,
+		roles:            roles,
+		refreshExpiresAt: refreshExpiresAt,
+	}
+}
+
+// IssueTokens starts a new refresh token family, e.g. after a password login.
+func (s *tokenService) IssueTokens(ctx context.Context, userId primitive.ObjectID, roles []string) (*TokenPair, error) {
+	return s.issue(ctx, userId, primitive.NewObjectID(), roles)
+}
+
+// Refresh exchanges a refresh token for a new token pair. Every refresh token
+// can be used once; presenting an already used token is treated as theft and
+// revokes the whole family so neither the attacker nor the victim can continue.
+func (s *tokenService) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	stored, err := s.repo.GetRefreshTokenByHash(ctx, HashToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+
+	if stored.RevokedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if stored.UsedAt != nil {
+		return nil, s.revokeFamily(ctx, stored.FamilyID)
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, ErrRefreshTokenExpired
+	}
+
+	if err := s.repo.MarkRefreshTokenUsed(ctx, stored.ID); err != nil {
+		if errors.Is(err, ErrRefreshTokenReused) {
+			return nil, s.revokeFamily(ctx, stored.FamilyID)
+		}
+		return nil, err
+	}
+
+	roles, err := s.roles(ctx, stored.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issue(ctx, stored.UserID, stored.FamilyID, roles)
+}
+
+// Logout revokes the access token identified by jti and, when given, the
+// refresh token family it was issued with. A refresh token that belongs to
+// someone else is ignored rather than revoked.
+func (s *tokenService) Logout(ctx context.Context, userId, jti string, expiresAt time.Time, refreshToken string) error {
+	if err := s.revocations.RevokeToken(ctx, jti, expiresAt); err != nil {
+		return err
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+
+	stored, err := s.repo.GetRefreshTokenByHash(ctx, HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, ErrInvalidRefreshToken) {
+			return nil
+		}
+		return err
+	}
+
+	if stored.UserID.Hex() != userId {
+		return nil
+	}
+	return s.repo.RevokeRefreshTokenFamily(ctx, stored.FamilyID)
+}
+
+// RevokeUserTokens logs the user out everywhere: access tokens issued so far
+// are rejected and every refresh token stops working.
+func (s *tokenService) RevokeUserTokens(ctx context.Context, userId primitive.ObjectID) error {
+	if err := s.revocations.RevokeUserTokens(ctx, userId.Hex()); err != nil {
+		return err
+	}
+	return s.repo.RevokeUserRefreshTokens(ctx, userId)
+}
+
+func (s *tokenService) issue(ctx context.Context, userId, familyId primitive.ObjectID, roles []string) (*TokenPair, error) {
+	claims := s.jwt.GenerateClaims(userId, roles)
+
+	accessToken, err := s.jwt.GenerateToken(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := GenerateOpaqueToken()
+	if err != nil {
+		return nil, ErrGeneratingToken
+	}
+
+	now := time.Now()
+	stored := &RefreshToken{
+		UserID:    userId,
+		FamilyID:  familyId,
+		TokenHash: HashToken(refreshToken),
+		ExpiresAt: now.Add(s.refreshExpiresAt),
+		CreatedAt: now,
+	}
+	if err := s.repo.CreateRefreshToken(ctx, stored); err != nil {
+		return nil, err
+	}
+
+	exp, _ := claims["exp"].(int64)
+	return &TokenPair{
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  time.Unix(exp, 0),
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: stored.ExpiresAt,
+	}, nil
+}
+
+func (s *tokenService) revokeFamily(ctx context.Context, familyId primitive.ObjectID) error {
+	if err := s.repo.RevokeRefreshTokenFamily(ctx, familyId); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// GenerateOpaqueToken returns 32 random bytes encoded as base64url, for
+// tokens that are looked up server side rather than verified by signature.
+func GenerateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken is what gets persisted so a database leak does not expose usable
+// opaque tokens. The tokens are high entropy, so a plain SHA-256 is enough.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,149 @@
+package test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ritchie-gr8/7solution-be/internal/auth"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func writePrivateKey(t *testing.T, key any) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "key.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	return path
+}
+
+func generateKeys(t *testing.T) map[string]any {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate EC key: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+
+	return map[string]any{
+		"RS256": rsaKey,
+		"ES256": ecKey,
+		"EdDSA": edKey,
+	}
+}
+
+func TestAsymmetricSigning(t *testing.T) {
+	for alg, key := range generateKeys(t) {
+		t.Run(alg, func(t *testing.T) {
+			signing, err := auth.LoadSigningKey("key-1", writePrivateKey(t, key))
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			if signing.Method.Alg() != alg {
+				t.Errorf("Expected method %s, got %s", alg, signing.Method.Alg())
+			}
+
+			jwtAuth := auth.NewJWTAuthenticatorWithKeys(auth.NewKeySet(signing), "test", "test", time.Minute)
+			token, err := jwtAuth.GenerateToken(jwtAuth.GenerateClaims(primitive.NewObjectID(), nil))
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			parsed, err := jwtAuth.ValidateToken(token)
+			if err != nil {
+				t.Fatalf("Expected token to validate, got: %v", err)
+			}
+
+			if parsed.Header["kid"] != "key-1" {
+				t.Errorf("Expected kid key-1, got %v", parsed.Header["kid"])
+			}
+
+			jwks := jwtAuth.JWKS()
+			if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != "key-1" || jwks.Keys[0].Alg != alg {
+				t.Errorf("Expected a single %s JWK with kid key-1, got %+v", alg, jwks.Keys)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	keys := generateKeys(t)
+	oldKeyPath := writePrivateKey(t, keys["RS256"])
+	newKeyPath := writePrivateKey(t, keys["EdDSA"])
+
+	oldSigning, _ := auth.LoadSigningKey("old", oldKeyPath)
+	oldAuth := auth.NewJWTAuthenticatorWithKeys(auth.NewKeySet(oldSigning), "test", "test", time.Minute)
+	oldToken, _ := oldAuth.GenerateToken(oldAuth.GenerateClaims(primitive.NewObjectID(), nil))
+
+	newSigning, _ := auth.LoadSigningKey("new", newKeyPath)
+	oldVerification, err := auth.LoadVerificationKey("old", oldKeyPath)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	rotated := auth.NewJWTAuthenticatorWithKeys(auth.NewKeySet(newSigning, oldVerification), "test", "test", time.Minute)
+
+	if _, err := rotated.ValidateToken(oldToken); err != nil {
+		t.Errorf("Expected token signed with previous key to validate, got: %v", err)
+	}
+
+	if len(rotated.JWKS().Keys) != 2 {
+		t.Errorf("Expected both keys in JWKS, got %d", len(rotated.JWKS().Keys))
+	}
+
+	retired, _ := auth.LoadSigningKey("retired", newKeyPath)
+	unknown := auth.NewJWTAuthenticatorWithKeys(auth.NewKeySet(retired), "test", "test", time.Minute)
+	newToken, _ := rotated.GenerateToken(rotated.GenerateClaims(primitive.NewObjectID(), nil))
+	if _, err := unknown.ValidateToken(newToken); !errors.Is(err, auth.ErrUnknownKeyID) {
+		t.Errorf("Expected auth.ErrUnknownKeyID, got: %v", err)
+	}
+}
+
+func TestRejectsAlgorithmConfusion(t *testing.T) {
+	signing, _ := auth.LoadSigningKey("key-1", writePrivateKey(t, generateKeys(t)["RS256"]))
+	jwtAuth := auth.NewJWTAuthenticatorWithKeys(auth.NewKeySet(signing), "test", "test", time.Minute)
+
+	der, _ := x509.MarshalPKIXPublicKey(signing.Public)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwtAuth.GenerateClaims(primitive.NewObjectID(), nil))
+	forged.Header["kid"] = "key-1"
+	token, err := forged.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil {
+		t.Fatalf("Failed to sign forged token: %v", err)
+	}
+
+	if _, err := jwtAuth.ValidateToken(token); err == nil {
+		t.Error("Expected HS256 token to be rejected by RS256 key")
+	}
+
+	hmacAuth := auth.NewJWTAuthenticator("secret", "test", "test", time.Minute)
+	if len(hmacAuth.JWKS().Keys) != 0 {
+		t.Error("Expected symmetric keys to be left out of JWKS")
+	}
+}
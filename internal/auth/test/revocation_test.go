@@ -0,0 +1,107 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ritchie-gr8/7solution-be/internal/auth"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// fakeRevocations keeps the documents the revocation store upserts, with
+// dates cut to milliseconds like BSON does.
+type fakeRevocations struct {
+	docs map[string]bson.M
+}
+
+func (f *fakeRevocations) FindOne(ctx context.Context, filter any, opts ...*options.FindOneOptions) *mongo.SingleResult {
+	doc, ok := f.docs[filter.(bson.M)["_id"].(string)]
+	if !ok {
+		return mongo.NewSingleResultFromDocument(bson.M{}, mongo.ErrNoDocuments, nil)
+	}
+	return mongo.NewSingleResultFromDocument(doc, nil, nil)
+}
+
+func (f *fakeRevocations) UpdateOne(ctx context.Context, filter any, update any, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	id := filter.(bson.M)["_id"].(string)
+	doc := bson.M{"_id": id}
+	for key, value := range update.(bson.M)["$set"].(bson.M) {
+		if t, ok := value.(time.Time); ok {
+			value = primitive.NewDateTimeFromTime(t)
+		}
+		doc[key] = value
+	}
+	f.docs[id] = doc
+	return &mongo.UpdateResult{UpsertedCount: 1}, nil
+}
+
+func (f *fakeRevocations) InsertOne(ctx context.Context, document any, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	return nil, nil
+}
+
+func (f *fakeRevocations) FindOneAndUpdate(ctx context.Context, filter any, update any, opts ...*options.FindOneAndUpdateOptions) *mongo.SingleResult {
+	return nil
+}
+
+func (f *fakeRevocations) UpdateMany(ctx context.Context, filter any, update any, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return nil, nil
+}
+
+func TestRevokeUserTokensInSameSecond(t *testing.T) {
+	jwtAuth := auth.NewJWTAuthenticator("secret", "test", "test", time.Minute)
+	userId := primitive.NewObjectID()
+
+	// issue signs a token and reads iat back the way ValidateToken does.
+	issue := func(t *testing.T) time.Time {
+		t.Helper()
+		token, err := jwtAuth.GenerateToken(jwtAuth.GenerateClaims(userId, nil))
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		parsed, err := jwtAuth.ValidateToken(token)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		issuedAt, err := auth.IssuedAt(parsed.Claims.(jwt.MapClaims))
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		return issuedAt
+	}
+
+	for name, revocations := range map[string]auth.IRevocationStore{
+		"memory": auth.NewMemoryRevocationStore(time.Minute),
+		// Without a cache every lookup reads the stored cutoff.
+		"mongo": auth.NewRevocationStoreWithCollection(&fakeRevocations{docs: make(map[string]bson.M)}, time.Minute, 0),
+	} {
+		t.Run(name, func(t *testing.T) {
+			// Start at the beginning of a second, so all of it happens
+			// within the same one.
+			time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+			second := time.Now().Unix()
+
+			before := issue(t)
+			time.Sleep(time.Millisecond)
+			if err := revocations.RevokeUserTokens(context.Background(), userId.Hex()); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			time.Sleep(time.Millisecond)
+			after := issue(t)
+
+			if before.Unix() != second || after.Unix() != second {
+				t.Fatalf("Expected both tokens to be issued in second %d, got: %v and %v", second, before, after)
+			}
+			if revoked, err := revocations.IsRevoked(context.Background(), "before", userId.Hex(), before); err != nil || !revoked {
+				t.Errorf("Expected a token issued before the cutoff in the same second to be revoked, got: %v, %v", revoked, err)
+			}
+			if revoked, err := revocations.IsRevoked(context.Background(), "after", userId.Hex(), after); err != nil || revoked {
+				t.Errorf("Expected a token issued after the cutoff to be valid, got: %v, %v", revoked, err)
+			}
+		})
+	}
+}
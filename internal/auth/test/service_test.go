@@ -0,0 +1,250 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ritchie-gr8/7solution-be/internal/auth"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MockRefreshTokenRepository struct {
+	tokens []*auth.RefreshToken
+}
+
+func (m *MockRefreshTokenRepository) CreateRefreshToken(ctx context.Context, token *auth.RefreshToken) error {
+	token.ID = primitive.NewObjectID()
+	m.tokens = append(m.tokens, token)
+	return nil
+}
+
+func (m *MockRefreshTokenRepository) GetRefreshTokenByHash(ctx context.Context, hash string) (*auth.RefreshToken, error) {
+	for _, token := range m.tokens {
+		if token.TokenHash == hash {
+			copied := *token
+			return &copied, nil
+		}
+	}
+	return nil, auth.ErrInvalidRefreshToken
+}
+
+func (m *MockRefreshTokenRepository) MarkRefreshTokenUsed(ctx context.Context, id primitive.ObjectID) error {
+	for _, token := range m.tokens {
+		if token.ID == id {
+			if token.UsedAt != nil || token.RevokedAt != nil {
+				return auth.ErrRefreshTokenReused
+			}
+			now := time.Now()
+			token.UsedAt = &now
+			return nil
+		}
+	}
+	return auth.ErrRefreshTokenReused
+}
+
+func (m *MockRefreshTokenRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID primitive.ObjectID) error {
+	for _, token := range m.tokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			now := time.Now()
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (m *MockRefreshTokenRepository) RevokeUserRefreshTokens(ctx context.Context, userID primitive.ObjectID) error {
+	for _, token := range m.tokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			now := time.Now()
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+type MockRevocationStore struct {
+	revokedTokens map[string]time.Time
+	revokedUsers  map[string]time.Time
+}
+
+func NewMockRevocationStore() *MockRevocationStore {
+	return &MockRevocationStore{
+		revokedTokens: make(map[string]time.Time),
+		revokedUsers:  make(map[string]time.Time),
+	}
+}
+
+func (m *MockRevocationStore) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	m.revokedTokens[jti] = expiresAt
+	return nil
+}
+
+func (m *MockRevocationStore) RevokeUserTokens(ctx context.Context, userId string) error {
+	m.revokedUsers[userId] = time.Now()
+	return nil
+}
+
+func (m *MockRevocationStore) IsRevoked(ctx context.Context, jti, userId string, issuedAt time.Time) (bool, error) {
+	if _, ok := m.revokedTokens[jti]; ok {
+		return true, nil
+	}
+	cutoff, ok := m.revokedUsers[userId]
+	return ok && issuedAt.Before(cutoff), nil
+}
+
+func newTokenService(repo auth.IRefreshTokenRepository) auth.ITokenService {
+	return newTokenServiceWithRevocations(repo, NewMockRevocationStore())
+}
+
+func newTokenServiceWithRevocations(repo auth.IRefreshTokenRepository, revocations auth.IRevocationStore) auth.ITokenService {
+	jwtAuth := auth.NewJWTAuthenticator("secret", "test", "test", time.Minute)
+	roles := func(ctx context.Context, userId primitive.ObjectID) ([]string, error) {
+		return []string{auth.RoleUser}, nil
+	}
+	return auth.NewTokenService(jwtAuth, repo, revocations, roles, time.Hour)
+}
+
+func TestRefresh(t *testing.T) {
+	t.Run("Rotates refresh token", func(t *testing.T) {
+		repo := &MockRefreshTokenRepository{}
+		svc := newTokenService(repo)
+		ctx := context.Background()
+
+		issued, err := svc.IssueTokens(ctx, primitive.NewObjectID(), nil)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		if issued.AccessToken == "" || issued.RefreshToken == "" {
+			t.Fatal("Expected access and refresh tokens to be set")
+		}
+
+		refreshed, err := svc.Refresh(ctx, issued.RefreshToken)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		if refreshed.RefreshToken == issued.RefreshToken {
+			t.Error("Expected a new refresh token")
+		}
+
+		if repo.tokens[0].FamilyID != repo.tokens[1].FamilyID {
+			t.Error("Expected rotated token to stay in the same family")
+		}
+	})
+
+	t.Run("Reuse revokes the family", func(t *testing.T) {
+		repo := &MockRefreshTokenRepository{}
+		svc := newTokenService(repo)
+		ctx := context.Background()
+
+		issued, _ := svc.IssueTokens(ctx, primitive.NewObjectID(), nil)
+		refreshed, err := svc.Refresh(ctx, issued.RefreshToken)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		_, err = svc.Refresh(ctx, issued.RefreshToken)
+		if !errors.Is(err, auth.ErrRefreshTokenReused) {
+			t.Errorf("Expected auth.ErrRefreshTokenReused, got: %v", err)
+		}
+
+		_, err = svc.Refresh(ctx, refreshed.RefreshToken)
+		if !errors.Is(err, auth.ErrInvalidRefreshToken) {
+			t.Errorf("Expected auth.ErrInvalidRefreshToken for revoked family, got: %v", err)
+		}
+	})
+
+	t.Run("Expired token", func(t *testing.T) {
+		repo := &MockRefreshTokenRepository{}
+		svc := newTokenService(repo)
+		ctx := context.Background()
+
+		issued, _ := svc.IssueTokens(ctx, primitive.NewObjectID(), nil)
+		repo.tokens[0].ExpiresAt = time.Now().Add(-time.Minute)
+
+		_, err := svc.Refresh(ctx, issued.RefreshToken)
+		if !errors.Is(err, auth.ErrRefreshTokenExpired) {
+			t.Errorf("Expected auth.ErrRefreshTokenExpired, got: %v", err)
+		}
+	})
+
+	t.Run("Unknown token", func(t *testing.T) {
+		svc := newTokenService(&MockRefreshTokenRepository{})
+
+		_, err := svc.Refresh(context.Background(), "does-not-exist")
+		if !errors.Is(err, auth.ErrInvalidRefreshToken) {
+			t.Errorf("Expected auth.ErrInvalidRefreshToken, got: %v", err)
+		}
+	})
+}
+
+func TestLogout(t *testing.T) {
+	t.Run("Revokes access token and refresh family", func(t *testing.T) {
+		repo := &MockRefreshTokenRepository{}
+		revocations := NewMockRevocationStore()
+		svc := newTokenServiceWithRevocations(repo, revocations)
+		ctx := context.Background()
+		userId := primitive.NewObjectID()
+
+		issued, _ := svc.IssueTokens(ctx, userId, nil)
+
+		err := svc.Logout(ctx, userId.Hex(), "token-id", issued.AccessTokenExpiresAt, issued.RefreshToken)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		if _, ok := revocations.revokedTokens["token-id"]; !ok {
+			t.Error("Expected access token to be revoked")
+		}
+
+		_, err = svc.Refresh(ctx, issued.RefreshToken)
+		if !errors.Is(err, auth.ErrInvalidRefreshToken) {
+			t.Errorf("Expected auth.ErrInvalidRefreshToken, got: %v", err)
+		}
+	})
+
+	t.Run("Ignores refresh token of another user", func(t *testing.T) {
+		repo := &MockRefreshTokenRepository{}
+		svc := newTokenService(repo)
+		ctx := context.Background()
+
+		victim, _ := svc.IssueTokens(ctx, primitive.NewObjectID(), nil)
+
+		err := svc.Logout(ctx, primitive.NewObjectID().Hex(), "token-id", time.Now().Add(time.Minute), victim.RefreshToken)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		if repo.tokens[0].RevokedAt != nil {
+			t.Error("Expected refresh token of another user to stay valid")
+		}
+	})
+
+	t.Run("Logout everywhere", func(t *testing.T) {
+		repo := &MockRefreshTokenRepository{}
+		revocations := NewMockRevocationStore()
+		svc := newTokenServiceWithRevocations(repo, revocations)
+		ctx := context.Background()
+		userId := primitive.NewObjectID()
+
+		first, _ := svc.IssueTokens(ctx, userId, nil)
+		second, _ := svc.IssueTokens(ctx, userId, nil)
+
+		if err := svc.RevokeUserTokens(ctx, userId); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		if _, ok := revocations.revokedUsers[userId.Hex()]; !ok {
+			t.Error("Expected user cutoff to be recorded")
+		}
+
+		for _, refreshToken := range []string{first.RefreshToken, second.RefreshToken} {
+			if _, err := svc.Refresh(ctx, refreshToken); !errors.Is(err, auth.ErrInvalidRefreshToken) {
+				t.Errorf("Expected auth.ErrInvalidRefreshToken, got: %v", err)
+			}
+		}
+	})
+}
@@ -2,54 +2,190 @@ package config
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"math"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/ritchie-gr8/7solution-be/internal/logging"
 )
 
 func parseEnvInt(envMap map[string]string, key string, errorMsg string) int {
 	val, err := strconv.Atoi(envMap[key])
 	if err != nil {
-		log.Fatalf("%s: %v", errorMsg, err)
+		logging.Fatal(errorMsg, "key", key, "error", err)
 	}
 	return val
 }
 
+func envOr(envMap map[string]string, key string, fallback string) string {
+	if val := strings.TrimSpace(envMap[key]); val != "" {
+		return val
+	}
+	return fallback
+}
+
+// parseEnvIntOr is parseEnvInt for optional settings, it returns fallback
+// when the variable is not set.
+func parseEnvIntOr(envMap map[string]string, key string, fallback int, errorMsg string) int {
+	if strings.TrimSpace(envMap[key]) == "" {
+		return fallback
+	}
+	return parseEnvInt(envMap, key, errorMsg)
+}
+
 func parseEnvDuration(envMap map[string]string, key string, errorMsg string) time.Duration {
 	seconds := parseEnvInt(envMap, key, errorMsg)
 	return time.Duration(int64(seconds) * int64(math.Pow10(9)))
 }
 
+// parseEnvKeyFiles reads a comma separated list of kid=path pairs.
+func parseEnvKeyFiles(envMap map[string]string, key string, errorMsg string) map[string]string {
+	files := make(map[string]string)
+	if strings.TrimSpace(envMap[key]) == "" {
+		return files
+	}
+
+	for _, pair := range strings.Split(envMap[key], ",") {
+		kid, path, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || kid == "" || path == "" {
+			logging.Fatal(errorMsg, "key", key, "error", fmt.Sprintf("invalid entry %q", pair))
+		}
+		files[kid] = path
+	}
+	return files
+}
+
+// parseEnvList reads a comma separated list, leaving out empty entries.
+func parseEnvList(envMap map[string]string, key string) []string {
+	var list []string
+	for _, entry := range strings.Split(envMap[key], ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+// parseEnvLevel reads a log level name such as debug, info, warn or error.
+func parseEnvLevel(envMap map[string]string, key string, fallback slog.Level, errorMsg string) slog.Level {
+	if strings.TrimSpace(envMap[key]) == "" {
+		return fallback
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(envMap[key]))); err != nil {
+		logging.Fatal(errorMsg, "key", key, "error", err)
+	}
+	return level
+}
+
 func LoadConfig(path string) IConfig {
 	envMap, err := godotenv.Read(path)
 	if err != nil {
-		log.Fatalf("load env failed: %v", err)
+		logging.Fatal("load env failed", "path", path, "error", err)
 	}
 
 	return &config{
 		app: &app{
-			host:         envMap["APP_HOST"],
-			port:         parseEnvInt(envMap, "APP_PORT", "load port failed"),
-			name:         envMap["APP_NAME"],
-			version:      envMap["APP_VERSION"],
-			readTimeout:  parseEnvDuration(envMap, "APP_READ_TIMEOUT", "load read timeout failed"),
-			writeTimeout: parseEnvDuration(envMap, "APP_WRITE_TIMEOUT", "load write timeout failed"),
-			bodyLimit:    parseEnvInt(envMap, "APP_BODY_LIMIT", "load body limit failed"),
+			host:            envMap["APP_HOST"],
+			port:            parseEnvInt(envMap, "APP_PORT", "load port failed"),
+			name:            envMap["APP_NAME"],
+			version:         envMap["APP_VERSION"],
+			readTimeout:     parseEnvDuration(envMap, "APP_READ_TIMEOUT", "load read timeout failed"),
+			writeTimeout:    parseEnvDuration(envMap, "APP_WRITE_TIMEOUT", "load write timeout failed"),
+			bodyLimit:       parseEnvInt(envMap, "APP_BODY_LIMIT", "load body limit failed"),
+			shutdownTimeout: time.Duration(parseEnvIntOr(envMap, "APP_SHUTDOWN_TIMEOUT", 30, "load shutdown timeout failed")) * time.Second,
+			shutdownDelay:   time.Duration(parseEnvIntOr(envMap, "APP_SHUTDOWN_DELAY", 0, "load shutdown delay failed")) * time.Second,
+			requestTimeout:  time.Duration(parseEnvIntOr(envMap, "APP_REQUEST_TIMEOUT", 15, "load request timeout failed")) * time.Second,
+			proxyHeader:     envMap["APP_PROXY_HEADER"],
+			trustedProxies:  parseEnvList(envMap, "APP_TRUSTED_PROXIES"),
 		},
 		db: &db{
-			host:        envMap["DB_HOST"],
-			port:        parseEnvInt(envMap, "DB_PORT", "load database port failed"),
-			username:    envMap["DB_USER"],
-			password:    envMap["DB_PASSWORD"],
-			database:    envMap["DB_NAME"],
-			maxPoolSize: parseEnvInt(envMap, "DB_MAX_POOL_SIZE", "load max pool size failed"),
+			host:             envMap["DB_HOST"],
+			port:             parseEnvInt(envMap, "DB_PORT", "load database port failed"),
+			username:         envMap["DB_USER"],
+			password:         envMap["DB_PASSWORD"],
+			database:         envMap["DB_NAME"],
+			maxPoolSize:      parseEnvInt(envMap, "DB_MAX_POOL_SIZE", "load max pool size failed"),
+			userStore:        envOr(envMap, "DB_USER_STORE", "mongo"),
+			postgresUrl:      envMap["DB_POSTGRES_URL"],
+			snapshotFile:     envMap["DB_MEMORY_SNAPSHOT_FILE"],
+			snapshotInterval: time.Duration(parseEnvIntOr(envMap, "DB_MEMORY_SNAPSHOT_INTERVAL", 60, "load memory snapshot interval failed")) * time.Second,
+			migrateOnStart:   envOr(envMap, "DB_MIGRATE_ON_START", "true") == "true",
 		},
 		jwt: &jwt{
-			secretKey:       envMap["JWT_SECRET_KEY"],
-			accessExpiresAt: parseEnvInt(envMap, "JWT_ACCESS_EXPIRES", "load access expires at failed"),
+			secretKey:        envMap["JWT_SECRET_KEY"],
+			signingKeyFile:   envMap["JWT_SIGNING_KEY_FILE"],
+			signingKeyId:     envMap["JWT_SIGNING_KEY_ID"],
+			verificationKeys: parseEnvKeyFiles(envMap, "JWT_VERIFICATION_KEYS", "load verification keys failed"),
+			accessExpiresAt:  parseEnvInt(envMap, "JWT_ACCESS_EXPIRES", "load access expires at failed"),
+			refreshExpiresAt: parseEnvInt(envMap, "JWT_REFRESH_EXPIRES", "load refresh expires at failed"),
+		},
+		password: &password{
+			resetUrl:       envMap["PASSWORD_RESET_URL"],
+			resetExpiresAt: parseEnvInt(envMap, "PASSWORD_RESET_EXPIRES", "load password reset expires at failed"),
+		},
+		emailVerification: &emailVerification{
+			secret:    envMap["EMAIL_VERIFICATION_SECRET"],
+			url:       envMap["EMAIL_VERIFICATION_URL"],
+			expiresAt: parseEnvInt(envMap, "EMAIL_VERIFICATION_EXPIRES", "load email verification expires at failed"),
+			policy:    envMap["EMAIL_VERIFICATION_POLICY"],
+		},
+		mail: &mail{
+			driver:        envOr(envMap, "MAIL_DRIVER", "stdout"),
+			host:          envMap["MAIL_SMTP_HOST"],
+			port:          parseEnvIntOr(envMap, "MAIL_SMTP_PORT", 587, "load mail smtp port failed"),
+			username:      envMap["MAIL_SMTP_USERNAME"],
+			password:      envMap["MAIL_SMTP_PASSWORD"],
+			from:          envMap["MAIL_FROM"],
+			dir:           envMap["MAIL_FILE_DIR"],
+			defaultLocale: envOr(envMap, "MAIL_DEFAULT_LOCALE", "en"),
+		},
+		login: &login{
+			maxAccountFailures: parseEnvIntOr(envMap, "LOGIN_MAX_ACCOUNT_FAILURES", 5, "load login max account failures failed"),
+			maxIPFailures:      parseEnvIntOr(envMap, "LOGIN_MAX_IP_FAILURES", 20, "load login max ip failures failed"),
+			lockoutBase:        parseEnvIntOr(envMap, "LOGIN_LOCKOUT_BASE", 30, "load login lockout base failed"),
+			lockoutMax:         parseEnvIntOr(envMap, "LOGIN_LOCKOUT_MAX", 900, "load login lockout max failed"),
+			failureWindow:      parseEnvIntOr(envMap, "LOGIN_FAILURE_WINDOW", 3600, "load login failure window failed"),
+		},
+		mfa: &mfa{
+			issuer:             envOr(envMap, "MFA_ISSUER", envMap["APP_NAME"]),
+			challengeSecret:    envMap["MFA_CHALLENGE_SECRET"],
+			challengeExpiresAt: parseEnvIntOr(envMap, "MFA_CHALLENGE_EXPIRES", 300, "load mfa challenge expires at failed"),
+		},
+		rateLimit: &rateLimit{
+			store:          envOr(envMap, "RATE_LIMIT_STORE", "memory"),
+			algorithm:      envOr(envMap, "RATE_LIMIT_ALGORITHM", "token_bucket"),
+			apiKeyHeader:   envOr(envMap, "RATE_LIMIT_API_KEY_HEADER", "X-API-Key"),
+			apiKeys:        parseEnvList(envMap, "RATE_LIMIT_API_KEYS"),
+			globalRequests: parseEnvIntOr(envMap, "RATE_LIMIT_GLOBAL_REQUESTS", 300, "load rate limit global requests failed"),
+			globalWindow:   parseEnvIntOr(envMap, "RATE_LIMIT_GLOBAL_WINDOW", 60, "load rate limit global window failed"),
+			authRequests:   parseEnvIntOr(envMap, "RATE_LIMIT_AUTH_REQUESTS", 10, "load rate limit auth requests failed"),
+			authWindow:     parseEnvIntOr(envMap, "RATE_LIMIT_AUTH_WINDOW", 60, "load rate limit auth window failed"),
+			userRequests:   parseEnvIntOr(envMap, "RATE_LIMIT_USER_REQUESTS", 120, "load rate limit user requests failed"),
+			userWindow:     parseEnvIntOr(envMap, "RATE_LIMIT_USER_WINDOW", 60, "load rate limit user window failed"),
+		},
+		log: &log{
+			format: envOr(envMap, "LOG_FORMAT", "json"),
+			level:  parseEnvLevel(envMap, "LOG_LEVEL", slog.LevelInfo, "load log level failed"),
+		},
+		health: &health{
+			checkTimeout:  parseEnvIntOr(envMap, "HEALTH_CHECK_TIMEOUT", 2, "load health check timeout failed"),
+			cacheTTL:      parseEnvIntOr(envMap, "HEALTH_CACHE_TTL", 5, "load health cache ttl failed"),
+			diskPath:      envOr(envMap, "HEALTH_DISK_PATH", "."),
+			diskMinFreeMB: parseEnvIntOr(envMap, "HEALTH_DISK_MIN_FREE_MB", 100, "load health disk min free failed"),
+		},
+		metrics: &metrics{
+			addr: envMap["METRICS_ADDR"],
+		},
+		tracing: &tracing{
+			exporter:      envOr(envMap, "TRACING_EXPORTER", "none"),
+			otlpEndpoint:  envMap["TRACING_OTLP_ENDPOINT"],
+			otlpInsecure:  envMap["TRACING_OTLP_INSECURE"] == "true",
+			samplePercent: parseEnvIntOr(envMap, "TRACING_SAMPLE_PERCENT", 100, "load tracing sample percent failed"),
 		},
 	}
 }
@@ -58,12 +194,32 @@ type IConfig interface {
 	App() IAppConfig
 	DB() IDBConfig
 	Jwt() IJwtConfig
+	Password() IPasswordConfig
+	EmailVerification() IEmailVerificationConfig
+	Mail() IMailConfig
+	Login() ILoginConfig
+	MFA() IMFAConfig
+	RateLimit() IRateLimitConfig
+	Metrics() IMetricsConfig
+	Tracing() ITracingConfig
+	Log() ILogConfig
+	Health() IHealthConfig
 }
 
 type config struct {
-	app *app
-	db  *db
-	jwt *jwt
+	app               *app
+	db                *db
+	jwt               *jwt
+	password          *password
+	emailVerification *emailVerification
+	mail              *mail
+	login             *login
+	mfa               *mfa
+	rateLimit         *rateLimit
+	metrics           *metrics
+	tracing           *tracing
+	log               *log
+	health            *health
 }
 
 type IAppConfig interface {
@@ -75,30 +231,49 @@ type IAppConfig interface {
 	BodyLimit() int
 	Host() string
 	Port() int
+	ShutdownTimeout() time.Duration
+	ShutdownDelay() time.Duration
+	RequestTimeout() time.Duration
+	ProxyHeader() string
+	TrustedProxies() []string
 }
 
 type app struct {
-	host         string
-	port         int
-	name         string
-	version      string
-	readTimeout  time.Duration
-	writeTimeout time.Duration
-	bodyLimit    int
+	host            string
+	port            int
+	name            string
+	version         string
+	readTimeout     time.Duration
+	writeTimeout    time.Duration
+	bodyLimit       int
+	shutdownTimeout time.Duration
+	shutdownDelay   time.Duration
+	requestTimeout  time.Duration
+	proxyHeader     string
+	trustedProxies  []string
 }
 
 func (c *config) App() IAppConfig {
 	return c.app
 }
 
-func (a *app) Url() string                 { return fmt.Sprintf("%s:%d", a.host, a.port) }
-func (a *app) Name() string                { return a.name }
-func (a *app) Version() string             { return a.version }
-func (a *app) ReadTimeout() time.Duration  { return a.readTimeout }
-func (a *app) WriteTimeout() time.Duration { return a.writeTimeout }
-func (a *app) Host() string                { return a.host }
-func (a *app) Port() int                   { return a.port }
-func (a *app) BodyLimit() int              { return a.bodyLimit }
+func (a *app) Url() string                    { return fmt.Sprintf("%s:%d", a.host, a.port) }
+func (a *app) Name() string                   { return a.name }
+func (a *app) Version() string                { return a.version }
+func (a *app) ReadTimeout() time.Duration     { return a.readTimeout }
+func (a *app) WriteTimeout() time.Duration    { return a.writeTimeout }
+func (a *app) Host() string                   { return a.host }
+func (a *app) Port() int                      { return a.port }
+func (a *app) BodyLimit() int                 { return a.bodyLimit }
+func (a *app) ShutdownTimeout() time.Duration { return a.shutdownTimeout }
+func (a *app) ShutdownDelay() time.Duration   { return a.shutdownDelay }
+func (a *app) RequestTimeout() time.Duration  { return a.requestTimeout }
+
+// ProxyHeader carries the client address set by the load balancer. It is only
+// read on requests from TrustedProxies, addresses or CIDR ranges, so clients
+// cannot pick their own address to get around the rate limits.
+func (a *app) ProxyHeader() string      { return a.proxyHeader }
+func (a *app) TrustedProxies() []string { return a.trustedProxies }
 
 type IDBConfig interface {
 	Url() string
@@ -108,6 +283,11 @@ type IDBConfig interface {
 	Database() string
 	Username() string
 	Password() string
+	UserStore() string
+	PostgresUrl() string
+	MemorySnapshotFile() string
+	MemorySnapshotInterval() time.Duration
+	MigrateOnStart() bool
 }
 
 type db struct {
@@ -117,6 +297,12 @@ type db struct {
 	password    string
 	database    string
 	maxPoolSize int
+	userStore   string
+	postgresUrl string
+	// snapshotFile and snapshotInterval are only used with the memory store.
+	snapshotFile     string
+	snapshotInterval time.Duration
+	migrateOnStart   bool
 }
 
 func (c *config) DB() IDBConfig {
@@ -139,21 +325,288 @@ func (d *db) Database() string { return d.database }
 func (d *db) Username() string { return d.username }
 func (d *db) Password() string { return d.password }
 
+// UserStore is the backend users are stored in, "mongo", "postgres" or
+// "memory". With "memory" no database is used at all.
+func (d *db) UserStore() string                     { return d.userStore }
+func (d *db) PostgresUrl() string                   { return d.postgresUrl }
+func (d *db) MemorySnapshotFile() string            { return d.snapshotFile }
+func (d *db) MemorySnapshotInterval() time.Duration { return d.snapshotInterval }
+
+// MigrateOnStart runs the pending MongoDB migrations before the server starts.
+// Turn it off when migrations are run as a separate deploy step.
+func (d *db) MigrateOnStart() bool { return d.migrateOnStart }
+
 type IJwtConfig interface {
 	SecretKey() []byte
+	SigningKeyFile() string
+	SigningKeyId() string
+	VerificationKeyFiles() map[string]string
 	AccessExpiresAt() int
+	RefreshExpiresAt() int
 	SetJwtAccessExpires(t int)
 }
 
 type jwt struct {
-	secretKey       string
-	accessExpiresAt int
+	secretKey        string
+	signingKeyFile   string
+	signingKeyId     string
+	verificationKeys map[string]string
+	accessExpiresAt  int
+	refreshExpiresAt int
 }
 
 func (c *config) Jwt() IJwtConfig {
 	return c.jwt
 }
 
-func (j *jwt) SecretKey() []byte         { return []byte(j.secretKey) }
-func (j *jwt) AccessExpiresAt() int      { return j.accessExpiresAt }
-func (j *jwt) SetJwtAccessExpires(t int) { j.accessExpiresAt = t }
+func (j *jwt) SecretKey() []byte                       { return []byte(j.secretKey) }
+func (j *jwt) SigningKeyFile() string                  { return j.signingKeyFile }
+func (j *jwt) SigningKeyId() string                    { return j.signingKeyId }
+func (j *jwt) VerificationKeyFiles() map[string]string { return j.verificationKeys }
+func (j *jwt) AccessExpiresAt() int                    { return j.accessExpiresAt }
+func (j *jwt) RefreshExpiresAt() int                   { return j.refreshExpiresAt }
+func (j *jwt) SetJwtAccessExpires(t int)               { j.accessExpiresAt = t }
+
+type IPasswordConfig interface {
+	ResetUrl() string
+	ResetExpiresAt() int
+}
+
+type password struct {
+	resetUrl       string
+	resetExpiresAt int
+}
+
+func (c *config) Password() IPasswordConfig {
+	return c.password
+}
+
+func (p *password) ResetUrl() string    { return p.resetUrl }
+func (p *password) ResetExpiresAt() int { return p.resetExpiresAt }
+
+type IEmailVerificationConfig interface {
+	Secret() []byte
+	Url() string
+	ExpiresAt() int
+	Policy() string
+}
+
+type emailVerification struct {
+	secret    string
+	url       string
+	expiresAt int
+	policy    string
+}
+
+func (c *config) EmailVerification() IEmailVerificationConfig {
+	return c.emailVerification
+}
+
+func (e *emailVerification) Secret() []byte { return []byte(e.secret) }
+func (e *emailVerification) Url() string    { return e.url }
+func (e *emailVerification) ExpiresAt() int { return e.expiresAt }
+func (e *emailVerification) Policy() string { return e.policy }
+
+type IMailConfig interface {
+	Driver() string
+	Host() string
+	Port() int
+	Username() string
+	Password() string
+	From() string
+	Dir() string
+	DefaultLocale() string
+}
+
+type mail struct {
+	driver        string
+	host          string
+	port          int
+	username      string
+	password      string
+	from          string
+	dir           string
+	defaultLocale string
+}
+
+func (c *config) Mail() IMailConfig {
+	return c.mail
+}
+
+func (m *mail) Driver() string        { return m.driver }
+func (m *mail) Host() string          { return m.host }
+func (m *mail) Port() int             { return m.port }
+func (m *mail) Username() string      { return m.username }
+func (m *mail) Password() string      { return m.password }
+func (m *mail) From() string          { return m.from }
+func (m *mail) Dir() string           { return m.dir }
+func (m *mail) DefaultLocale() string { return m.defaultLocale }
+
+type ILoginConfig interface {
+	MaxAccountFailures() int
+	MaxIPFailures() int
+	LockoutBase() time.Duration
+	LockoutMax() time.Duration
+	FailureWindow() time.Duration
+}
+
+type login struct {
+	maxAccountFailures int
+	maxIPFailures      int
+	lockoutBase        int
+	lockoutMax         int
+	failureWindow      int
+}
+
+func (c *config) Login() ILoginConfig {
+	return c.login
+}
+
+func (l *login) MaxAccountFailures() int      { return l.maxAccountFailures }
+func (l *login) MaxIPFailures() int           { return l.maxIPFailures }
+func (l *login) LockoutBase() time.Duration   { return time.Duration(l.lockoutBase) * time.Second }
+func (l *login) LockoutMax() time.Duration    { return time.Duration(l.lockoutMax) * time.Second }
+func (l *login) FailureWindow() time.Duration { return time.Duration(l.failureWindow) * time.Second }
+
+type IMFAConfig interface {
+	Issuer() string
+	ChallengeSecret() []byte
+	ChallengeExpiresAt() int
+}
+
+type mfa struct {
+	issuer             string
+	challengeSecret    string
+	challengeExpiresAt int
+}
+
+func (c *config) MFA() IMFAConfig {
+	return c.mfa
+}
+
+func (m *mfa) Issuer() string          { return m.issuer }
+func (m *mfa) ChallengeSecret() []byte { return []byte(m.challengeSecret) }
+func (m *mfa) ChallengeExpiresAt() int { return m.challengeExpiresAt }
+
+type IRateLimitConfig interface {
+	Store() string
+	Algorithm() string
+	APIKeyHeader() string
+	APIKeys() []string
+	GlobalRequests() int
+	GlobalWindow() time.Duration
+	AuthRequests() int
+	AuthWindow() time.Duration
+	UserRequests() int
+	UserWindow() time.Duration
+}
+
+type rateLimit struct {
+	store          string
+	algorithm      string
+	apiKeyHeader   string
+	apiKeys        []string
+	globalRequests int
+	globalWindow   int
+	authRequests   int
+	authWindow     int
+	userRequests   int
+	userWindow     int
+}
+
+func (c *config) RateLimit() IRateLimitConfig {
+	return c.rateLimit
+}
+
+func (r *rateLimit) Store() string               { return r.store }
+func (r *rateLimit) Algorithm() string           { return r.algorithm }
+func (r *rateLimit) APIKeyHeader() string        { return r.apiKeyHeader }
+func (r *rateLimit) GlobalRequests() int         { return r.globalRequests }
+func (r *rateLimit) GlobalWindow() time.Duration { return time.Duration(r.globalWindow) * time.Second }
+func (r *rateLimit) AuthRequests() int           { return r.authRequests }
+func (r *rateLimit) AuthWindow() time.Duration   { return time.Duration(r.authWindow) * time.Second }
+func (r *rateLimit) UserRequests() int           { return r.userRequests }
+func (r *rateLimit) UserWindow() time.Duration   { return time.Duration(r.userWindow) * time.Second }
+
+// APIKeys are the registered keys that get a global budget of their own.
+// Requests with any other key are counted per IP address.
+func (r *rateLimit) APIKeys() []string { return r.apiKeys }
+
+type IMetricsConfig interface {
+	Addr() string
+}
+
+type metrics struct {
+	addr string
+}
+
+func (c *config) Metrics() IMetricsConfig {
+	return c.metrics
+}
+
+// Addr is the host:port /metrics is served on, apart from the API so it is
+// not reachable through the public port. Empty turns the endpoint off.
+func (m *metrics) Addr() string { return m.addr }
+
+type ITracingConfig interface {
+	Exporter() string
+	OTLPEndpoint() string
+	OTLPInsecure() bool
+	SamplePercent() int
+}
+
+type tracing struct {
+	exporter      string
+	otlpEndpoint  string
+	otlpInsecure  bool
+	samplePercent int
+}
+
+func (c *config) Tracing() ITracingConfig {
+	return c.tracing
+}
+
+func (t *tracing) Exporter() string     { return t.exporter }
+func (t *tracing) OTLPEndpoint() string { return t.otlpEndpoint }
+func (t *tracing) OTLPInsecure() bool   { return t.otlpInsecure }
+func (t *tracing) SamplePercent() int   { return t.samplePercent }
+
+type ILogConfig interface {
+	Format() string
+	Level() slog.Level
+}
+
+type log struct {
+	format string
+	level  slog.Level
+}
+
+func (c *config) Log() ILogConfig {
+	return c.log
+}
+
+func (l *log) Format() string    { return l.format }
+func (l *log) Level() slog.Level { return l.level }
+
+type IHealthConfig interface {
+	CheckTimeout() time.Duration
+	CacheTTL() time.Duration
+	DiskPath() string
+	DiskMinFreeBytes() uint64
+}
+
+type health struct {
+	checkTimeout  int
+	cacheTTL      int
+	diskPath      string
+	diskMinFreeMB int
+}
+
+func (c *config) Health() IHealthConfig {
+	return c.health
+}
+
+func (h *health) CheckTimeout() time.Duration { return time.Duration(h.checkTimeout) * time.Second }
+func (h *health) CacheTTL() time.Duration     { return time.Duration(h.cacheTTL) * time.Second }
+func (h *health) DiskPath() string            { return h.diskPath }
+func (h *health) DiskMinFreeBytes() uint64    { return uint64(h.diskMinFreeMB) << 20 }
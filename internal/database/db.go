@@ -2,10 +2,11 @@ package databases
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/ritchie-gr8/7solution-be/internal/config"
+	"github.com/ritchie-gr8/7solution-be/internal/logging"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
@@ -18,28 +19,40 @@ func DbConnect(cfg config.IDBConfig) *mongo.Client {
 	clientOptions := options.Client().ApplyURI(cfg.Url()).SetMaxPoolSize(uint64(cfg.MaxPoolSize()))
 	db, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
-		log.Fatalf("failed to connect to db: %v", err)
+		logging.Fatal("failed to connect to db", "error", err)
 	}
 
 	if err := db.Ping(ctx, readpref.Primary()); err != nil {
-		log.Fatalf("failed to ping db: %v", err)
+		logging.Fatal("failed to ping db", "error", err)
 	}
 
-	log.Printf("Connected to MongoDB with max pool size: %d", cfg.MaxPoolSize())
+	slog.Info("connected to MongoDB", "max_pool_size", cfg.MaxPoolSize())
 	return db
 }
 
-func DbDisconnect(db *mongo.Client) {
+// DbDisconnect closes the pool, waiting for operations in use until ctx is
+// done.
+func DbDisconnect(ctx context.Context, db *mongo.Client) error {
 	if db == nil {
-		return
+		return nil
 	}
 
+	if err := db.Disconnect(ctx); err != nil {
+		return err
+	}
+
+	slog.Info("disconnected from MongoDB")
+	return nil
+}
+
+// EnsureIndexes creates the given indexes if they do not exist yet. A failure
+// is logged rather than fatal so the API can still start against a database
+// user that lacks the createIndex privilege.
+func EnsureIndexes(collection *mongo.Collection, models []mongo.IndexModel) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if err := db.Disconnect(ctx); err != nil {
-		log.Fatalf("failed to disconnect from db: %v", err)
+	if _, err := collection.Indexes().CreateMany(ctx, models); err != nil {
+		slog.Warn("create indexes failed", "collection", collection.Name(), "error", err)
 	}
-
-	log.Println("Disconnected from MongoDB")
 }
@@ -0,0 +1,60 @@
+package databases
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/ritchie-gr8/7solution-be/internal/config"
+	"github.com/ritchie-gr8/7solution-be/internal/logging"
+)
+
+// PostgresConnect opens a pool to DB_POSTGRES_URL. The URL carries the
+// password, so it is never logged.
+func PostgresConnect(cfg config.IDBConfig) *pgxpool.Pool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	poolConfig, err := pgxpool.ParseConfig(cfg.PostgresUrl())
+	if err != nil {
+		logging.Fatal("failed to parse postgres url", "error", err)
+	}
+	if cfg.MaxPoolSize() > 0 {
+		poolConfig.MaxConns = int32(cfg.MaxPoolSize())
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		logging.Fatal("failed to connect to postgres", "error", err)
+	}
+
+	if err := pool.Ping(ctx); err != nil {
+		logging.Fatal("failed to ping postgres", "error", err)
+	}
+
+	slog.Info("connected to PostgreSQL", "max_pool_size", poolConfig.MaxConns)
+	return pool
+}
+
+// PostgresDisconnect closes the pool, waiting for connections in use to be
+// released.
+func PostgresDisconnect(pool *pgxpool.Pool) {
+	if pool == nil {
+		return
+	}
+
+	pool.Close()
+	slog.Info("disconnected from PostgreSQL")
+}
+
+// EnsureSchema runs the given DDL, which is expected to be idempotent. Like
+// EnsureIndexes, a failure is logged rather than fatal.
+func EnsureSchema(pool *pgxpool.Pool, name, schema string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := pool.Exec(ctx, schema); err != nil {
+		slog.Warn("create schema failed", "schema", name, "error", err)
+	}
+}
@@ -0,0 +1,63 @@
+package health
+
+import (
+	"context"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+)
+
+// IChecker reports whether a dependency is usable. Check must return once
+// ctx is done. A failing check that is not critical is reported but leaves
+// the server ready.
+type IChecker interface {
+	Name() string
+	Critical() bool
+	Check(ctx context.Context) error
+}
+
+type checker struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// NewChecker turns a function into a checker.
+func NewChecker(name string, check func(ctx context.Context) error) IChecker {
+	return &checker{name: name, check: check}
+}
+
+func (c *checker) Name() string                    { return c.name }
+func (c *checker) Critical() bool                  { return true }
+func (c *checker) Check(ctx context.Context) error { return c.check(ctx) }
+
+type nonCritical struct {
+	IChecker
+}
+
+// NonCritical reports checker without failing readiness, for dependencies the
+// server can serve requests without.
+func NonCritical(checker IChecker) IChecker {
+	return nonCritical{IChecker: checker}
+}
+
+func (nonCritical) Critical() bool { return false }
+
+type CheckResult struct {
+	Name      string  `json:"name"`
+	Status    Status  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status    Status         `json:"status"`
+	Message   string         `json:"message,omitempty"`
+	Checks    []*CheckResult `json:"checks"`
+	CheckedAt time.Time      `json:"checked_at"`
+}
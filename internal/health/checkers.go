@@ -0,0 +1,29 @@
+package health
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/ritchie-gr8/7solution-be/internal/mail"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// NewMongoChecker pings the primary, which is where every write goes.
+func NewMongoChecker(db *mongo.Client) IChecker {
+	return NewChecker("mongodb", func(ctx context.Context) error {
+		return db.Ping(ctx, readpref.Primary())
+	})
+}
+
+// NewPostgresChecker pings the database users are stored in when
+// DB_USER_STORE is postgres.
+func NewPostgresChecker(pool *pgxpool.Pool) IChecker {
+	return NewChecker("postgres", pool.Ping)
+}
+
+// NewMailerChecker checks that the mailer can take messages, e.g. that the
+// SMTP server answers.
+func NewMailerChecker(mailer mail.IMailer) IChecker {
+	return NewChecker("mailer", mailer.Ping)
+}
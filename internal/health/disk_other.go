@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package health
+
+import "context"
+
+// NewDiskChecker is a no-op where free space cannot be read with Statfs.
+func NewDiskChecker(path string, minFree uint64) IChecker {
+	return NewChecker("disk", func(ctx context.Context) error { return nil })
+}
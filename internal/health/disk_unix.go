@@ -0,0 +1,24 @@
+//go:build linux || darwin
+
+package health
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+)
+
+// NewDiskChecker fails once the file system holding path has less than
+// minFree bytes available.
+func NewDiskChecker(path string, minFree uint64) IChecker {
+	return NewChecker("disk", func(ctx context.Context) error {
+		var stat syscall.Statfs_t
+		if err := syscall.Statfs(path, &stat); err != nil {
+			return err
+		}
+		if free := uint64(stat.Bavail) * uint64(stat.Bsize); free < minFree {
+			return fmt.Errorf("%w: %d bytes free on %s", ErrLowDiskSpace, free, path)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,9 @@
+package health
+
+import "errors"
+
+var (
+	ErrNotReady      = errors.New("health: server is starting or shutting down")
+	ErrLowDiskSpace  = errors.New("health: free disk space is below the minimum")
+	ErrCheckTimedOut = errors.New("health: check timed out")
+)
@@ -1,6 +1,9 @@
 package health
 
 import (
+	"context"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/ritchie-gr8/7solution-be/internal/config"
 	"github.com/ritchie-gr8/7solution-be/pkg/response"
@@ -8,20 +11,57 @@ import (
 
 type IMonitorHandler interface {
 	HealthCheck(c *fiber.Ctx) error
+	Live(c *fiber.Ctx) error
+	Ready(c *fiber.Ctx) error
 }
 
 type monitorHandler struct {
-	cfg config.IConfig
+	cfg      config.IConfig
+	registry IRegistry
 }
 
-func NewMonitorHandler(cfg config.IConfig) IMonitorHandler {
-	return &monitorHandler{cfg: cfg}
+func NewMonitorHandler(cfg config.IConfig, registry IRegistry) IMonitorHandler {
+	return &monitorHandler{cfg: cfg, registry: registry}
 }
 
+// HealthCheck is a summary of the readiness probe for humans.
 func (h *monitorHandler) HealthCheck(c *fiber.Ctx) error {
-	return response.NewResponse(c).Success(fiber.StatusOK, map[string]string{
+	code, status := fiber.StatusOK, "ok"
+	if report := h.report(c); report.Status != StatusUp {
+		code, status = fiber.StatusServiceUnavailable, "unavailable"
+	}
+
+	return response.NewResponse(c).Success(code, map[string]string{
 		"name":    h.cfg.App().Name(),
 		"version": h.cfg.App().Version(),
-		"status":  "ok",
+		"status":  status,
+	}).Response()
+}
+
+// Live only tells that the process answers requests, it checks no
+// dependencies so a database outage does not get the process restarted.
+func (h *monitorHandler) Live(c *fiber.Ctx) error {
+	return response.NewResponse(c).Success(fiber.StatusOK, map[string]Status{
+		"status": StatusUp,
 	}).Response()
 }
+
+// Ready answers 503 while the server is starting or shutting down, or when
+// any dependency is down, so the load balancer stops sending traffic.
+func (h *monitorHandler) Ready(c *fiber.Ctx) error {
+	report := h.report(c)
+	code := fiber.StatusOK
+	if report.Status != StatusUp {
+		code = fiber.StatusServiceUnavailable
+	}
+	return response.NewResponse(c).Success(code, report).Response()
+}
+
+func (h *monitorHandler) report(c *fiber.Ctx) *Report {
+	if !h.registry.Ready() {
+		return &Report{Status: StatusDown, Message: ErrNotReady.Error(), Checks: []*CheckResult{}, CheckedAt: time.Now()}
+	}
+	// The report is cached and shared, so a client hanging up must not
+	// cancel the checks.
+	return h.registry.Check(context.WithoutCancel(c.UserContext()))
+}
@@ -0,0 +1,107 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// IRegistry runs the registered checkers for the readiness probe. The
+// registry starts out not ready, the server marks it ready once it listens
+// and not ready again when it starts shutting down.
+type IRegistry interface {
+	Register(checkers ...IChecker)
+	SetReady(ready bool)
+	Ready() bool
+	Check(ctx context.Context) *Report
+}
+
+type registry struct {
+	timeout time.Duration
+	ttl     time.Duration
+	now     func() time.Time
+	ready   atomic.Bool
+
+	mu       sync.Mutex
+	checkers []IChecker
+	cached   *Report
+}
+
+// NewRegistry gives every check timeout to answer and caches the report for
+// ttl, so frequent probes do not hammer the dependencies.
+func NewRegistry(timeout, ttl time.Duration) IRegistry {
+	return NewRegistryWithClock(timeout, ttl, time.Now)
+}
+
+// NewRegistryWithClock lets tests control time.
+func NewRegistryWithClock(timeout, ttl time.Duration, now func() time.Time) IRegistry {
+	return &registry{timeout: timeout, ttl: ttl, now: now}
+}
+
+func (r *registry) Register(checkers ...IChecker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checkers = append(r.checkers, checkers...)
+	r.cached = nil
+}
+
+func (r *registry) SetReady(ready bool) { r.ready.Store(ready) }
+func (r *registry) Ready() bool         { return r.ready.Load() }
+
+// Check runs all checkers concurrently, or returns the cached report while it
+// is fresh. Concurrent callers wait for the same run.
+func (r *registry) Check(ctx context.Context) *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cached != nil && r.now().Sub(r.cached.CheckedAt) < r.ttl {
+		return r.cached
+	}
+
+	report := &Report{Status: StatusUp, Checks: make([]*CheckResult, len(r.checkers)), CheckedAt: r.now()}
+
+	var wg sync.WaitGroup
+	for i, checker := range r.checkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Checks[i] = r.run(ctx, checker)
+		}()
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status == StatusDown && result.Critical {
+			report.Status = StatusDown
+		}
+	}
+
+	r.cached = report
+	return report
+}
+
+func (r *registry) run(ctx context.Context, checker IChecker) *CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := checker.Check(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = ErrCheckTimedOut
+	}
+
+	result := &CheckResult{
+		Name:      checker.Name(),
+		Status:    StatusUp,
+		Critical:  checker.Critical(),
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,133 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ritchie-gr8/7solution-be/internal/health"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time { return c.now }
+
+func TestRegistryCachesReport(t *testing.T) {
+	clk := &clock{now: time.Date(2025, 6, 4, 10, 0, 0, 0, time.UTC)}
+	registry := health.NewRegistryWithClock(time.Second, 5*time.Second, clk.Now)
+
+	calls := 0
+	var failure error
+	registry.Register(health.NewChecker("db", func(ctx context.Context) error {
+		calls++
+		return failure
+	}))
+
+	if report := registry.Check(context.Background()); report.Status != health.StatusUp {
+		t.Fatalf("Expected the report to be up, got: %s", report.Status)
+	}
+
+	failure = errors.New("connection refused")
+	clk.now = clk.now.Add(4 * time.Second)
+	if report := registry.Check(context.Background()); report.Status != health.StatusUp || calls != 1 {
+		t.Errorf("Expected the cached report, got: %s after %d calls", report.Status, calls)
+	}
+
+	clk.now = clk.now.Add(time.Second)
+	report := registry.Check(context.Background())
+	if report.Status != health.StatusDown || calls != 2 {
+		t.Fatalf("Expected a fresh failing report, got: %s after %d calls", report.Status, calls)
+	}
+	if report.Checks[0].Error != "connection refused" {
+		t.Errorf("Expected the check error in the report, got: %q", report.Checks[0].Error)
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	registry := health.NewRegistry(10*time.Millisecond, 0)
+	registry.Register(
+		health.NewChecker("fast", func(ctx context.Context) error { return nil }),
+		health.NewChecker("slow", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}),
+	)
+
+	report := registry.Check(context.Background())
+	if report.Status != health.StatusDown {
+		t.Fatalf("Expected the report to be down, got: %s", report.Status)
+	}
+	if report.Checks[0].Status != health.StatusUp {
+		t.Errorf("Expected the fast check to be up, got: %s", report.Checks[0].Status)
+	}
+	if report.Checks[1].Error != health.ErrCheckTimedOut.Error() {
+		t.Errorf("Expected the slow check to time out, got: %q", report.Checks[1].Error)
+	}
+}
+
+func TestNonCriticalCheck(t *testing.T) {
+	registry := health.NewRegistry(time.Second, 0)
+	registry.Register(
+		health.NewChecker("db", func(ctx context.Context) error { return nil }),
+		health.NonCritical(health.NewChecker("mailer", func(ctx context.Context) error {
+			return errors.New("connection refused")
+		})),
+	)
+
+	report := registry.Check(context.Background())
+	if report.Status != health.StatusUp {
+		t.Fatalf("Expected a failing non-critical check to leave the report up, got: %s", report.Status)
+	}
+	if mailer := report.Checks[1]; mailer.Status != health.StatusDown || mailer.Critical || mailer.Error == "" {
+		t.Errorf("Expected the mailer check to be reported down and non-critical, got: %+v", mailer)
+	}
+	if !report.Checks[0].Critical {
+		t.Error("Expected checks to be critical by default")
+	}
+}
+
+func TestReadinessProbe(t *testing.T) {
+	registry := health.NewRegistry(time.Second, 0)
+	registry.Register(health.NewChecker("db", func(ctx context.Context) error { return nil }))
+
+	handler := health.NewMonitorHandler(nil, registry)
+	app := fiber.New()
+	app.Get("/health/live", handler.Live)
+	app.Get("/health/ready", handler.Ready)
+
+	probe := func(path string) (int, *health.Report) {
+		res, err := app.Test(httptest.NewRequest(fiber.MethodGet, path, nil))
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		var report health.Report
+		if err := json.NewDecoder(res.Body).Decode(&report); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		return res.StatusCode, &report
+	}
+
+	if status, _ := probe("/health/live"); status != fiber.StatusOK {
+		t.Errorf("Expected liveness to be 200 during startup, got: %d", status)
+	}
+	if status, report := probe("/health/ready"); status != fiber.StatusServiceUnavailable || report.Message == "" {
+		t.Errorf("Expected readiness to be 503 during startup, got: %d %+v", status, report)
+	}
+
+	registry.SetReady(true)
+	status, report := probe("/health/ready")
+	if status != fiber.StatusOK || len(report.Checks) != 1 || report.Checks[0].Name != "db" {
+		t.Errorf("Expected readiness to be 200 with the db check, got: %d %+v", status, report)
+	}
+
+	registry.SetReady(false)
+	if status, _ := probe("/health/ready"); status != fiber.StatusServiceUnavailable {
+		t.Errorf("Expected readiness to be 503 during shutdown, got: %d", status)
+	}
+}
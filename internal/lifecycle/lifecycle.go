@@ -0,0 +1,152 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// IManager stops the application in a defined order. Shutdown phases run one
+// after the other in the order they were registered, all within one
+// deadline, and each is logged with how long it took.
+type IManager interface {
+	// OnShutdown registers a phase. Phases should give up once ctx is done.
+	OnShutdown(name string, run func(ctx context.Context) error)
+	// Go runs a background worker until StopWorkers cancels its context.
+	Go(name string, run func(ctx context.Context))
+	// StopWorkers cancels the workers and waits for them to return. It is
+	// meant to be registered as a phase itself.
+	StopWorkers(ctx context.Context) error
+	// Stop starts the shutdown without a signal, e.g. when the server fails
+	// to listen. Only the first cause is kept.
+	Stop(cause error)
+	// Wait blocks until SIGINT, SIGTERM or Stop and then shuts down.
+	Wait() error
+	// Shutdown runs the phases.
+	Shutdown() error
+}
+
+type phase struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+type manager struct {
+	timeout time.Duration
+	stop    chan error
+
+	mu     sync.Mutex
+	phases []phase
+
+	workers       sync.WaitGroup
+	workerCtx     context.Context
+	cancelWorkers context.CancelFunc
+}
+
+// NewManager gives the whole shutdown timeout to complete. Phases still
+// running at the deadline see their context canceled.
+func NewManager(timeout time.Duration) IManager {
+	workerCtx, cancel := context.WithCancel(context.Background())
+	return &manager{
+		timeout:       timeout,
+		stop:          make(chan error, 1),
+		workerCtx:     workerCtx,
+		cancelWorkers: cancel,
+	}
+}
+
+func (m *manager) OnShutdown(name string, run func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.phases = append(m.phases, phase{name: name, run: run})
+}
+
+func (m *manager) Go(name string, run func(ctx context.Context)) {
+	m.workers.Add(1)
+	go func() {
+		defer m.workers.Done()
+		run(m.workerCtx)
+		slog.Debug("worker stopped", "worker", name)
+	}()
+}
+
+func (m *manager) StopWorkers(ctx context.Context) error {
+	m.cancelWorkers()
+
+	done := make(chan struct{})
+	go func() {
+		m.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *manager) Stop(cause error) {
+	select {
+	case m.stop <- cause:
+	default:
+	}
+}
+
+func (m *manager) Wait() error {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	var cause error
+	select {
+	case sig := <-signals:
+		slog.Info("shutdown signal received", "signal", sig.String())
+	case cause = <-m.stop:
+		if cause != nil {
+			slog.Error("shutting down after failure", "error", cause)
+		}
+	}
+
+	// A second signal skips the graceful shutdown.
+	go func() {
+		sig := <-signals
+		slog.Error("second signal received, exiting", "signal", sig.String())
+		os.Exit(1)
+	}()
+
+	return errors.Join(cause, m.Shutdown())
+}
+
+func (m *manager) Shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	m.mu.Lock()
+	phases := m.phases
+	m.mu.Unlock()
+
+	start := time.Now()
+	var errs []error
+	for _, p := range phases {
+		phaseStart := time.Now()
+		err := p.run(ctx)
+		took := slog.Float64("duration_ms", float64(time.Since(phaseStart).Microseconds())/1000)
+		if err != nil {
+			slog.Error("shutdown phase failed", "phase", p.name, took, "error", err)
+			errs = append(errs, err)
+			continue
+		}
+		slog.Info("shutdown phase done", "phase", p.name, took)
+	}
+
+	slog.Info("shutdown complete", "duration_ms", float64(time.Since(start).Microseconds())/1000)
+	return errors.Join(errs...)
+}
@@ -0,0 +1,95 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ritchie-gr8/7solution-be/internal/lifecycle"
+)
+
+func TestPhasesRunInOrder(t *testing.T) {
+	lc := lifecycle.NewManager(time.Second)
+
+	var order []string
+	for _, name := range []string{"readiness", "http", "mongo"} {
+		lc.OnShutdown(name, func(ctx context.Context) error {
+			order = append(order, name)
+			return nil
+		})
+	}
+
+	if err := lc.Shutdown(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(order) != 3 || order[0] != "readiness" || order[1] != "http" || order[2] != "mongo" {
+		t.Errorf("Expected the phases in registration order, got: %v", order)
+	}
+}
+
+func TestPhasesShareTheDeadline(t *testing.T) {
+	lc := lifecycle.NewManager(20 * time.Millisecond)
+
+	lc.OnShutdown("http", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	ran := false
+	lc.OnShutdown("mongo", func(ctx context.Context) error {
+		ran = true
+		return nil
+	})
+
+	start := time.Now()
+	err := lc.Shutdown()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got: %v", err)
+	}
+	if !ran {
+		t.Error("Expected the later phases to run after a failed one")
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("Expected the shutdown to stop at the deadline, took: %s", took)
+	}
+}
+
+func TestStopWorkers(t *testing.T) {
+	lc := lifecycle.NewManager(time.Second)
+
+	stopped := make(chan struct{})
+	lc.Go("ticker", func(ctx context.Context) {
+		<-ctx.Done()
+		close(stopped)
+	})
+	lc.OnShutdown("workers", lc.StopWorkers)
+
+	if err := lc.Shutdown(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	select {
+	case <-stopped:
+	default:
+		t.Error("Expected the worker to have returned")
+	}
+}
+
+func TestStopTriggersShutdown(t *testing.T) {
+	lc := lifecycle.NewManager(time.Second)
+
+	shutDown := false
+	lc.OnShutdown("http", func(ctx context.Context) error {
+		shutDown = true
+		return nil
+	})
+
+	listenErr := errors.New("address already in use")
+	lc.Stop(listenErr)
+
+	if err := lc.Wait(); !errors.Is(err, listenErr) {
+		t.Errorf("Expected the stop cause, got: %v", err)
+	}
+	if !shutDown {
+		t.Error("Expected the phases to run")
+	}
+}
@@ -0,0 +1,39 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx carrying logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger of ctx, or the default one, with the trace
+// id of the span in ctx if there is one.
+func FromContext(ctx context.Context) *slog.Logger {
+	logger, ok := ctx.Value(loggerKey{}).(*slog.Logger)
+	if !ok {
+		logger = slog.Default()
+	}
+	if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.HasTraceID() {
+		logger = logger.With("trace_id", spanCtx.TraceID().String())
+	}
+	return logger
+}
+
+// FromCtx is the request's logger: the request id, the trace id, the matched
+// route and, once the token is validated, the user id.
+func FromCtx(c *fiber.Ctx) *slog.Logger {
+	logger := FromContext(c.UserContext()).With("route", c.Route().Path)
+	if userId, ok := c.Locals("userId").(string); ok && userId != "" {
+		logger = logger.With("user_id", userId)
+	}
+	return logger
+}
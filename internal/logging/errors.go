@@ -0,0 +1,7 @@
+package logging
+
+import "errors"
+
+var (
+	ErrUnknownFormat = errors.New("logging: unknown format")
+)
@@ -0,0 +1,45 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// New returns a logger writing format to w. Sensitive attributes are redacted
+// whatever handler they go through.
+func New(w io.Writer, format string, level slog.Level) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+
+	switch format {
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+}
+
+// Setup makes a logger writing to stdout the default, so slog's top level
+// functions and the standard log package go through it too.
+func Setup(format string, level slog.Level) *slog.Logger {
+	logger, err := New(os.Stdout, format, level)
+	if err != nil {
+		Fatal("load logger failed", "error", err)
+	}
+	slog.SetDefault(logger)
+	return logger
+}
+
+// Fatal logs msg at error level and exits.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
@@ -0,0 +1,34 @@
+package logging
+
+import (
+	"log/slog"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute and header names whose values never reach the
+// logs, matched case-insensitively.
+var sensitiveKeys = map[string]bool{
+	"password":         true,
+	"current_password": true,
+	"new_password":     true,
+	"token":            true,
+	"access_token":     true,
+	"refresh_token":    true,
+	"mfa_token":        true,
+	"secret":           true,
+	"code":             true,
+	"recovery_code":    true,
+	"authorization":    true,
+	"cookie":           true,
+	"set-cookie":       true,
+	"x-api-key":        true,
+}
+
+func redact(_ []string, a slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+	return a
+}
@@ -0,0 +1,110 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ritchie-gr8/7solution-be/internal/logging"
+	"github.com/ritchie-gr8/7solution-be/internal/middleware"
+)
+
+func newLogger(t *testing.T, level slog.Level) (*slog.Logger, *bytes.Buffer) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, logging.FormatJSON, level)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	return logger, &buf
+}
+
+func entries(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var result []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Expected a JSON log line, got: %q", line)
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
+func TestUnknownFormat(t *testing.T) {
+	_, err := logging.New(&bytes.Buffer{}, "xml", slog.LevelInfo)
+	if !errors.Is(err, logging.ErrUnknownFormat) {
+		t.Errorf("Expected logging.ErrUnknownFormat, got: %v", err)
+	}
+}
+
+func TestSensitiveFieldsAreRedacted(t *testing.T) {
+	logger, buf := newLogger(t, slog.LevelInfo)
+
+	logger.Info("login", "email", "john@example.com", "Password", "hunter2",
+		slog.Group("headers", "Authorization", "Bearer abc"))
+
+	entry := entries(t, buf)[0]
+	if entry["email"] != "john@example.com" {
+		t.Errorf("Expected email to be logged, got: %v", entry["email"])
+	}
+	if entry["Password"] != "[REDACTED]" {
+		t.Errorf("Expected the password to be redacted, got: %v", entry["Password"])
+	}
+	if headers := entry["headers"].(map[string]any); headers["Authorization"] != "[REDACTED]" {
+		t.Errorf("Expected the Authorization header to be redacted, got: %v", headers["Authorization"])
+	}
+}
+
+func TestRequestLogger(t *testing.T) {
+	logger, buf := newLogger(t, slog.LevelDebug)
+
+	app := fiber.New()
+	app.Use(middleware.RequestLogger(logger))
+	app.Get("/widgets/:id", func(c *fiber.Ctx) error {
+		c.Locals("userId", "user-1")
+		logging.FromCtx(c).Info("loading widget")
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(fiber.MethodGet, "/widgets/1", nil)
+	req.Header.Set(fiber.HeaderXRequestID, "req-1")
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer abc")
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if res.Header.Get(fiber.HeaderXRequestID) != "req-1" {
+		t.Errorf("Expected the request id to be echoed, got: %q", res.Header.Get(fiber.HeaderXRequestID))
+	}
+
+	logged := entries(t, buf)
+	if len(logged) != 2 {
+		t.Fatalf("Expected a handler and an access log entry, got: %d", len(logged))
+	}
+
+	handler := logged[0]
+	for key, want := range map[string]any{"request_id": "req-1", "route": "/widgets/:id", "user_id": "user-1"} {
+		if handler[key] != want {
+			t.Errorf("Expected the handler entry's %s to be %v, got: %v", key, want, handler[key])
+		}
+	}
+
+	access := logged[1]
+	for key, want := range map[string]any{"msg": "request", "request_id": "req-1", "method": "GET", "route": "/widgets/:id", "status": float64(204), "user_id": "user-1"} {
+		if access[key] != want {
+			t.Errorf("Expected the access entry's %s to be %v, got: %v", key, want, access[key])
+		}
+	}
+	if headers := access["headers"].(map[string]any); headers["Authorization"] != "[REDACTED]" {
+		t.Errorf("Expected the Authorization header to be redacted, got: %v", headers["Authorization"])
+	}
+}
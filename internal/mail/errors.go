@@ -0,0 +1,10 @@
+package mail
+
+import "errors"
+
+var (
+	ErrNoRecipients     = errors.New("mail: message has no recipients")
+	ErrEmptyMessage     = errors.New("mail: message has neither a text nor an html body")
+	ErrUnknownDriver    = errors.New("mail: unknown driver")
+	ErrTemplateNotFound = errors.New("mail: template not found")
+)
@@ -0,0 +1,89 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+type fileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer stores every message as an .eml file in dir, which most mail
+// clients can open. It is meant for development and tests.
+func NewFileMailer(dir, from string) (IMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &fileMailer{dir: dir, from: from}, nil
+}
+
+func (m *fileMailer) Send(ctx context.Context, msg *Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+
+	data, err := encode(m.from, msg)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(m.dir, time.Now().UTC().Format("20060102T150405")+"-*.eml")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Ping checks that the directory is still there.
+func (m *fileMailer) Ping(ctx context.Context) error {
+	info, err := os.Stat(m.dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("mail: %s is not a directory", m.dir)
+	}
+	return nil
+}
+
+type writerMailer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	from string
+}
+
+// NewWriterMailer writes every message to w, e.g. os.Stdout.
+func NewWriterMailer(w io.Writer, from string) IMailer {
+	return &writerMailer{w: w, from: from}
+}
+
+func (m *writerMailer) Send(ctx context.Context, msg *Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+
+	data, err := encode(m.from, msg)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err = fmt.Fprintf(m.w, "----- mail -----\r\n%s\r\n----- end mail -----\r\n", data)
+	return err
+}
+
+func (m *writerMailer) Ping(ctx context.Context) error {
+	return nil
+}
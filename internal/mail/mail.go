@@ -0,0 +1,55 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/ritchie-gr8/7solution-be/internal/config"
+)
+
+const (
+	DriverSMTP   = "smtp"
+	DriverFile   = "file"
+	DriverStdout = "stdout"
+)
+
+type Message struct {
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// IMailer sends a message. The sender address is part of the mailer's
+// configuration, not of the message. Ping reports whether the mailer can
+// currently take messages.
+type IMailer interface {
+	Send(ctx context.Context, msg *Message) error
+	Ping(ctx context.Context) error
+}
+
+// New builds the mailer selected by MAIL_DRIVER. Without a driver, mail is
+// written to stdout.
+func New(cfg config.IMailConfig) (IMailer, error) {
+	switch cfg.Driver() {
+	case DriverSMTP:
+		return NewSMTPMailer(cfg.Host(), cfg.Port(), cfg.Username(), cfg.Password(), cfg.From()), nil
+	case DriverFile:
+		return NewFileMailer(cfg.Dir(), cfg.From())
+	case DriverStdout, "":
+		return NewWriterMailer(os.Stdout, cfg.From()), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownDriver, cfg.Driver())
+	}
+}
+
+func validate(msg *Message) error {
+	if len(msg.To) == 0 {
+		return ErrNoRecipients
+	}
+	if msg.Text == "" && msg.HTML == "" {
+		return ErrEmptyMessage
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var embedded embed.FS
+
+// Templates renders messages from a directory per locale, e.g.
+//
+//	en/password_reset.txt
+//	en/password_reset.html
+//	th/password_reset.txt
+//
+// The text template also defines the subject with {{define "subject"}}.
+type Templates struct {
+	defaultLocale string
+	text          map[string]*texttemplate.Template
+	html          map[string]*htmltemplate.Template
+}
+
+// DefaultTemplates returns the templates bundled with the binary.
+func DefaultTemplates(defaultLocale string) (*Templates, error) {
+	sub, err := fs.Sub(embedded, "templates")
+	if err != nil {
+		return nil, err
+	}
+	return NewTemplates(sub, defaultLocale)
+}
+
+func NewTemplates(fsys fs.FS, defaultLocale string) (*Templates, error) {
+	t := &Templates{
+		defaultLocale: normalizeLocale(defaultLocale),
+		text:          make(map[string]*texttemplate.Template),
+		html:          make(map[string]*htmltemplate.Template),
+	}
+
+	err := fs.WalkDir(fsys, ".", func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		locale := normalizeLocale(path.Dir(file))
+		base := path.Base(file)
+		switch ext := path.Ext(base); ext {
+		case ".txt":
+			tmpl, err := texttemplate.New(base).ParseFS(fsys, file)
+			if err != nil {
+				return err
+			}
+			t.text[templateKey(locale, strings.TrimSuffix(base, ext))] = tmpl
+		case ".html":
+			tmpl, err := htmltemplate.New(base).ParseFS(fsys, file)
+			if err != nil {
+				return err
+			}
+			t.html[templateKey(locale, strings.TrimSuffix(base, ext))] = tmpl
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Render executes the named template in the best matching locale: the exact
+// locale ("th-th"), then its language ("th"), then the default locale.
+func (t *Templates) Render(name, locale string, data any) (*Message, error) {
+	for _, candidate := range t.candidates(locale) {
+		key := templateKey(candidate, name)
+		text, ok := t.text[key]
+		if !ok {
+			continue
+		}
+
+		msg := &Message{}
+		var buf bytes.Buffer
+		if text.Lookup("subject") != nil {
+			if err := text.ExecuteTemplate(&buf, "subject", data); err != nil {
+				return nil, err
+			}
+			msg.Subject = strings.TrimSpace(buf.String())
+			buf.Reset()
+		}
+
+		if err := text.Execute(&buf, data); err != nil {
+			return nil, err
+		}
+		msg.Text = strings.TrimSpace(buf.String()) + "\n"
+
+		if html, ok := t.html[key]; ok {
+			buf.Reset()
+			if err := html.Execute(&buf, data); err != nil {
+				return nil, err
+			}
+			msg.HTML = buf.String()
+		}
+		return msg, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+}
+
+func (t *Templates) candidates(locale string) []string {
+	locale = normalizeLocale(locale)
+	candidates := []string{locale}
+	if lang, _, ok := strings.Cut(locale, "-"); ok {
+		candidates = append(candidates, lang)
+	}
+	return append(candidates, t.defaultLocale)
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+func templateKey(locale, name string) string {
+	return locale + "/" + name
+}
@@ -0,0 +1,231 @@
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	mailer "github.com/ritchie-gr8/7solution-be/internal/mail"
+)
+
+// smtpStandIn accepts a single SMTP session and records the envelope and the
+// message data. It implements just enough of RFC 5321 for net/smtp.
+type smtpStandIn struct {
+	listener net.Listener
+	from     string
+	to       []string
+	data     chan []byte
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	s := &smtpStandIn{listener: listener, data: make(chan []byte, 1)}
+	go s.serve()
+	return s
+}
+
+func (s *smtpStandIn) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpStandIn) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 localhost ESMTP stand-in")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimSpace(line)
+
+		switch verb := strings.ToUpper(strings.SplitN(cmd, " ", 2)[0]); {
+		case verb == "EHLO" || verb == "HELO":
+			reply("250 localhost")
+		case strings.HasPrefix(strings.ToUpper(cmd), "MAIL FROM:"):
+			s.from = strings.Trim(cmd[len("MAIL FROM:"):], "<> ")
+			reply("250 OK")
+		case strings.HasPrefix(strings.ToUpper(cmd), "RCPT TO:"):
+			s.to = append(s.to, strings.Trim(cmd[len("RCPT TO:"):], "<> "))
+			reply("250 OK")
+		case verb == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data bytes.Buffer
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			s.data <- data.Bytes()
+			reply("250 OK")
+		case verb == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+// parts returns the bodies of a multipart/alternative message by content type.
+func parts(t *testing.T, msg *mail.Message) map[string]string {
+	t.Helper()
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Expected multipart/alternative, got %q (%v)", mediaType, err)
+	}
+
+	bodies := make(map[string]string)
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return bodies
+		}
+		if err != nil {
+			t.Fatalf("Failed to read part: %v", err)
+		}
+		body, _ := io.ReadAll(part)
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		bodies[contentType] = string(body)
+	}
+}
+
+func TestSMTPMailer(t *testing.T) {
+	server := newSMTPStandIn(t)
+	m := mailer.NewSMTPMailer("127.0.0.1", server.port(), "", "", "App <no-reply@example.com>")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := m.Send(ctx, &mailer.Message{
+		To:      []string{"john@example.com"},
+		Subject: "ตั้งรหัสผ่านใหม่",
+		Text:    "Reset: https://app.example.com/reset?token=abc",
+		HTML:    `<a href="https://app.example.com/reset?token=abc">Reset</a>`,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	var data []byte
+	select {
+	case data = <-server.data:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the stand-in to receive a message")
+	}
+
+	if server.from != "no-reply@example.com" || len(server.to) != 1 || server.to[0] != "john@example.com" {
+		t.Errorf("Unexpected envelope: from %q to %v", server.from, server.to)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to parse message: %v", err)
+	}
+
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if subject != "ตั้งรหัสผ่านใหม่" {
+		t.Errorf("Expected the subject to survive encoding, got %q", subject)
+	}
+
+	// multipart.Reader undoes the quoted-printable transfer encoding.
+	bodies := parts(t, msg)
+	if bodies["text/plain"] != "Reset: https://app.example.com/reset?token=abc" {
+		t.Errorf("Unexpected text part: %q", bodies["text/plain"])
+	}
+	if bodies["text/html"] != `<a href="https://app.example.com/reset?token=abc">Reset</a>` {
+		t.Errorf("Unexpected html part: %q", bodies["text/html"])
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m, err := mailer.NewFileMailer(dir, "no-reply@example.com")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if err := m.Send(context.Background(), &mailer.Message{To: []string{"john@example.com"}, Subject: "Hi", Text: "Hello"}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one .eml file, got %d", len(files))
+	}
+	data, _ := os.ReadFile(files[0])
+	if !bytes.Contains(data, []byte("To: john@example.com")) {
+		t.Errorf("Expected the recipient header, got:\n%s", data)
+	}
+
+	if err := m.Send(context.Background(), &mailer.Message{Subject: "Hi", Text: "Hello"}); !errors.Is(err, mailer.ErrNoRecipients) {
+		t.Errorf("Expected mail.ErrNoRecipients, got: %v", err)
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	fsys := fstest.MapFS{
+		"en/welcome.txt":  {Data: []byte(`{{define "subject"}}Welcome {{.}}{{end}}Hello {{.}}`)},
+		"en/welcome.html": {Data: []byte(`<p>Hello {{.}}</p>`)},
+		"th/welcome.txt":  {Data: []byte(`{{define "subject"}}ยินดีต้อนรับ {{.}}{{end}}สวัสดี {{.}}`)},
+	}
+
+	templates, err := mailer.NewTemplates(fsys, "en")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	msg, err := templates.Render("welcome", "th-TH", "<John>")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if msg.Subject != "ยินดีต้อนรับ <John>" || msg.HTML != "" {
+		t.Errorf("Expected the Thai text-only variant, got %+v", msg)
+	}
+
+	msg, _ = templates.Render("welcome", "fr", "<John>")
+	if msg.Subject != "Welcome <John>" || msg.HTML != "<p>Hello &lt;John&gt;</p>" {
+		t.Errorf("Expected the escaped default variant, got %+v", msg)
+	}
+
+	if _, err := templates.Render("missing", "en", nil); !errors.Is(err, mailer.ErrTemplateNotFound) {
+		t.Errorf("Expected mail.ErrTemplateNotFound, got: %v", err)
+	}
+
+	if _, err := mailer.DefaultTemplates("en"); err != nil {
+		t.Errorf("Expected the bundled templates to parse, got: %v", err)
+	}
+}
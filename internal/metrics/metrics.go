@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Login results recorded by RecordLogin.
+const (
+	LoginSuccess     = "success"
+	LoginFailure     = "failure"
+	LoginLocked      = "locked"
+	LoginMFARequired = "mfa_required"
+)
+
+// Registry holds every metric of the API. It is separate from the default
+// registry so nothing a dependency registers ends up on /metrics by accident.
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = promauto.With(Registry).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method, route and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	MongoOperationDuration = promauto.With(Registry).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mongo_operation_duration_seconds",
+		Help:    "MongoDB operation latency by collection and operation.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"collection", "operation"})
+
+	MongoOperationErrors = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Name: "mongo_operation_errors_total",
+		Help: "Failed MongoDB operations by collection and operation. A missing document is not an error.",
+	}, []string{"collection", "operation"})
+
+	BcryptDuration = promauto.With(Registry).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "bcrypt_duration_seconds",
+		Help:    "Time spent hashing and comparing passwords.",
+		Buckets: []float64{.01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation"})
+
+	Logins = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Name: "user_logins_total",
+		Help: "Login attempts by result: success, failure, locked or mfa_required.",
+	}, []string{"result"})
+
+	Users = promauto.With(Registry).NewGauge(prometheus.GaugeOpts{
+		Name: "users",
+		Help: "Number of user accounts, refreshed by the user count monitor.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the registry in the Prometheus text format.
+func Handler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+}
+
+func ObserveMongo(collection, operation string, start time.Time, err error) {
+	MongoOperationDuration.WithLabelValues(collection, operation).Observe(time.Since(start).Seconds())
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		MongoOperationErrors.WithLabelValues(collection, operation).Inc()
+	}
+}
+
+func ObserveBcrypt(operation string, start time.Time) {
+	BcryptDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+func RecordLogin(result string) {
+	Logins.WithLabelValues(result).Inc()
+}
@@ -0,0 +1,70 @@
+package test
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ritchie-gr8/7solution-be/internal/metrics"
+	"github.com/ritchie-gr8/7solution-be/internal/middleware"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func scrape(t *testing.T, app *fiber.App) string {
+	t.Helper()
+
+	res, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/metrics", nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	return string(body)
+}
+
+func TestHTTPMetrics(t *testing.T) {
+	app := fiber.New()
+	app.Use(middleware.Metrics())
+	app.Get("/metrics", metrics.Handler())
+	app.Get("/widgets/:id", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+
+	for _, path := range []string{"/widgets/1", "/widgets/2", "/no-such-route"} {
+		if _, err := app.Test(httptest.NewRequest(fiber.MethodGet, path, nil)); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+	}
+
+	body := scrape(t, app)
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="/widgets/:id",status="200"} 2`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/widgets/:id",status="200"} 2`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected the scrape to contain %q", want)
+		}
+	}
+}
+
+func TestMongoMetrics(t *testing.T) {
+	app := fiber.New()
+	app.Get("/metrics", metrics.Handler())
+
+	metrics.ObserveMongo("widgets", "find_one", time.Now(), mongo.ErrNoDocuments)
+	metrics.ObserveMongo("widgets", "insert_one", time.Now(), errors.New("connection reset"))
+
+	body := scrape(t, app)
+	if !strings.Contains(body, `mongo_operation_duration_seconds_count{collection="widgets",operation="find_one"} 1`) {
+		t.Error("Expected the find_one latency to be recorded")
+	}
+	if strings.Contains(body, `mongo_operation_errors_total{collection="widgets",operation="find_one"}`) {
+		t.Error("Expected a missing document not to count as an error")
+	}
+	if !strings.Contains(body, `mongo_operation_errors_total{collection="widgets",operation="insert_one"} 1`) {
+		t.Error("Expected the insert_one error to be counted")
+	}
+}
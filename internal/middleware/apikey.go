@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/ritchie-gr8/7solution-be/internal/auth"
+)
+
+// APIKey stores the hash of the key sent in header as "apiKey" when it is one
+// of the registered keys, so the rate limiter can count the request against
+// the key. Unknown keys are ignored and the request is counted per IP, or any
+// made-up key would get a budget of its own.
+func APIKey(header string, keys []string) fiber.Handler {
+	registered := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		registered[auth.HashToken(key)] = struct{}{}
+	}
+
+	return func(c *fiber.Ctx) error {
+		if key := c.Get(header); key != "" {
+			if hash := auth.HashToken(key); hasKey(registered, hash) {
+				c.Locals("apiKey", hash)
+			}
+		}
+		return c.Next()
+	}
+}
+
+func hasKey(keys map[string]struct{}, hash string) bool {
+	_, ok := keys[hash]
+	return ok
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ritchie-gr8/7solution-be/pkg/response"
+)
+
+// ErrorCatalog covers the errors the middleware leaves to handlers and
+// services, such as a request running out of time.
+var ErrorCatalog = response.Catalog{
+	{Err: context.DeadlineExceeded, Status: fiber.StatusServiceUnavailable, Code: "request_timeout", Title: "Request Timeout", Detail: "The request took too long to process. Please try again."},
+}
+
+// RequestDeadline cancels the user context once the request has taken
+// timeout. Handlers pass c.UserContext() on to the services, so database
+// calls give up instead of piling up behind a slow dependency.
+func RequestDeadline(timeout time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, cancel := context.WithTimeout(c.UserContext(), timeout)
+		defer cancel()
+
+		c.SetUserContext(ctx)
+		return c.Next()
+	}
+}
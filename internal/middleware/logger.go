@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/ritchie-gr8/7solution-be/internal/logging"
+)
+
+// maxRequestIdLength bounds a client supplied X-Request-ID.
+const maxRequestIdLength = 128
+
+// RequestLogger gives every request a logger carrying its request id, taken
+// from X-Request-ID or generated, and writes one access log entry per request
+// once it is answered. Request headers are only logged at debug level.
+func RequestLogger(logger *slog.Logger) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+
+		requestId := c.Get(fiber.HeaderXRequestID)
+		if requestId == "" || len(requestId) > maxRequestIdLength {
+			requestId = utils.UUIDv4()
+		}
+		c.Set(fiber.HeaderXRequestID, requestId)
+		c.SetUserContext(logging.WithLogger(c.UserContext(), logger.With("request_id", requestId)))
+
+		err := handleError(c, c.Next())
+
+		status, route := responseStatus(c)
+		attrs := []slog.Attr{
+			slog.String("method", c.Method()),
+			slog.String("path", c.Path()),
+			slog.String("route", route),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("ip", c.IP()),
+			slog.Int("bytes", len(c.Response().Body())),
+			slog.String("user_agent", c.Get(fiber.HeaderUserAgent)),
+		}
+		if userId, ok := c.Locals("userId").(string); ok && userId != "" {
+			attrs = append(attrs, slog.String("user_id", userId))
+		}
+		if handlerErr := handlerError(c); handlerErr != nil && status >= fiber.StatusInternalServerError {
+			attrs = append(attrs, slog.String("error", handlerErr.Error()))
+		}
+
+		ctx := c.UserContext()
+		if logger.Enabled(ctx, slog.LevelDebug) {
+			headers := make([]any, 0)
+			c.Request().Header.VisitAll(func(key, value []byte) {
+				headers = append(headers, slog.String(string(key), string(value)))
+			})
+			attrs = append(attrs, slog.Group("headers", headers...))
+		}
+
+		logging.FromContext(ctx).LogAttrs(ctx, accessLevel(status), "request", attrs...)
+		return err
+	}
+}
+
+func accessLevel(status int) slog.Level {
+	switch {
+	case status >= fiber.StatusInternalServerError:
+		return slog.LevelError
+	case status >= fiber.StatusBadRequest:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
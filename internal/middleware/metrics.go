@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/ritchie-gr8/7solution-be/internal/metrics"
+)
+
+// Metrics records the count and latency of every request. Routes are labelled
+// by their pattern, e.g. /v1/users/:id, not by the requested path.
+func Metrics() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := handleError(c, c.Next())
+
+		status, route := responseStatus(c)
+		// Prometheus keeps the label values, and c.Method() points into a
+		// buffer Fiber reuses for the next request.
+		labels := []string{utils.CopyString(c.Method()), route, strconv.Itoa(status)}
+		metrics.HTTPRequests.WithLabelValues(labels...).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/ritchie-gr8/7solution-be/internal/auth"
+	"github.com/ritchie-gr8/7solution-be/pkg/response"
+)
+
+// RequirePermission rejects the request unless the token carries every listed
+// permission. It has to run after ValidateToken.
+func RequirePermission(permissions ...auth.Permission) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		granted, _ := c.Locals("permissions").([]auth.Permission)
+		for _, permission := range permissions {
+			if !auth.HasPermission(granted, permission) {
+				return response.NewResponse(c).Error(fiber.StatusForbidden, "Forbidden").Response()
+			}
+		}
+
+		return c.Next()
+	}
+}
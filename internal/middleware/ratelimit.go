@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ritchie-gr8/7solution-be/internal/logging"
+	"github.com/ritchie-gr8/7solution-be/internal/ratelimit"
+	"github.com/ritchie-gr8/7solution-be/pkg/response"
+)
+
+// RateLimit counts every request against the policy's key and answers 429
+// once the limit is used up. The RateLimit-* headers follow the IETF
+// RateLimit header fields draft. If the store fails, the request is let
+// through rather than taking the API down with it.
+func RateLimit(store ratelimit.IStore, policy ratelimit.Policy) fiber.Handler {
+	if !policy.Limit.Enabled() {
+		return func(c *fiber.Ctx) error { return c.Next() }
+	}
+
+	policyHeader := fmt.Sprintf("%d;w=%d", policy.Limit.Requests, int(policy.Limit.Window.Seconds()))
+
+	return func(c *fiber.Ctx) error {
+		result, err := store.Allow(c.UserContext(), policy.Name+":"+policy.Key(c), policy.Limit)
+		if err != nil {
+			logging.FromCtx(c).Error("rate limit failed", "policy", policy.Name, "error", err)
+			return c.Next()
+		}
+
+		c.Set("RateLimit-Policy", policyHeader)
+		c.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+		if !result.Allowed {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(max(1, ceilSeconds(result.RetryAfter))))
+			return response.NewResponse(c).Error(fiber.StatusTooManyRequests, "Too many requests. Please try again later.").Response()
+		}
+		return c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// unmatchedRoute labels requests no route matched, so scanners probing random
+// paths cannot blow up the number of series.
+const unmatchedRoute = "unmatched"
+
+// handleError renders a handler error with the app's error handler right
+// away, so the response status is final when it is recorded. Fiber would
+// only do so after the whole middleware chain returned. The error is kept in
+// c.Locals("error") for middleware further out, which sees nil instead.
+func handleError(c *fiber.Ctx, err error) error {
+	if err == nil {
+		return nil
+	}
+	c.Locals("error", err)
+	if err := c.App().ErrorHandler(c, err); err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	return nil
+}
+
+// handlerError is the error handleError rendered, if any.
+func handlerError(c *fiber.Ctx) error {
+	err, _ := c.Locals("error").(error)
+	return err
+}
+
+// responseStatus is the status and route pattern the request was answered
+// with. It has to run after handleError.
+func responseStatus(c *fiber.Ctx) (int, string) {
+	status, route := c.Response().StatusCode(), c.Route().Path
+	// The router answers a path without a route with a 404 fiber.Error,
+	// handlers return catalog errors instead.
+	var fiberErr *fiber.Error
+	if errors.As(handlerError(c), &fiberErr) && fiberErr.Code == fiber.StatusNotFound {
+		route = unmatchedRoute
+	}
+	return status, route
+}
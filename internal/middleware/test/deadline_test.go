@@ -0,0 +1,61 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ritchie-gr8/7solution-be/internal/middleware"
+	"github.com/ritchie-gr8/7solution-be/pkg/response"
+)
+
+func TestRequestDeadline(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: response.ErrorHandler(middleware.ErrorCatalog)})
+	app.Use(middleware.RequestDeadline(10 * time.Millisecond))
+	app.Get("/slow", func(c *fiber.Ctx) error {
+		// Stands in for a service waiting on the database.
+		<-c.UserContext().Done()
+		return c.UserContext().Err()
+	})
+
+	res, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/slow", nil), -1)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	var body response.ErrorResponse
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if res.StatusCode != fiber.StatusServiceUnavailable || body.Code != "request_timeout" {
+		t.Errorf("Expected a 503 request_timeout, got: %d %+v", res.StatusCode, body)
+	}
+}
+
+func TestRequestDeadlineKeepsContextValues(t *testing.T) {
+	type key struct{}
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.SetUserContext(context.WithValue(c.UserContext(), key{}, "span"))
+		return c.Next()
+	})
+	app.Use(middleware.RequestDeadline(time.Minute))
+	app.Get("/", func(c *fiber.Ctx) error {
+		if _, ok := c.UserContext().Deadline(); !ok || c.UserContext().Value(key{}) != "span" {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	res, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if res.StatusCode != fiber.StatusOK {
+		t.Errorf("Expected the handler to see a deadline and the outer context values, got: %d", res.StatusCode)
+	}
+}
@@ -0,0 +1,47 @@
+package test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ritchie-gr8/7solution-be/internal/auth"
+	"github.com/ritchie-gr8/7solution-be/internal/middleware"
+)
+
+func TestRequirePermission(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		granted any
+		want    int
+	}{
+		{"no permissions", nil, fiber.StatusForbidden},
+		{"empty permissions", []auth.Permission{}, fiber.StatusForbidden},
+		{"partial grant", []auth.Permission{auth.PermissionUsersWrite}, fiber.StatusForbidden},
+		{"full grant", []auth.Permission{auth.PermissionUsersRead, auth.PermissionUsersWrite, auth.PermissionUsersManage}, fiber.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Use(func(c *fiber.Ctx) error {
+				if tc.granted != nil {
+					c.Locals("permissions", tc.granted)
+				}
+				return c.Next()
+			})
+
+			called := false
+			app.Get("/", middleware.RequirePermission(auth.PermissionUsersWrite, auth.PermissionUsersManage), func(c *fiber.Ctx) error {
+				called = true
+				return c.SendStatus(fiber.StatusOK)
+			})
+
+			res, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil), -1)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if res.StatusCode != tc.want || called != (tc.want == fiber.StatusOK) {
+				t.Errorf("Expected status %d, got: %d (next called: %v)", tc.want, res.StatusCode, called)
+			}
+		})
+	}
+}
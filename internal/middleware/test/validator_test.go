@@ -0,0 +1,97 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ritchie-gr8/7solution-be/internal/middleware"
+	"github.com/ritchie-gr8/7solution-be/internal/users"
+	"github.com/ritchie-gr8/7solution-be/pkg/response"
+)
+
+func newApp() *fiber.App {
+	app := fiber.New()
+	app.Post("/users", middleware.ValidateRequest[users.CreateUserRequest](), func(c *fiber.Ctx) error {
+		return c.JSON(c.Locals("body").(*users.CreateUserRequest))
+	})
+	return app
+}
+
+// post is safe to call from other goroutines than the test's.
+func post(t *testing.T, app *fiber.App, body string) (int, []byte) {
+	t.Helper()
+
+	req := httptest.NewRequest(fiber.MethodPost, "/users", strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	res, err := app.Test(req)
+	if err != nil {
+		t.Errorf("Expected no error, got: %v", err)
+		return 0, nil
+	}
+
+	data, _ := io.ReadAll(res.Body)
+	return res.StatusCode, data
+}
+
+func TestValidationReportsEveryField(t *testing.T) {
+	status, body := post(t, newApp(), `{"name":"Al","email":"not-an-email"}`)
+	if status != fiber.StatusBadRequest {
+		t.Fatalf("Expected status 400, got: %d", status)
+	}
+
+	var res response.ErrorResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	want := []response.FieldError{
+		{Field: "name", Rule: "min", Param: "3", Message: "name must be at least 3 characters"},
+		{Field: "email", Rule: "email", Message: "email must be a valid email address"},
+		{Field: "password", Rule: "required", Message: "password is required"},
+	}
+	if len(res.Errors) != len(want) {
+		t.Fatalf("Expected %d field errors, got: %+v", len(want), res.Errors)
+	}
+	for i := range want {
+		if res.Errors[i] != want[i] {
+			t.Errorf("Expected %+v, got: %+v", want[i], res.Errors[i])
+		}
+	}
+}
+
+func TestValidationRejectsMalformedBody(t *testing.T) {
+	if status, _ := post(t, newApp(), `{"name":`); status != fiber.StatusBadRequest {
+		t.Errorf("Expected status 400, got: %d", status)
+	}
+}
+
+func TestValidatedBodyIsPerRequest(t *testing.T) {
+	app := newApp()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			email := fmt.Sprintf("user%d@example.com", i)
+			status, body := post(t, app, fmt.Sprintf(`{"name":"User %d","email":%q,"password":"secret123"}`, i, email))
+			if status != fiber.StatusOK {
+				t.Errorf("Expected status 200, got: %d", status)
+				return
+			}
+
+			var got users.CreateUserRequest
+			if err := json.Unmarshal(body, &got); err != nil || got.Email != email {
+				t.Errorf("Expected the handler to see %s, got: %+v", email, got)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -9,35 +9,82 @@ import (
 	"github.com/ritchie-gr8/7solution-be/pkg/response"
 )
 
-func ValidateToken(jwtAuth auth.IAuthenticator) fiber.Handler {
+func ValidateToken(jwtAuth auth.IAuthenticator, revocations auth.IRevocationStore) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
-			return response.NewResponse(c).Error(fiber.StatusUnauthorized, "", "Unauthorized").Response()
+			return response.NewResponse(c).Error(fiber.StatusUnauthorized, "Unauthorized").Response()
 		}
 
 		if !strings.HasPrefix(authHeader, "Bearer ") {
-			return response.NewResponse(c).Error(fiber.StatusUnauthorized, "", "Invalid token format").Response()
+			return response.NewResponse(c).Error(fiber.StatusUnauthorized, "Invalid token format").Response()
 		}
 
 		token := strings.TrimPrefix(authHeader, "Bearer ")
 		if token == "" {
-			return response.NewResponse(c).Error(fiber.StatusUnauthorized, "", "Missing token").Response()
+			return response.NewResponse(c).Error(fiber.StatusUnauthorized, "Missing token").Response()
 		}
 
 		jwtToken, err := jwtAuth.ValidateToken(token)
 		if err != nil {
-			return response.NewResponse(c).Error(fiber.StatusUnauthorized, "", "Invalid token").Response()
+			return response.NewResponse(c).Error(fiber.StatusUnauthorized, "Invalid token").Response()
 		}
 
 		claims, ok := jwtToken.Claims.(jwt.MapClaims)
 		if !ok {
-			return response.NewResponse(c).Error(fiber.StatusUnauthorized, "", "Invalid token claims").Response()
+			return response.NewResponse(c).Error(fiber.StatusUnauthorized, "Invalid token claims").Response()
 		}
 
 		userId := claims["sub"].(string)
+		jti, ok := claims["jti"].(string)
+		if !ok || jti == "" {
+			return response.NewResponse(c).Error(fiber.StatusUnauthorized, "Invalid token claims").Response()
+		}
+
+		issuedAt, err := auth.IssuedAt(claims)
+		if err != nil {
+			return response.NewResponse(c).Error(fiber.StatusUnauthorized, "Invalid token claims").Response()
+		}
+
+		expiresAt, err := claims.GetExpirationTime()
+		if err != nil || expiresAt == nil {
+			return response.NewResponse(c).Error(fiber.StatusUnauthorized, "Invalid token claims").Response()
+		}
+
+		revoked, err := revocations.IsRevoked(c.UserContext(), jti, userId, issuedAt)
+		if err != nil {
+			return response.NewResponse(c).Error(fiber.StatusInternalServerError, "Could not verify token").Response()
+		}
+		if revoked {
+			return response.NewResponse(c).Error(fiber.StatusUnauthorized, "Token has been revoked").Response()
+		}
+
 		c.Locals("userId", userId)
+		c.Locals("tokenId", jti)
+		c.Locals("tokenExpiresAt", expiresAt.Time)
+		c.Locals("roles", claimStrings(claims["roles"]))
+		c.Locals("permissions", claimPermissions(claims["permissions"]))
 
 		return c.Next()
 	}
 }
+
+func claimStrings(claim any) []string {
+	values, _ := claim.([]any)
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func claimPermissions(claim any) []auth.Permission {
+	values := claimStrings(claim)
+	result := make([]auth.Permission, 0, len(values))
+	for _, v := range values {
+		result = append(result, auth.Permission(v))
+	}
+	return result
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/ritchie-gr8/7solution-be/internal/tracing"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// headerCarrier adapts the request headers to the propagator.
+type headerCarrier struct {
+	c *fiber.Ctx
+}
+
+func (h headerCarrier) Get(key string) string { return h.c.Get(key) }
+func (h headerCarrier) Set(key, value string) { h.c.Request().Header.Set(key, value) }
+func (h headerCarrier) Keys() []string {
+	keys := make([]string, 0)
+	h.c.Request().Header.VisitAll(func(key, _ []byte) { keys = append(keys, string(key)) })
+	return keys
+}
+
+// Tracing starts a server span for every request, continuing the trace of an
+// incoming traceparent header. The span is put into the user context, so
+// handlers and services pass it on through c.UserContext().
+func Tracing() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), headerCarrier{c})
+		ctx, span := tracing.Start(ctx, c.Method(), trace.SpanKindServer,
+			semconv.HTTPRequestMethodKey.String(c.Method()),
+			semconv.URLPath(c.Path()),
+			semconv.ClientAddress(c.IP()),
+		)
+		c.SetUserContext(ctx)
+
+		err := handleError(c, c.Next())
+
+		// The route pattern is only known once the router has matched it.
+		status := c.Response().StatusCode()
+		span.SetName(c.Method() + " " + c.Route().Path)
+		span.SetAttributes(
+			semconv.HTTPRoute(c.Route().Path),
+			semconv.HTTPResponseStatusCode(status),
+		)
+		// A 4xx is the client's fault, not a failure of the server span.
+		var spanErr error
+		if status >= fiber.StatusInternalServerError {
+			spanErr = handlerError(c)
+		}
+		tracing.End(span, spanErr)
+		return err
+	}
+}
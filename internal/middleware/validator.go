@@ -1,33 +1,112 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"github.com/ritchie-gr8/7solution-be/pkg/response"
 )
 
-var validate = validator.New()
+var validate = newValidator()
+
+// newValidator reports fields by their JSON names, so the errors match what
+// the client sent.
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			return ""
+		case "":
+			return field.Name
+		}
+		return name
+	})
+	return v
+}
 
-func ValidateRequest(model any) fiber.Handler {
+// ValidateRequest parses the body into a new T for every request and stores
+// the *T in c.Locals("body") once it passes the validate tags. Handlers read
+// it from there instead of parsing the body again.
+func ValidateRequest[T any]() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		if err := c.BodyParser(model); err != nil {
-			return response.NewResponse(c).Error(fiber.StatusBadRequest, "", "Invalid request body").Response()
+		body := new(T)
+		if err := c.BodyParser(body); err != nil {
+			return response.NewResponse(c).Error(fiber.StatusBadRequest, "Invalid request body").Response()
 		}
 
-		if err := validate.Struct(model); err != nil {
-			validationErrors := err.(validator.ValidationErrors)
-			errorMsg := formatValidationErrors(validationErrors)
-			return response.NewResponse(c).Error(fiber.StatusBadRequest, "", errorMsg).Response()
+		if err := validate.Struct(body); err != nil {
+			var validationErrors validator.ValidationErrors
+			if !errors.As(err, &validationErrors) {
+				return response.NewResponse(c).Error(fiber.StatusInternalServerError, "Request validation failed unexpectedly").Response()
+			}
+			return response.NewResponse(c).ValidationError(fiber.StatusBadRequest, "Validation failed", formatValidationErrors(validationErrors)).Response()
 		}
 
+		c.Locals("body", body)
 		return c.Next()
 	}
 }
 
-func formatValidationErrors(errs validator.ValidationErrors) string {
-	if len(errs) > 0 {
-		err := errs[0]
-		return "Validation failed on field '" + err.Field() + "', condition: " + err.Tag()
+func formatValidationErrors(errs validator.ValidationErrors) []response.FieldError {
+	fields := make([]response.FieldError, 0, len(errs))
+	for _, err := range errs {
+		fields = append(fields, response.FieldError{
+			Field:   fieldPath(err),
+			Rule:    err.Tag(),
+			Param:   err.Param(),
+			Message: validationMessage(err),
+		})
+	}
+	return fields
+}
+
+// fieldPath drops the struct name the namespace starts with, e.g.
+// "CreateUserRequest.email" becomes "email".
+func fieldPath(err validator.FieldError) string {
+	_, path, found := strings.Cut(err.Namespace(), ".")
+	if !found {
+		return err.Field()
+	}
+	return path
+}
+
+func validationMessage(err validator.FieldError) string {
+	field := fieldPath(err)
+	switch err.Tag() {
+	case "required":
+		return field + " is required"
+	case "email":
+		return field + " must be a valid email address"
+	case "url":
+		return field + " must be a valid URL"
+	case "numeric":
+		return field + " must be numeric"
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", field, strings.ReplaceAll(err.Param(), " ", ", "))
+	case "len":
+		return field + " must be exactly " + bound(err)
+	case "min", "gte":
+		return field + " must be at least " + bound(err)
+	case "max", "lte":
+		return field + " must be at most " + bound(err)
+	}
+	return fmt.Sprintf("%s failed the %s rule", field, err.Tag())
+}
+
+// bound is the parameter of a length rule with what it counts. Numbers are
+// compared by value and have no unit.
+func bound(err validator.FieldError) string {
+	switch err.Kind() {
+	case reflect.String:
+		return err.Param() + " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return err.Param() + " items"
 	}
-	return "Validation failed"
+	return err.Param()
 }
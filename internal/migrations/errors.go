@@ -0,0 +1,10 @@
+package migrations
+
+import "errors"
+
+var (
+	ErrInvalidMigrations = errors.New("migrations: versions must be positive and strictly increasing")
+	ErrLocked            = errors.New("migrations: another process is migrating")
+	ErrIrreversible      = errors.New("migrations: migration cannot be reverted")
+	ErrUnknownVersion    = errors.New("migrations: applied version is not known to this build")
+)
@@ -0,0 +1,240 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	recordsCollection = "schema_migrations"
+	lockCollection    = "schema_migrations_lock"
+	lockId            = "migrations"
+
+	// lockTTL frees the lock of a process that died while migrating.
+	lockTTL = 10 * time.Minute
+)
+
+// Migration is one versioned change to the database. Down is nil for a
+// migration that cannot be reverted.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database) error
+	Down    func(ctx context.Context, db *mongo.Database) error
+}
+
+// Record is the schema_migrations document of an applied migration.
+type Record struct {
+	Version   int       `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"applied_at"`
+}
+
+// Status is a known migration and when it was applied, nil while pending.
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+type MongoCollection interface {
+	Find(ctx context.Context, filter any, opts ...*options.FindOptions) (*mongo.Cursor, error)
+	InsertOne(ctx context.Context, document any, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error)
+	DeleteOne(ctx context.Context, filter any, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error)
+}
+
+type IMigrator interface {
+	// Up applies the pending migrations in version order and returns the
+	// ones it applied. It stops at the first migration that fails.
+	Up(ctx context.Context) ([]Migration, error)
+	// Down reverts the last steps applied migrations, newest first.
+	Down(ctx context.Context, steps int) ([]Migration, error)
+	Status(ctx context.Context) ([]Status, error)
+}
+
+type migrator struct {
+	db         *mongo.Database
+	records    MongoCollection
+	lock       MongoCollection
+	migrations []Migration
+}
+
+// NewMigrator records applied migrations in the schema_migrations collection.
+// Only one process migrates at a time, so instances starting together do not
+// apply the same migration twice.
+func NewMigrator(db *mongo.Client, migrations []Migration) IMigrator {
+	database := db.Database("userdb")
+	return NewMigratorWithCollections(database, database.Collection(recordsCollection), database.Collection(lockCollection), migrations)
+}
+
+func NewMigratorWithCollections(db *mongo.Database, records, lock MongoCollection, migrations []Migration) IMigrator {
+	return &migrator{db: db, records: records, lock: lock, migrations: migrations}
+}
+
+func (m *migrator) Up(ctx context.Context) ([]Migration, error) {
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	unlock, err := m.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		start := time.Now()
+		if err := migration.Up(ctx, m.db); err != nil {
+			return done, fmt.Errorf("migrations: apply %d %s: %w", migration.Version, migration.Name, err)
+		}
+		record := Record{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}
+		if _, err := m.records.InsertOne(ctx, record); err != nil {
+			return done, fmt.Errorf("migrations: record %d %s: %w", migration.Version, migration.Name, err)
+		}
+
+		slog.Info("migration applied", "version", migration.Version, "name", migration.Name, "duration_ms", time.Since(start).Milliseconds())
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+func (m *migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	unlock, err := m.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// A newer build applied a migration this one knows nothing about, so
+	// reverting older ones would leave the schema out of order.
+	known := make(map[int]bool, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+	}
+	for version := range applied {
+		if !known[version] {
+			return nil, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+		}
+	}
+
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if migration.Down == nil {
+			return done, fmt.Errorf("%w: %d %s", ErrIrreversible, migration.Version, migration.Name)
+		}
+
+		start := time.Now()
+		if err := migration.Down(ctx, m.db); err != nil {
+			return done, fmt.Errorf("migrations: revert %d %s: %w", migration.Version, migration.Name, err)
+		}
+		if _, err := m.records.DeleteOne(ctx, bson.M{"_id": migration.Version}); err != nil {
+			return done, fmt.Errorf("migrations: unrecord %d %s: %w", migration.Version, migration.Name, err)
+		}
+
+		slog.Info("migration reverted", "version", migration.Version, "name", migration.Name, "duration_ms", time.Since(start).Milliseconds())
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+func (m *migrator) Status(ctx context.Context) ([]Status, error) {
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &record.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func (m *migrator) validate() error {
+	for i, migration := range m.migrations {
+		if migration.Version <= 0 || migration.Up == nil || (i > 0 && migration.Version <= m.migrations[i-1].Version) {
+			return fmt.Errorf("%w: %d %s", ErrInvalidMigrations, migration.Version, migration.Name)
+		}
+	}
+	return nil
+}
+
+func (m *migrator) applied(ctx context.Context) (map[int]Record, error) {
+	cursor, err := m.records.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []Record
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]Record, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// acquire takes the migration lock and returns the function releasing it. A
+// lock older than lockTTL is taken over.
+func (m *migrator) acquire(ctx context.Context) (func(), error) {
+	now := time.Now()
+	if _, err := m.lock.DeleteOne(ctx, bson.M{"_id": lockId, "expires_at": bson.M{"$lt": now}}); err != nil {
+		return nil, err
+	}
+
+	_, err := m.lock.InsertOne(ctx, bson.M{"_id": lockId, "locked_at": now, "expires_at": now.Add(lockTTL)})
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		// The caller's context may be done by now, the lock must still go.
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if _, err := m.lock.DeleteOne(ctx, bson.M{"_id": lockId}); err != nil {
+			slog.Warn("release migration lock failed", "error", err)
+		}
+	}, nil
+}
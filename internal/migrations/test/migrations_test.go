@@ -0,0 +1,245 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/ritchie-gr8/7solution-be/internal/migrations"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// fakeCollection keeps documents by _id and rejects a second insert of the
+// same _id like a real collection does.
+type fakeCollection struct {
+	mu   sync.Mutex
+	docs map[any]any
+}
+
+func newFakeCollection() *fakeCollection {
+	return &fakeCollection{docs: map[any]any{}}
+}
+
+func (c *fakeCollection) Find(ctx context.Context, filter any, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	docs := bson.A{}
+	for _, doc := range c.docs {
+		docs = append(docs, doc)
+	}
+	return mongo.NewCursorFromDocuments(docs, nil, nil)
+}
+
+func (c *fakeCollection) InsertOne(ctx context.Context, document any, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := idOf(document)
+	if _, ok := c.docs[id]; ok {
+		return nil, mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000}}}
+	}
+	c.docs[id] = document
+	return &mongo.InsertOneResult{InsertedID: id}, nil
+}
+
+// DeleteOne matches on _id only. Locks in the fake never expire, so a filter
+// asking for an expired lock deletes nothing.
+func (c *fakeCollection) DeleteOne(ctx context.Context, filter any, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := idOf(filter)
+	if _, expired := filter.(bson.M)["expires_at"]; expired {
+		return &mongo.DeleteResult{}, nil
+	}
+	if _, ok := c.docs[id]; !ok {
+		return &mongo.DeleteResult{}, nil
+	}
+	delete(c.docs, id)
+	return &mongo.DeleteResult{DeletedCount: 1}, nil
+}
+
+func idOf(document any) any {
+	raw, err := bson.Marshal(document)
+	if err != nil {
+		panic(err)
+	}
+	var decoded bson.M
+	if err := bson.Unmarshal(raw, &decoded); err != nil {
+		panic(err)
+	}
+	return decoded["_id"]
+}
+
+// recording returns migrations with the given versions that append to log
+// when applied or reverted.
+func recording(log *[]string, versions ...int) []migrations.Migration {
+	var list []migrations.Migration
+	for _, version := range versions {
+		name := "m" + string(rune('0'+version))
+		list = append(list, migrations.Migration{
+			Version: version,
+			Name:    name,
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				*log = append(*log, "up "+name)
+				return nil
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				*log = append(*log, "down "+name)
+				return nil
+			},
+		})
+	}
+	return list
+}
+
+func TestMigratorUpAppliesPendingInOrder(t *testing.T) {
+	ctx := context.Background()
+	var log []string
+	records := newFakeCollection()
+	migrator := migrations.NewMigratorWithCollections(nil, records, newFakeCollection(), recording(&log, 1, 2, 3))
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(applied) != 3 || !slices.Equal(log, []string{"up m1", "up m2", "up m3"}) {
+		t.Errorf("Expected all three migrations in order, got: %v", log)
+	}
+
+	applied, err = migrator.Up(ctx)
+	if err != nil || len(applied) != 0 || len(log) != 3 {
+		t.Errorf("Expected a second run to apply nothing, got: %d %v", len(applied), err)
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			t.Errorf("Expected migration %d to be applied", status.Version)
+		}
+	}
+}
+
+func TestMigratorDownRevertsNewestFirst(t *testing.T) {
+	ctx := context.Background()
+	var log []string
+	migrator := migrations.NewMigratorWithCollections(nil, newFakeCollection(), newFakeCollection(), recording(&log, 1, 2, 3))
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	log = nil
+
+	reverted, err := migrator.Down(ctx, 1)
+	if err != nil || len(reverted) != 1 || !slices.Equal(log, []string{"down m3"}) {
+		t.Fatalf("Expected only the newest migration reverted, got: %v %v", log, err)
+	}
+
+	statuses, _ := migrator.Status(ctx)
+	if statuses[1].AppliedAt == nil || statuses[2].AppliedAt != nil {
+		t.Errorf("Expected migration 3 pending and 2 applied, got: %+v", statuses)
+	}
+
+	if _, err := migrator.Up(ctx); err != nil || !slices.Equal(log, []string{"down m3", "up m3"}) {
+		t.Errorf("Expected the reverted migration to apply again, got: %v %v", log, err)
+	}
+}
+
+func TestMigratorDownIrreversible(t *testing.T) {
+	ctx := context.Background()
+	var log []string
+	list := recording(&log, 1, 2)
+	list[1].Down = nil
+	migrator := migrations.NewMigratorWithCollections(nil, newFakeCollection(), newFakeCollection(), list)
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if _, err := migrator.Down(ctx, 2); !errors.Is(err, migrations.ErrIrreversible) {
+		t.Errorf("Expected ErrIrreversible, got: %v", err)
+	}
+	if statuses, _ := migrator.Status(ctx); statuses[0].AppliedAt == nil || statuses[1].AppliedAt == nil {
+		t.Errorf("Expected both migrations to stay applied, got: %+v", statuses)
+	}
+}
+
+func TestMigratorDownUnknownVersion(t *testing.T) {
+	ctx := context.Background()
+	var log []string
+	records := newFakeCollection()
+	newer := migrations.NewMigratorWithCollections(nil, records, newFakeCollection(), recording(&log, 1, 2))
+	if _, err := newer.Up(ctx); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	older := migrations.NewMigratorWithCollections(nil, records, newFakeCollection(), recording(&log, 1))
+	if _, err := older.Down(ctx, 1); !errors.Is(err, migrations.ErrUnknownVersion) {
+		t.Errorf("Expected ErrUnknownVersion, got: %v", err)
+	}
+}
+
+func TestMigratorFailedMigrationIsNotRecorded(t *testing.T) {
+	ctx := context.Background()
+	var log []string
+	list := recording(&log, 1, 2, 3)
+	list[1].Up = func(ctx context.Context, db *mongo.Database) error { return errors.New("boom") }
+	migrator := migrations.NewMigratorWithCollections(nil, newFakeCollection(), newFakeCollection(), list)
+
+	applied, err := migrator.Up(ctx)
+	if err == nil || len(applied) != 1 || !slices.Equal(log, []string{"up m1"}) {
+		t.Fatalf("Expected to stop at the failing migration, got: %v %v", log, err)
+	}
+	if statuses, _ := migrator.Status(ctx); statuses[1].AppliedAt != nil || statuses[2].AppliedAt != nil {
+		t.Errorf("Expected migrations 2 and 3 pending, got: %+v", statuses)
+	}
+}
+
+func TestMigratorLocked(t *testing.T) {
+	ctx := context.Background()
+	lock := newFakeCollection()
+	lock.InsertOne(ctx, bson.M{"_id": "migrations"})
+
+	var log []string
+	migrator := migrations.NewMigratorWithCollections(nil, newFakeCollection(), lock, recording(&log, 1))
+	if _, err := migrator.Up(ctx); !errors.Is(err, migrations.ErrLocked) {
+		t.Errorf("Expected ErrLocked, got: %v", err)
+	}
+	if len(log) != 0 {
+		t.Errorf("Expected nothing applied while locked, got: %v", log)
+	}
+}
+
+func TestMigratorReleasesLock(t *testing.T) {
+	ctx := context.Background()
+	lock := newFakeCollection()
+	var log []string
+	migrator := migrations.NewMigratorWithCollections(nil, newFakeCollection(), lock, recording(&log, 1))
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(lock.docs) != 0 {
+		t.Errorf("Expected the lock to be released, got: %v", lock.docs)
+	}
+}
+
+func TestMigratorRejectsInvalidOrder(t *testing.T) {
+	var log []string
+	migrator := migrations.NewMigratorWithCollections(nil, newFakeCollection(), newFakeCollection(), recording(&log, 2, 1))
+	if _, err := migrator.Up(context.Background()); !errors.Is(err, migrations.ErrInvalidMigrations) {
+		t.Errorf("Expected ErrInvalidMigrations, got: %v", err)
+	}
+}
+
+func TestAllMigrationsAreValid(t *testing.T) {
+	all := migrations.All()
+	for i, migration := range all {
+		if migration.Version != i+1 || migration.Name == "" || migration.Up == nil {
+			t.Errorf("Expected migration %d to be numbered in order with a name, got: %+v", i, migration)
+		}
+	}
+}
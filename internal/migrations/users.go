@@ -0,0 +1,80 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// All returns the migrations of this build in version order. Append new ones
+// at the end and never renumber or edit one that has shipped.
+func All() []Migration {
+	return []Migration{
+		{
+			Version: 1,
+			Name:    "users_email_unique",
+			Up: createIndex("users", mongo.IndexModel{
+				Keys:    bson.D{{Key: "email", Value: 1}},
+				Options: options.Index().SetName("users_email_unique").SetUnique(true),
+			}),
+			Down: dropIndex("users", "users_email_unique"),
+		},
+		{
+			Version: 2,
+			Name:    "users_created_at",
+			Up: createIndex("users", mongo.IndexModel{
+				Keys:    bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}},
+				Options: options.Index().SetName("users_created_at"),
+			}),
+			Down: dropIndex("users", "users_created_at"),
+		},
+		{
+			// UpdateUser used to write updatedAt while everything else reads
+			// updated_at. The newer of the two wins; the old field is gone
+			// afterwards, so there is nothing to revert to.
+			Version: 3,
+			Name:    "users_backfill_updated_at",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection("users").UpdateMany(ctx,
+					bson.M{"updatedAt": bson.M{"$exists": true}},
+					mongo.Pipeline{
+						{{Key: "$set", Value: bson.M{"updated_at": bson.M{"$max": bson.A{"$updated_at", "$updatedAt"}}}}},
+						{{Key: "$unset", Value: "updatedAt"}},
+					},
+				)
+				return err
+			},
+		},
+		{
+			// Users created before optimistic concurrency control start at
+			// version 1 like new ones. Reverting keeps the versions, which
+			// older builds ignore.
+			Version: 4,
+			Name:    "users_backfill_version",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection("users").UpdateMany(ctx,
+					bson.M{"version": bson.M{"$exists": false}},
+					bson.M{"$set": bson.M{"version": 1}},
+				)
+				return err
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error { return nil },
+		},
+	}
+}
+
+func createIndex(collection string, model mongo.IndexModel) func(context.Context, *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		_, err := db.Collection(collection).Indexes().CreateOne(ctx, model)
+		return err
+	}
+}
+
+func dropIndex(collection, name string) func(context.Context, *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		_, err := db.Collection(collection).Indexes().DropOne(ctx, name)
+		return err
+	}
+}
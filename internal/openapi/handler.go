@@ -0,0 +1,35 @@
+package openapi
+
+import (
+	"bytes"
+	_ "embed"
+	"html/template"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+//go:embed ui.html
+var uiPage string
+
+var uiTemplate = template.Must(template.New("ui").Parse(uiPage))
+
+// Handler serves the document as JSON.
+func Handler(doc *Document) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.JSON(doc)
+	}
+}
+
+// UIHandler serves a Swagger UI page that loads the document from specUrl.
+// The page itself is embedded, the UI's scripts come from a CDN.
+func UIHandler(title, specUrl string) fiber.Handler {
+	var page bytes.Buffer
+	if err := uiTemplate.Execute(&page, map[string]string{"Title": title, "SpecUrl": specUrl}); err != nil {
+		panic(err)
+	}
+
+	return func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+		return c.Send(page.Bytes())
+	}
+}
@@ -0,0 +1,181 @@
+package openapi
+
+import (
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/ritchie-gr8/7solution-be/pkg/response"
+)
+
+const bearerAuth = "bearerAuth"
+
+// Route documents one registered route. Query and Request are zero values of
+// the query and body structs, Responses maps a status to a zero value of the
+// body type.
+type Route struct {
+	Summary string
+	Tag     string
+	Secured bool
+	Query   any
+	Request any
+	// OptionalRequest marks a body the route also works without.
+	OptionalRequest bool
+	Responses       map[int]any
+}
+
+// Text marks a text/plain response body.
+type Text string
+
+// HTML marks a text/html response body.
+type HTML string
+
+// OneOf is a response body that is one of several types.
+type OneOf []any
+
+// Key is how Build looks up the documentation of a route, e.g.
+// "GET /v1/users/:id".
+func Key(method, path string) string {
+	return method + " " + path
+}
+
+var pathParam = regexp.MustCompile(`:(\w+)\??`)
+
+// Build documents the registered routes. Paths, methods and path parameters
+// come from the routes, the rest from docs. Routes without docs are left out
+// and returned, so the caller can report them.
+func Build(info Info, routes []fiber.Route, docs map[string]Route) (*Document, []string) {
+	gen := newSchemaGenerator()
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]*PathItem),
+		Components: Components{
+			Schemas: gen.schemas,
+			SecuritySchemes: map[string]*SecurityScheme{
+				bearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+
+	var undocumented []string
+	for _, route := range routes {
+		// Fiber registers a HEAD route next to every GET route.
+		if route.Method == fiber.MethodHead {
+			continue
+		}
+
+		key := Key(route.Method, route.Path)
+		spec, ok := docs[key]
+		if !ok {
+			if !slices.Contains(undocumented, key) {
+				undocumented = append(undocumented, key)
+			}
+			continue
+		}
+
+		path := pathParam.ReplaceAllString(route.Path, "{$1}")
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = &PathItem{}
+			doc.Paths[path] = item
+		}
+		(*item)[strings.ToLower(route.Method)] = operation(gen, route, spec)
+	}
+
+	slices.Sort(undocumented)
+	return doc, undocumented
+}
+
+func operation(gen *schemaGenerator, route fiber.Route, spec Route) *Operation {
+	op := &Operation{
+		OperationID: operationId(route.Method, route.Path),
+		Summary:     spec.Summary,
+		Responses:   make(map[string]*Response),
+	}
+	if spec.Tag != "" {
+		op.Tags = []string{spec.Tag}
+	}
+	if spec.Secured {
+		op.Security = []map[string][]string{{bearerAuth: {}}}
+	}
+
+	for _, name := range route.Params {
+		op.Parameters = append(op.Parameters, &Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+	}
+	if spec.Query != nil {
+		op.Parameters = append(op.Parameters, queryParameters(gen, spec.Query)...)
+	}
+
+	if spec.Request != nil {
+		op.RequestBody = &RequestBody{Required: !spec.OptionalRequest, Content: jsonContent(gen.schemaOf(spec.Request))}
+	}
+
+	for status, body := range spec.Responses {
+		res := &Response{Description: utils.StatusMessage(status)}
+		switch body := body.(type) {
+		case nil:
+		case Text:
+			res.Content = map[string]MediaType{"text/plain": {Schema: &Schema{Type: "string"}}}
+		case HTML:
+			res.Content = map[string]MediaType{"text/html": {Schema: &Schema{Type: "string"}}}
+		case OneOf:
+			schema := &Schema{}
+			for _, v := range body {
+				schema.OneOf = append(schema.OneOf, gen.schemaOf(v))
+			}
+			res.Content = jsonContent(schema)
+		default:
+			res.Content = jsonContent(gen.schemaOf(body))
+		}
+		op.Responses[strconv.Itoa(status)] = res
+	}
+	op.Responses["default"] = &Response{
+		Description: "Error as RFC 7807 problem details",
+		Content:     map[string]MediaType{response.MIMEProblemJSON: {Schema: gen.schemaOf(response.ErrorResponse{})}},
+	}
+	return op
+}
+
+// queryParameters reads the fields of a struct with query tags, as parsed by
+// c.QueryParser.
+func queryParameters(gen *schemaGenerator, query any) []*Parameter {
+	t := reflect.TypeOf(query)
+	params := make([]*Parameter, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("query")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		schema := gen.schema(field.Type)
+		required := applyRules(schema, field.Tag.Get("validate"))
+		params = append(params, &Parameter{Name: name, In: "query", Required: required, Schema: schema})
+	}
+	return params
+}
+
+func jsonContent(schema *Schema) map[string]MediaType {
+	return map[string]MediaType{fiber.MIMEApplicationJSON: {Schema: schema}}
+}
+
+// operationId turns "GET /v1/users/:id/mfa" into "getV1UsersByIdMfa".
+func operationId(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, word := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '.' || r == '-' || r == '_'
+	}) {
+		if param, ok := strings.CutPrefix(word, ":"); ok {
+			b.WriteString("By")
+			word = strings.TrimSuffix(param, "?")
+		}
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return b.String()
+}
@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// bucket is the state of a token bucket. Tokens are refilled lazily from the
+// time of the last request.
+type bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+func (b *bucket) take(limit Limit, now time.Time) bool {
+	b.refill(limit, now)
+	if b.Tokens < 1 {
+		return false
+	}
+	b.Tokens--
+	return true
+}
+
+func (b *bucket) refill(limit Limit, now time.Time) {
+	capacity := bucketCapacity(limit)
+	if b.UpdatedAt.IsZero() {
+		b.Tokens = capacity
+	} else if elapsed := now.Sub(b.UpdatedAt).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(capacity, b.Tokens+elapsed*refillRate(limit))
+	}
+	b.UpdatedAt = now
+}
+
+func (b *bucket) result(limit Limit, allowed bool) *Result {
+	capacity := bucketCapacity(limit)
+	rate := refillRate(limit)
+
+	res := &Result{
+		Allowed:   allowed,
+		Limit:     int(capacity),
+		Remaining: int(b.Tokens),
+		Reset:     seconds((capacity - b.Tokens) / rate),
+	}
+	if !allowed {
+		res.RetryAfter = seconds((1 - b.Tokens) / rate)
+	}
+	return res
+}
+
+// bucketTTL is how long an empty bucket takes to fill up, after which its
+// state no longer matters.
+func bucketTTL(limit Limit) time.Duration {
+	return seconds(bucketCapacity(limit) / refillRate(limit))
+}
+
+func bucketCapacity(limit Limit) float64 {
+	if limit.Burst > 0 {
+		return float64(limit.Burst)
+	}
+	return float64(limit.Requests)
+}
+
+// refillRate is in tokens per second.
+func refillRate(limit Limit) float64 {
+	return float64(limit.Requests) / limit.Window.Seconds()
+}
+
+// window is the state of a sliding window counter: the requests of the current
+// fixed window and of the one before it.
+type window struct {
+	Start    time.Time
+	Count    int
+	Previous int
+}
+
+func (w *window) take(limit Limit, now time.Time) bool {
+	w.roll(limit, now)
+	if w.estimate(limit, now)+1 > float64(limit.Requests) {
+		return false
+	}
+	w.Count++
+	return true
+}
+
+func (w *window) roll(limit Limit, now time.Time) {
+	start := now.Truncate(limit.Window)
+	switch {
+	case w.Start.Equal(start):
+	case w.Start.Equal(start.Add(-limit.Window)):
+		w.Previous, w.Count = w.Count, 0
+	default:
+		w.Previous, w.Count = 0, 0
+	}
+	w.Start = start
+}
+
+// estimate is the number of requests in the sliding window ending at now,
+// assuming the previous window's requests were evenly spread.
+func (w *window) estimate(limit Limit, now time.Time) float64 {
+	return float64(w.Previous)*windowWeight(limit, w.Start, now) + float64(w.Count)
+}
+
+func (w *window) result(limit Limit, now time.Time, allowed bool) *Result {
+	end := w.Start.Add(limit.Window)
+	res := &Result{
+		Allowed:   allowed,
+		Limit:     limit.Requests,
+		Remaining: max(0, limit.Requests-int(math.Ceil(w.estimate(limit, now)))),
+		Reset:     end.Sub(now),
+	}
+	if allowed {
+		return res
+	}
+
+	// Once the current window alone is full, nothing helps but the next one.
+	// Otherwise wait until enough of the previous window has slid out.
+	res.RetryAfter = res.Reset
+	if w.Count+1 <= limit.Requests && w.Previous > 0 {
+		overlap := float64(limit.Requests-w.Count-1) / float64(w.Previous)
+		at := w.Start.Add(time.Duration((1 - overlap) * float64(limit.Window)))
+		res.RetryAfter = max(at.Sub(now), 0)
+	}
+	return res
+}
+
+// windowTTL keeps a window around while it can still be the previous one.
+func windowTTL(limit Limit) time.Duration {
+	return 2 * limit.Window
+}
+
+func windowWeight(limit Limit, start, now time.Time) float64 {
+	return 1 - now.Sub(start).Seconds()/limit.Window.Seconds()
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,9 @@
+package ratelimit
+
+import "errors"
+
+var (
+	ErrUnknownStore     = errors.New("ratelimit: unknown store")
+	ErrUnknownAlgorithm = errors.New("ratelimit: unknown algorithm")
+	ErrInvalidLimit     = errors.New("ratelimit: window must be positive")
+)
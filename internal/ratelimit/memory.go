@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often expired keys are dropped from the memory store.
+const sweepInterval = time.Minute
+
+type memoryEntry struct {
+	bucket    bucket
+	window    window
+	expiresAt time.Time
+}
+
+type memoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]*memoryEntry
+	nextSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryStore() IStore {
+	return NewMemoryStoreWithClock(time.Now)
+}
+
+// NewMemoryStoreWithClock lets tests control time.
+func NewMemoryStoreWithClock(now func() time.Time) IStore {
+	return &memoryStore{entries: make(map[string]*memoryEntry), now: now}
+}
+
+func (s *memoryStore) Allow(ctx context.Context, key string, limit Limit) (*Result, error) {
+	if err := validateLimit(limit); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &memoryEntry{}
+		s.entries[key] = entry
+	}
+
+	if limit.Algorithm == SlidingWindow {
+		allowed := entry.window.take(limit, now)
+		entry.expiresAt = entry.window.Start.Add(windowTTL(limit))
+		return entry.window.result(limit, now, allowed), nil
+	}
+
+	allowed := entry.bucket.take(limit, now)
+	entry.expiresAt = now.Add(bucketTTL(limit))
+	return entry.bucket.result(limit, allowed), nil
+}
+
+func (s *memoryStore) sweep(now time.Time) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+	for key, entry := range s.entries {
+		if !entry.expiresAt.After(now) {
+			delete(s.entries, key)
+		}
+	}
+	s.nextSweep = now.Add(sweepInterval)
+}
@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	databases "github.com/ritchie-gr8/7solution-be/internal/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoEntry is one rate limit key. Only the fields of the key's algorithm
+// are set.
+type mongoEntry struct {
+	Tokens    float64   `bson:"tokens"`
+	UpdatedAt time.Time `bson:"updated_at"`
+	Start     time.Time `bson:"start"`
+	Count     int       `bson:"count"`
+	Previous  int       `bson:"previous"`
+	Allowed   bool      `bson:"allowed"`
+}
+
+type mongoStore struct {
+	collection *mongo.Collection
+}
+
+// NewMongoStore shares counters across instances. Each request is a single
+// pipeline update, so concurrent requests cannot overshoot the limit.
+func NewMongoStore(db *mongo.Client) IStore {
+	database := db.Database("userdb")
+	collection := database.Collection("rate_limits")
+	databases.EnsureIndexes(collection, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return &mongoStore{collection: collection}
+}
+
+func (s *mongoStore) Allow(ctx context.Context, key string, limit Limit) (*Result, error) {
+	if err := validateLimit(limit); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	pipeline := bucketPipeline(limit, now)
+	if limit.Algorithm == SlidingWindow {
+		pipeline = windowPipeline(limit, now)
+	}
+
+	entry, err := s.update(ctx, key, pipeline)
+	// Two upserts of a new key can race, the loser retries against the
+	// document the winner inserted.
+	if mongo.IsDuplicateKeyError(err) {
+		entry, err = s.update(ctx, key, pipeline)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if limit.Algorithm == SlidingWindow {
+		w := window{Start: entry.Start, Count: entry.Count, Previous: entry.Previous}
+		return w.result(limit, now, entry.Allowed), nil
+	}
+	b := bucket{Tokens: entry.Tokens, UpdatedAt: entry.UpdatedAt}
+	return b.result(limit, entry.Allowed), nil
+}
+
+func (s *mongoStore) update(ctx context.Context, key string, pipeline bson.A) (*mongoEntry, error) {
+	var entry mongoEntry
+	err := s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": key},
+		pipeline,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&entry)
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// bucketPipeline is bucket.take as an update pipeline.
+func bucketPipeline(limit Limit, now time.Time) bson.A {
+	capacity := bucketCapacity(limit)
+	elapsed := bson.M{"$divide": bson.A{
+		bson.M{"$max": bson.A{0, bson.M{"$subtract": bson.A{now, bson.M{"$ifNull": bson.A{"$updated_at", now}}}}}},
+		1000,
+	}}
+
+	return bson.A{
+		bson.M{"$set": bson.M{
+			"tokens": bson.M{"$min": bson.A{capacity, bson.M{"$add": bson.A{
+				bson.M{"$ifNull": bson.A{"$tokens", capacity}},
+				bson.M{"$multiply": bson.A{elapsed, refillRate(limit)}},
+			}}}},
+			"updated_at": now,
+			"expires_at": now.Add(bucketTTL(limit)),
+		}},
+		bson.M{"$set": bson.M{"allowed": bson.M{"$gte": bson.A{"$tokens", 1}}}},
+		bson.M{"$set": bson.M{"tokens": bson.M{"$cond": bson.A{"$allowed", bson.M{"$subtract": bson.A{"$tokens", 1}}, "$tokens"}}}},
+	}
+}
+
+// windowPipeline is window.take as an update pipeline. Expressions within one
+// $set stage all see the document as it was before that stage.
+func windowPipeline(limit Limit, now time.Time) bson.A {
+	start := now.Truncate(limit.Window)
+	current := bson.M{"$eq": bson.A{"$start", start}}
+
+	return bson.A{
+		bson.M{"$set": bson.M{
+			"previous": bson.M{"$switch": bson.M{
+				"branches": bson.A{
+					bson.M{"case": current, "then": bson.M{"$ifNull": bson.A{"$previous", 0}}},
+					bson.M{"case": bson.M{"$eq": bson.A{"$start", start.Add(-limit.Window)}}, "then": bson.M{"$ifNull": bson.A{"$count", 0}}},
+				},
+				"default": 0,
+			}},
+			"count":      bson.M{"$cond": bson.A{current, bson.M{"$ifNull": bson.A{"$count", 0}}, 0}},
+			"start":      start,
+			"expires_at": start.Add(windowTTL(limit)),
+		}},
+		bson.M{"$set": bson.M{"allowed": bson.M{"$lte": bson.A{
+			bson.M{"$add": bson.A{bson.M{"$multiply": bson.A{"$previous", windowWeight(limit, start, now)}}, "$count", 1}},
+			limit.Requests,
+		}}}},
+		bson.M{"$set": bson.M{"count": bson.M{"$cond": bson.A{"$allowed", bson.M{"$add": bson.A{"$count", 1}}, "$count"}}}},
+	}
+}
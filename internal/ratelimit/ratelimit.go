@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ritchie-gr8/7solution-be/internal/config"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	StoreMemory = "memory"
+	StoreMongo  = "mongo"
+)
+
+type Algorithm string
+
+const (
+	// TokenBucket refills Requests tokens per Window and allows bursts of up
+	// to Burst requests.
+	TokenBucket Algorithm = "token_bucket"
+	// SlidingWindow allows Requests per Window, weighting the previous window
+	// by how much of it still overlaps the sliding one.
+	SlidingWindow Algorithm = "sliding_window"
+)
+
+func ParseAlgorithm(algorithm string) (Algorithm, error) {
+	switch a := Algorithm(algorithm); a {
+	case "":
+		return TokenBucket, nil
+	case TokenBucket, SlidingWindow:
+		return a, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownAlgorithm, algorithm)
+	}
+}
+
+type Limit struct {
+	Algorithm Algorithm
+	Requests  int
+	Window    time.Duration
+	// Burst is the bucket size of TokenBucket, Requests when zero.
+	Burst int
+}
+
+// Enabled reports whether the limit applies at all. A limit of zero requests
+// turns a policy off.
+func (l Limit) Enabled() bool {
+	return l.Requests > 0
+}
+
+// KeyFunc returns the identity a request is counted against.
+type KeyFunc func(c *fiber.Ctx) string
+
+// Policy is a limit with a name and a key. The name is part of the stored key,
+// so policies sharing a store keep separate counters.
+type Policy struct {
+	Name  string
+	Limit Limit
+	Key   KeyFunc
+}
+
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the full limit is available again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request is allowed, zero when
+	// this one was.
+	RetryAfter time.Duration
+}
+
+// IStore counts a request against key and decides whether it is allowed. A
+// denied request does not use up the limit.
+type IStore interface {
+	Allow(ctx context.Context, key string, limit Limit) (*Result, error)
+}
+
+// NewStore builds the store selected by RATE_LIMIT_STORE. The memory store is
+// per instance, deployments with several instances should use mongo.
+func NewStore(cfg config.IRateLimitConfig, db *mongo.Client) (IStore, error) {
+	switch cfg.Store() {
+	case StoreMemory, "":
+		return NewMemoryStore(), nil
+	case StoreMongo:
+		return NewMongoStore(db), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownStore, cfg.Store())
+	}
+}
+
+// KeyByIP counts requests per client IP address.
+func KeyByIP(c *fiber.Ctx) string {
+	return "ip:" + c.IP()
+}
+
+// KeyByUser counts requests per authenticated user and falls back to the IP
+// address before ValidateToken has run.
+func KeyByUser(c *fiber.Ctx) string {
+	if userId, ok := c.Locals("userId").(string); ok && userId != "" {
+		return "user:" + userId
+	}
+	return KeyByIP(c)
+}
+
+// KeyByAPIKey counts requests per API key once middleware.APIKey has found
+// it among the registered keys, and falls back to the IP address. Only a hash
+// of the key ends up in the store.
+func KeyByAPIKey(c *fiber.Ctx) string {
+	if hash, ok := c.Locals("apiKey").(string); ok && hash != "" {
+		return "key:" + hash
+	}
+	return KeyByIP(c)
+}
+
+func validateLimit(limit Limit) error {
+	if limit.Window <= 0 {
+		return ErrInvalidLimit
+	}
+	switch limit.Algorithm {
+	case TokenBucket, SlidingWindow:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownAlgorithm, limit.Algorithm)
+	}
+}
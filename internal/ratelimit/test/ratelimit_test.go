@@ -0,0 +1,150 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ritchie-gr8/7solution-be/internal/middleware"
+	"github.com/ritchie-gr8/7solution-be/internal/ratelimit"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func newClock() *clock {
+	return &clock{now: time.Date(2025, 6, 4, 10, 0, 0, 0, time.UTC)}
+}
+
+func (c *clock) Now() time.Time          { return c.now }
+func (c *clock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func allow(t *testing.T, store ratelimit.IStore, limit ratelimit.Limit) *ratelimit.Result {
+	t.Helper()
+
+	res, err := store.Allow(context.Background(), "ip:10.0.0.1", limit)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	return res
+}
+
+func TestTokenBucket(t *testing.T) {
+	clk := newClock()
+	store := ratelimit.NewMemoryStoreWithClock(clk.Now)
+	limit := ratelimit.Limit{Algorithm: ratelimit.TokenBucket, Requests: 60, Window: time.Minute, Burst: 3}
+
+	for i := 2; i >= 0; i-- {
+		if res := allow(t, store, limit); !res.Allowed || res.Remaining != i {
+			t.Fatalf("Expected the burst to be allowed with %d remaining, got %+v", i, res)
+		}
+	}
+
+	res := allow(t, store, limit)
+	if res.Allowed || res.RetryAfter != time.Second {
+		t.Fatalf("Expected a denial with a retry after 1s, got %+v", res)
+	}
+
+	clk.Advance(time.Second)
+	if res := allow(t, store, limit); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("Expected one token to be refilled, got %+v", res)
+	}
+
+	clk.Advance(time.Hour)
+	if res := allow(t, store, limit); res.Remaining != 2 {
+		t.Errorf("Expected the bucket to refill only up to the burst, got %+v", res)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	clk := newClock()
+	store := ratelimit.NewMemoryStoreWithClock(clk.Now)
+	limit := ratelimit.Limit{Algorithm: ratelimit.SlidingWindow, Requests: 4, Window: time.Minute}
+
+	for i := 0; i < 4; i++ {
+		allow(t, store, limit)
+	}
+	res := allow(t, store, limit)
+	if res.Allowed || res.RetryAfter != time.Minute {
+		t.Fatalf("Expected a denial until the next window, got %+v", res)
+	}
+
+	// A quarter into the next window, 3 of the previous 4 requests still count.
+	clk.Advance(75 * time.Second)
+	if res := allow(t, store, limit); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("Expected one request to be allowed, got %+v", res)
+	}
+
+	res = allow(t, store, limit)
+	if res.Allowed || res.RetryAfter != 15*time.Second {
+		t.Errorf("Expected to retry once another previous request slid out, got %+v", res)
+	}
+
+	clk.Advance(2 * time.Minute)
+	if res := allow(t, store, limit); !res.Allowed || res.Remaining != 3 {
+		t.Errorf("Expected a fresh window, got %+v", res)
+	}
+}
+
+func TestInvalidLimit(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+
+	_, err := store.Allow(context.Background(), "ip:10.0.0.1", ratelimit.Limit{Algorithm: "leaky", Requests: 1, Window: time.Second})
+	if !errors.Is(err, ratelimit.ErrUnknownAlgorithm) {
+		t.Errorf("Expected ratelimit.ErrUnknownAlgorithm, got: %v", err)
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	app := fiber.New()
+	app.Use(middleware.APIKey("X-API-Key", []string{"secret"}))
+	app.Use(middleware.RateLimit(store, ratelimit.Policy{
+		Name:  "test",
+		Limit: ratelimit.Limit{Algorithm: ratelimit.SlidingWindow, Requests: 2, Window: time.Hour},
+		Key:   ratelimit.KeyByAPIKey,
+	}))
+	app.Get("/", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+
+	request := func(apiKey string) (int, map[string]string) {
+		req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		headers := make(map[string]string)
+		for _, name := range []string{"RateLimit-Policy", "RateLimit-Limit", "RateLimit-Remaining", "Retry-After"} {
+			headers[name] = res.Header.Get(name)
+		}
+		return res.StatusCode, headers
+	}
+
+	status, headers := request("")
+	if status != fiber.StatusOK || headers["RateLimit-Policy"] != "2;w=3600" || headers["RateLimit-Remaining"] != "1" {
+		t.Fatalf("Unexpected first response: %d %v", status, headers)
+	}
+
+	request("")
+	status, headers = request("")
+	if status != fiber.StatusTooManyRequests || headers["Retry-After"] == "" {
+		t.Errorf("Expected 429 with Retry-After, got: %d %v", status, headers)
+	}
+
+	for _, key := range []string{"made-up-1", "made-up-2"} {
+		if status, _ := request(key); status != fiber.StatusTooManyRequests {
+			t.Errorf("Expected an unregistered API key to count against the IP, got: %d", status)
+		}
+	}
+
+	if status, _ := request("secret"); status != fiber.StatusOK {
+		t.Errorf("Expected a registered API key to have its own budget, got: %d", status)
+	}
+}
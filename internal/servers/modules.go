@@ -1,18 +1,39 @@
 package servers
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/ritchie-gr8/7solution-be/internal/auth"
+	"github.com/ritchie-gr8/7solution-be/internal/config"
+	databases "github.com/ritchie-gr8/7solution-be/internal/database"
 	"github.com/ritchie-gr8/7solution-be/internal/health"
+	"github.com/ritchie-gr8/7solution-be/internal/logging"
+	"github.com/ritchie-gr8/7solution-be/internal/mail"
 	"github.com/ritchie-gr8/7solution-be/internal/middleware"
+	"github.com/ritchie-gr8/7solution-be/internal/openapi"
+	"github.com/ritchie-gr8/7solution-be/internal/ratelimit"
 	"github.com/ritchie-gr8/7solution-be/internal/users"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// revocationCacheTTL bounds how long a logout on another instance can go
+// unnoticed by this one.
+const revocationCacheTTL = 30 * time.Second
+
 type IModuleFactory interface {
 	HealthModule()
+	AuthModule()
 	UserModule()
+	PasswordModule()
+	VerificationModule()
+	MFAModule()
+	DocsModule()
 }
 
 type moduleFactory struct {
@@ -28,25 +49,353 @@ func InitModule(r fiber.Router, server *server) IModuleFactory {
 }
 
 func (m *moduleFactory) HealthModule() {
-	healthHandler := health.NewMonitorHandler(m.server.cfg)
+	healthHandler := health.NewMonitorHandler(m.server.cfg, m.server.health)
 	m.router.Get("/health", healthHandler.HealthCheck)
+	m.router.Get("/health/live", healthHandler.Live)
+	m.router.Get("/health/ready", healthHandler.Ready)
+}
+
+func (m *moduleFactory) AuthModule() {
+	authn := m.server.authn
+	authHandler := auth.NewAuthHandler(authn.tokens, authn.jwt)
+
+	authGroup := m.router.Group("/auth")
+	authGroup.Post("/refresh", middleware.ValidateRequest[auth.RefreshTokenRequest](), authHandler.Refresh)
+	authGroup.Post("/logout", middleware.ValidateToken(authn.jwt, authn.revocations), authHandler.Logout)
+	authGroup.Post("/logout/all", middleware.ValidateToken(authn.jwt, authn.revocations), authHandler.LogoutAll)
+
+	m.server.app.Get("/.well-known/jwks.json", authHandler.JWKS)
 }
 
 func (m *moduleFactory) UserModule() {
-	jwtAuth := auth.NewJWTAuthenticator(
-		string(m.server.cfg.Jwt().SecretKey()),
-		m.server.cfg.App().Name(),
-		m.server.cfg.App().Name(),
-		time.Duration(m.server.cfg.Jwt().AccessExpiresAt())*time.Second)
-	userRepo := users.NewUserRepository(m.server.db)
-	userSvc := users.NewUserService(userRepo, jwtAuth)
+	authn := m.server.authn
+	userSvc := users.NewUserService(m.server.userRepo, authn.tokens, authn.verification, authn.throttle, authn.mfa)
 	userHandler := users.NewUserHandler(userSvc)
 
 	userGroup := m.router.Group("/users")
 	userGroup.Get("", userHandler.GetUsers)
+	userGroup.Get("/search", middleware.ValidateToken(authn.jwt, authn.revocations), m.limitUser(), middleware.RequirePermission(auth.PermissionUsersRead), userHandler.SearchUsers)
 	userGroup.Get("/:id", userHandler.GetUserById)
-	userGroup.Post("", middleware.ValidateRequest(&users.CreateUserRequest{}), userHandler.CreateUser)
-	userGroup.Put("/:id", middleware.ValidateToken(jwtAuth), middleware.ValidateRequest(&users.UpdateUserRequest{}), userHandler.UpdateUser)
-	userGroup.Delete("/:id", middleware.ValidateToken(jwtAuth), userHandler.DeleteUser)
-	userGroup.Post("/login", middleware.ValidateRequest(&users.LoginUserRequest{}), userHandler.Login)
+	userGroup.Post("", m.limitAuth("signup"), middleware.ValidateRequest[users.CreateUserRequest](), userHandler.CreateUser)
+	userGroup.Put("/:id", middleware.ValidateToken(authn.jwt, authn.revocations), m.limitUser(), middleware.RequirePermission(auth.PermissionUsersWrite), middleware.ValidateRequest[users.UpdateUserRequest](), userHandler.UpdateUser)
+	userGroup.Delete("/:id", middleware.ValidateToken(authn.jwt, authn.revocations), m.limitUser(), middleware.RequirePermission(auth.PermissionUsersWrite), userHandler.DeleteUser)
+	userGroup.Post("/:id/unlock", middleware.ValidateToken(authn.jwt, authn.revocations), m.limitUser(), middleware.RequirePermission(auth.PermissionUsersManage), userHandler.UnlockUser)
+	userGroup.Post("/login", m.limitAuth("login"), middleware.ValidateRequest[users.LoginUserRequest](), userHandler.Login)
+}
+
+func (m *moduleFactory) PasswordModule() {
+	authn := m.server.authn
+	cfg := m.server.cfg.Password()
+
+	resetUrl, err := url.Parse(cfg.ResetUrl())
+	if err != nil {
+		logging.Fatal("load password reset url failed", "error", err)
+	}
+
+	resets := users.NewMemoryPasswordResetRepository()
+	if !m.server.inMemory() {
+		resets = users.NewPasswordResetRepository(m.server.db)
+	}
+
+	passwordSvc := users.NewPasswordService(
+		m.server.userRepo,
+		resets,
+		authn.tokens,
+		authn.notifier,
+		resetUrl,
+		time.Duration(cfg.ResetExpiresAt())*time.Second)
+	passwordHandler := users.NewPasswordHandler(passwordSvc)
+
+	m.router.Put("/users/:id/password", middleware.ValidateToken(authn.jwt, authn.revocations), m.limitUser(), middleware.RequirePermission(auth.PermissionUsersWrite), middleware.ValidateRequest[users.ChangePasswordRequest](), passwordHandler.ChangePassword)
+	m.router.Post("/auth/password/forgot", m.limitAuth("password"), middleware.ValidateRequest[users.ForgotPasswordRequest](), passwordHandler.ForgotPassword)
+	m.router.Post("/auth/password/reset", m.limitAuth("password"), middleware.ValidateRequest[users.ResetPasswordRequest](), passwordHandler.ResetPassword)
+}
+
+func (m *moduleFactory) VerificationModule() {
+	verificationHandler := users.NewVerificationHandler(m.server.authn.verification)
+
+	authGroup := m.router.Group("/auth")
+	authGroup.Get("/verify-email", verificationHandler.VerifyEmail)
+	authGroup.Post("/verify-email/resend", m.limitAuth("verify-email"), middleware.ValidateRequest[users.ResendVerificationRequest](), verificationHandler.ResendVerification)
+}
+
+func (m *moduleFactory) MFAModule() {
+	authn := m.server.authn
+	mfaHandler := users.NewMFAHandler(authn.mfa)
+
+	userGroup := m.router.Group("/users")
+	userGroup.Post("/:id/mfa/enroll", middleware.ValidateToken(authn.jwt, authn.revocations), m.limitUser(), middleware.RequirePermission(auth.PermissionUsersWrite), mfaHandler.Enroll)
+	userGroup.Post("/:id/mfa/confirm", middleware.ValidateToken(authn.jwt, authn.revocations), m.limitUser(), middleware.RequirePermission(auth.PermissionUsersWrite), middleware.ValidateRequest[users.ConfirmMFARequest](), mfaHandler.Confirm)
+	userGroup.Delete("/:id/mfa", middleware.ValidateToken(authn.jwt, authn.revocations), m.limitUser(), middleware.RequirePermission(auth.PermissionUsersWrite), middleware.ValidateRequest[users.DisableMFARequest](), mfaHandler.Disable)
+
+	m.router.Post("/auth/mfa/verify", m.limitAuth("mfa"), middleware.ValidateRequest[users.VerifyMFARequest](), mfaHandler.Verify)
+}
+
+// DocsModule serves the OpenAPI document of every route registered so far, so
+// it has to come last.
+func (m *moduleFactory) DocsModule() {
+	doc := &openapi.Document{}
+	m.router.Get("/openapi.json", openapi.Handler(doc))
+	m.router.Get("/docs", openapi.UIHandler(m.server.cfg.App().Name(), "/v1/openapi.json"))
+
+	info := openapi.Info{Title: m.server.cfg.App().Name(), Version: m.server.cfg.App().Version()}
+	built, undocumented := openapi.Build(info, m.server.app.GetRoutes(true), apiDocs)
+	for _, route := range undocumented {
+		slog.Warn("route missing from the OpenAPI document", "route", route)
+	}
+	*doc = *built
+}
+
+// limitAuth limits unauthenticated routes that are worth abusing, such as
+// login and signup, per IP address. Each name gets its own budget.
+func (m *moduleFactory) limitAuth(name string) fiber.Handler {
+	limits := m.server.limits
+	return middleware.RateLimit(limits.store, ratelimit.Policy{Name: name, Limit: limits.auth, Key: ratelimit.KeyByIP})
+}
+
+// limitUser limits authenticated routes per user. It has to run after
+// ValidateToken and all routes share one budget.
+func (m *moduleFactory) limitUser() fiber.Handler {
+	limits := m.server.limits
+	return middleware.RateLimit(limits.store, ratelimit.Policy{Name: "user", Limit: limits.user, Key: ratelimit.KeyByUser})
+}
+
+// rateLimits are the store and limits shared by every module.
+type rateLimits struct {
+	store  ratelimit.IStore
+	global ratelimit.Policy
+	auth   ratelimit.Limit
+	user   ratelimit.Limit
+}
+
+func newRateLimits(s *server) *rateLimits {
+	cfg := s.cfg.RateLimit()
+
+	algorithm, err := ratelimit.ParseAlgorithm(cfg.Algorithm())
+	if err != nil {
+		logging.Fatal("load rate limit algorithm failed", "error", err)
+	}
+	if s.inMemory() && cfg.Store() == ratelimit.StoreMongo {
+		logging.Fatal("RATE_LIMIT_STORE=mongo needs MongoDB, which DB_USER_STORE=memory does not connect to")
+	}
+	store, err := ratelimit.NewStore(cfg, s.db)
+	if err != nil {
+		logging.Fatal("load rate limit store failed", "error", err)
+	}
+
+	return &rateLimits{
+		store: store,
+		global: ratelimit.Policy{
+			Name:  "global",
+			Limit: ratelimit.Limit{Algorithm: algorithm, Requests: cfg.GlobalRequests(), Window: cfg.GlobalWindow()},
+			Key:   ratelimit.KeyByAPIKey,
+		},
+		auth: ratelimit.Limit{Algorithm: algorithm, Requests: cfg.AuthRequests(), Window: cfg.AuthWindow()},
+		user: ratelimit.Limit{Algorithm: algorithm, Requests: cfg.UserRequests(), Window: cfg.UserWindow()},
+	}
+}
+
+// newUserRepository builds the repository selected by DB_USER_STORE. The
+// PostgreSQL pool is opened here, so it only exists when it is used.
+func newUserRepository(s *server) users.IUserRepository {
+	switch store := s.cfg.DB().UserStore(); store {
+	case users.StoreMongo:
+		return users.NewUserRepository(s.db)
+	case users.StorePostgres:
+		s.postgres = databases.PostgresConnect(s.cfg.DB())
+		return users.NewPostgresUserRepository(s.postgres)
+	case users.StoreMemory:
+		repo, err := users.NewMemoryUserRepository(s.cfg.DB().MemorySnapshotFile())
+		if err != nil {
+			logging.Fatal("load user store failed", "error", err)
+		}
+		s.memoryUsers = repo
+		return repo
+	default:
+		logging.Fatal("load user store failed", "error", fmt.Errorf("%w: %q", users.ErrUnknownStore, store))
+		return nil
+	}
+}
+
+// authComponents are built once per server and shared by every module, so the
+// revocation cache is not duplicated per route group.
+type authComponents struct {
+	jwt          auth.IAuthenticator
+	tokens       auth.ITokenService
+	revocations  auth.IRevocationStore
+	mailer       mail.IMailer
+	notifier     users.INotifier
+	verification users.IVerificationService
+	throttle     users.ILoginThrottle
+	mfa          users.IMFAService
+}
+
+func newAuthComponents(s *server) *authComponents {
+	mailer := newMailer(s.cfg.Mail())
+	notifier := newMailNotifier(s.cfg.Mail(), mailer)
+	verification := newVerificationService(s.cfg.EmailVerification(), s.userRepo, notifier)
+	throttleOpts := users.LoginThrottleOptions{
+		MaxAccountFailures: s.cfg.Login().MaxAccountFailures(),
+		MaxIPFailures:      s.cfg.Login().MaxIPFailures(),
+		LockoutBase:        s.cfg.Login().LockoutBase(),
+		LockoutMax:         s.cfg.Login().LockoutMax(),
+		FailureWindow:      s.cfg.Login().FailureWindow(),
+	}
+	throttle := users.NewMemoryLoginThrottle(throttleOpts)
+	if !s.inMemory() {
+		throttle = users.NewLoginThrottle(s.db, throttleOpts)
+	}
+
+	accessExpiresAt := time.Duration(s.cfg.Jwt().AccessExpiresAt()) * time.Second
+	jwtAuth := auth.NewJWTAuthenticatorWithKeys(
+		loadKeySet(s.cfg.Jwt()),
+		s.cfg.App().Name(),
+		s.cfg.App().Name(),
+		accessExpiresAt)
+	revocations := auth.NewMemoryRevocationStore(accessExpiresAt)
+	refreshTokens := auth.NewMemoryRefreshTokenRepository()
+	if !s.inMemory() {
+		revocations = auth.NewRevocationStore(s.db, accessExpiresAt, revocationCacheTTL)
+		refreshTokens = auth.NewRefreshTokenRepository(s.db)
+	}
+
+	tokens := auth.NewTokenService(
+		jwtAuth,
+		refreshTokens,
+		revocations,
+		userRoleResolver(s.userRepo, verification),
+		time.Duration(s.cfg.Jwt().RefreshExpiresAt())*time.Second)
+
+	return &authComponents{
+		jwt:          jwtAuth,
+		tokens:       tokens,
+		revocations:  revocations,
+		mailer:       mailer,
+		notifier:     notifier,
+		verification: verification,
+		throttle:     throttle,
+		mfa:          newMFAService(s.cfg.MFA(), s.userRepo, tokens, verification, throttle),
+	}
+}
+
+// userRoleResolver reads roles from the user record on every refresh, so role
+// changes, deleted accounts and verified emails take effect without waiting
+// for a new login.
+func userRoleResolver(repo users.IUserRepository, verification users.IVerificationService) auth.RoleResolver {
+	return func(ctx context.Context, userId primitive.ObjectID) ([]string, error) {
+		user, err := repo.GetUserById(ctx, userId.Hex())
+		if err != nil {
+			if errors.Is(err, users.ErrUserNotFound) {
+				return nil, auth.ErrInvalidRefreshToken
+			}
+			return nil, err
+		}
+
+		roles, err := verification.TokenRoles(user)
+		if errors.Is(err, users.ErrEmailNotVerified) {
+			return nil, auth.ErrInvalidRefreshToken
+		}
+		return roles, err
+	}
+}
+
+func newVerificationService(cfg config.IEmailVerificationConfig, repo users.IUserRepository, notifier users.INotifier) users.IVerificationService {
+	policy, err := users.ParseVerificationPolicy(cfg.Policy())
+	if err != nil {
+		logging.Fatal("load email verification policy failed", "error", err)
+	}
+	if len(cfg.Secret()) == 0 {
+		logging.Fatal("load email verification secret failed", "error", "EMAIL_VERIFICATION_SECRET is empty")
+	}
+
+	verifyUrl, err := url.Parse(cfg.Url())
+	if err != nil {
+		logging.Fatal("load email verification url failed", "error", err)
+	}
+
+	return users.NewVerificationService(
+		repo,
+		notifier,
+		cfg.Secret(),
+		verifyUrl,
+		time.Duration(cfg.ExpiresAt())*time.Second,
+		policy)
+}
+
+func newMFAService(cfg config.IMFAConfig, repo users.IUserRepository, tokens auth.ITokenService, verification users.IVerificationService, throttle users.ILoginThrottle) users.IMFAService {
+	if len(cfg.ChallengeSecret()) == 0 {
+		logging.Fatal("load mfa challenge secret failed", "error", "MFA_CHALLENGE_SECRET is empty")
+	}
+
+	return users.NewMFAService(
+		repo,
+		tokens,
+		verification,
+		throttle,
+		cfg.ChallengeSecret(),
+		cfg.Issuer(),
+		time.Duration(cfg.ChallengeExpiresAt())*time.Second)
+}
+
+// loadKeySet signs with the PEM key in JWT_SIGNING_KEY_FILE when it is set and
+// falls back to HS256 with JWT_SECRET_KEY otherwise. Keys listed in
+// JWT_VERIFICATION_KEYS are accepted too, which is how a key is rotated out.
+func loadKeySet(cfg config.IJwtConfig) *auth.KeySet {
+	signing := auth.NewHMACKey(cfg.SigningKeyId(), cfg.SecretKey())
+	if cfg.SigningKeyFile() != "" {
+		key, err := auth.LoadSigningKey(cfg.SigningKeyId(), cfg.SigningKeyFile())
+		if err != nil {
+			logging.Fatal("load signing key failed", "error", err)
+		}
+		signing = key
+	}
+
+	var verification []*auth.SigningKey
+	for kid, path := range cfg.VerificationKeyFiles() {
+		key, err := auth.LoadVerificationKey(kid, path)
+		if err != nil {
+			logging.Fatal("load verification key failed", "error", err)
+		}
+		verification = append(verification, key)
+	}
+
+	return auth.NewKeySet(signing, verification...)
+}
+
+func newMailer(cfg config.IMailConfig) mail.IMailer {
+	mailer, err := mail.New(cfg)
+	if err != nil {
+		logging.Fatal("load mailer failed", "error", err)
+	}
+	return mailer
+}
+
+func newMailNotifier(cfg config.IMailConfig, mailer mail.IMailer) users.INotifier {
+	templates, err := mail.DefaultTemplates(cfg.DefaultLocale())
+	if err != nil {
+		logging.Fatal("load mail templates failed", "error", err)
+	}
+	return users.NewMailNotifier(mailer, templates)
+}
+
+// newHealthRegistry registers the dependencies the readiness probe checks.
+// The disk check is skipped when HEALTH_DISK_MIN_FREE_MB is 0, the MongoDB
+// check when running in memory.
+func newHealthRegistry(s *server) health.IRegistry {
+	cfg := s.cfg.Health()
+
+	registry := health.NewRegistry(cfg.CheckTimeout(), cfg.CacheTTL())
+	if !s.inMemory() {
+		registry.Register(health.NewMongoChecker(s.db))
+	}
+	// Only verification and reset mails need the mailer, so an SMTP outage is
+	// reported without taking the server out of the load balancer.
+	registry.Register(health.NonCritical(health.NewMailerChecker(s.authn.mailer)))
+	if s.postgres != nil {
+		registry.Register(health.NewPostgresChecker(s.postgres))
+	}
+	if cfg.DiskMinFreeBytes() > 0 {
+		registry.Register(health.NewDiskChecker(cfg.DiskPath(), cfg.DiskMinFreeBytes()))
+	}
+	return registry
 }
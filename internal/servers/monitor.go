@@ -2,34 +2,57 @@ package servers
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"time"
 
+	"github.com/ritchie-gr8/7solution-be/internal/metrics"
 	"github.com/ritchie-gr8/7solution-be/internal/users"
 )
 
-func StartUserCountMonitor(ctx context.Context, userService users.IUserService) {
+// RunUserCountMonitor counts the users every 10 seconds until ctx is done.
+func RunUserCountMonitor(ctx context.Context, userService users.IUserService) {
 	ticker := time.NewTicker(10 * time.Second)
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				ticker.Stop()
-				return
-			case <-ticker.C:
-				countAndLogUsers(userService)
-			}
-		}
-	}()
+	defer ticker.Stop()
 
-	log.Println("User count monitor started")
+	slog.Info("user count monitor started")
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			countAndLogUsers(ctx, userService)
+		}
+	}
 }
 
-func countAndLogUsers(userService users.IUserService) {
-	ctx := context.Background()
+func countAndLogUsers(ctx context.Context, userService users.IUserService) {
 	count, err := userService.CountUsers(ctx)
 	if err != nil {
-		log.Printf("Failed to count users: %v", err)
+		slog.Error("count users failed", "error", err)
+		return
+	}
+	metrics.Users.Set(float64(count))
+	slog.Info("current user count", "count", count)
+}
+
+// RunUserSnapshots writes the in-memory users to their snapshot file every
+// interval until ctx is done. The last snapshot is written on shutdown.
+func RunUserSnapshots(ctx context.Context, repo users.IMemoryUserRepository, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := repo.Snapshot(); err != nil {
+				slog.Error("write user snapshot failed", "error", err)
+			}
+		}
 	}
-	log.Printf("Current user count: %d", count)
 }
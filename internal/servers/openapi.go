@@ -0,0 +1,195 @@
+package servers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/ritchie-gr8/7solution-be/internal/auth"
+	"github.com/ritchie-gr8/7solution-be/internal/health"
+	"github.com/ritchie-gr8/7solution-be/internal/openapi"
+	"github.com/ritchie-gr8/7solution-be/internal/users"
+	"github.com/ritchie-gr8/7solution-be/pkg/response"
+)
+
+// page is the body of a paginated response.
+type page[T any] struct {
+	Data []T               `json:"data"`
+	Meta response.PageMeta `json:"meta"`
+}
+
+type verifyEmailQuery struct {
+	Token string `query:"token" validate:"required"`
+}
+
+// apiDocs documents every registered route, keyed by openapi.Key. The paths,
+// methods and path parameters come from the router itself, so a route that is
+// registered but missing here is left out of /v1/openapi.json and logged.
+var apiDocs = map[string]openapi.Route{
+	// HealthModule
+	openapi.Key(fiber.MethodGet, "/v1/health"): {
+		Summary:   "Application name, version and overall status",
+		Tag:       "health",
+		Responses: map[int]any{fiber.StatusOK: map[string]string{}, fiber.StatusServiceUnavailable: map[string]string{}},
+	},
+	openapi.Key(fiber.MethodGet, "/v1/health/live"): {
+		Summary:   "Liveness probe",
+		Tag:       "health",
+		Responses: map[int]any{fiber.StatusOK: map[string]health.Status{}},
+	},
+	openapi.Key(fiber.MethodGet, "/v1/health/ready"): {
+		Summary:   "Readiness probe with the result of every dependency check",
+		Tag:       "health",
+		Responses: map[int]any{fiber.StatusOK: health.Report{}, fiber.StatusServiceUnavailable: health.Report{}},
+	},
+
+	// AuthModule
+	openapi.Key(fiber.MethodPost, "/v1/auth/refresh"): {
+		Summary:   "Exchange a refresh token for a new token pair",
+		Tag:       "auth",
+		Request:   auth.RefreshTokenRequest{},
+		Responses: map[int]any{fiber.StatusOK: auth.TokenPair{}},
+	},
+	openapi.Key(fiber.MethodPost, "/v1/auth/logout"): {
+		Summary:         "Revoke the current access token and optionally its refresh token",
+		Tag:             "auth",
+		Secured:         true,
+		Request:         auth.LogoutRequest{},
+		OptionalRequest: true,
+		Responses:       map[int]any{fiber.StatusOK: ""},
+	},
+	openapi.Key(fiber.MethodPost, "/v1/auth/logout/all"): {
+		Summary:   "Revoke every token of the current user",
+		Tag:       "auth",
+		Secured:   true,
+		Responses: map[int]any{fiber.StatusOK: ""},
+	},
+	openapi.Key(fiber.MethodGet, "/.well-known/jwks.json"): {
+		Summary:   "Public keys for verifying access tokens",
+		Tag:       "auth",
+		Responses: map[int]any{fiber.StatusOK: auth.JWKSet{}},
+	},
+
+	// UserModule
+	openapi.Key(fiber.MethodGet, "/v1/users"): {
+		Summary:   "List users with pagination, filtering and sorting",
+		Tag:       "users",
+		Query:     users.ListUsersQuery{},
+		Responses: map[int]any{fiber.StatusOK: page[*users.UserResponse]{}},
+	},
+	openapi.Key(fiber.MethodGet, "/v1/users/search"): {
+		Summary:   "Search users by name or email",
+		Tag:       "users",
+		Secured:   true,
+		Query:     users.SearchUsersQuery{},
+		Responses: map[int]any{fiber.StatusOK: []*users.UserSearchResponse{}},
+	},
+	openapi.Key(fiber.MethodGet, "/v1/users/:id"): {
+		Summary:   "Get a user",
+		Tag:       "users",
+		Responses: map[int]any{fiber.StatusOK: users.UserResponse{}, fiber.StatusNotModified: nil},
+	},
+	openapi.Key(fiber.MethodPost, "/v1/users"): {
+		Summary:   "Sign up",
+		Tag:       "users",
+		Request:   users.CreateUserRequest{},
+		Responses: map[int]any{fiber.StatusOK: users.UserResponseWithToken{}},
+	},
+	openapi.Key(fiber.MethodPut, "/v1/users/:id"): {
+		Summary:   "Update a user",
+		Tag:       "users",
+		Secured:   true,
+		Request:   users.UpdateUserRequest{},
+		Responses: map[int]any{fiber.StatusOK: users.UserResponseWithMessage{}},
+	},
+	openapi.Key(fiber.MethodDelete, "/v1/users/:id"): {
+		Summary:   "Delete a user",
+		Tag:       "users",
+		Secured:   true,
+		Responses: map[int]any{fiber.StatusOK: ""},
+	},
+	openapi.Key(fiber.MethodPost, "/v1/users/:id/unlock"): {
+		Summary:   "Clear the failed login counter of a locked account",
+		Tag:       "users",
+		Secured:   true,
+		Responses: map[int]any{fiber.StatusOK: ""},
+	},
+	openapi.Key(fiber.MethodPost, "/v1/users/login"): {
+		Summary:   "Log in, or get an MFA challenge when MFA is enabled",
+		Tag:       "users",
+		Request:   users.LoginUserRequest{},
+		Responses: map[int]any{fiber.StatusOK: openapi.OneOf{users.UserResponseWithToken{}, users.MFAChallenge{}}},
+	},
+
+	// PasswordModule
+	openapi.Key(fiber.MethodPut, "/v1/users/:id/password"): {
+		Summary:   "Change the password of the current user",
+		Tag:       "password",
+		Secured:   true,
+		Request:   users.ChangePasswordRequest{},
+		Responses: map[int]any{fiber.StatusOK: users.UserResponseWithToken{}},
+	},
+	openapi.Key(fiber.MethodPost, "/v1/auth/password/forgot"): {
+		Summary:   "Request a password reset link",
+		Tag:       "password",
+		Request:   users.ForgotPasswordRequest{},
+		Responses: map[int]any{fiber.StatusAccepted: ""},
+	},
+	openapi.Key(fiber.MethodPost, "/v1/auth/password/reset"): {
+		Summary:   "Set a new password with a reset token",
+		Tag:       "password",
+		Request:   users.ResetPasswordRequest{},
+		Responses: map[int]any{fiber.StatusOK: ""},
+	},
+
+	// VerificationModule
+	openapi.Key(fiber.MethodGet, "/v1/auth/verify-email"): {
+		Summary:   "Verify an email address",
+		Tag:       "verification",
+		Query:     verifyEmailQuery{},
+		Responses: map[int]any{fiber.StatusOK: ""},
+	},
+	openapi.Key(fiber.MethodPost, "/v1/auth/verify-email/resend"): {
+		Summary:   "Send a new verification link",
+		Tag:       "verification",
+		Request:   users.ResendVerificationRequest{},
+		Responses: map[int]any{fiber.StatusAccepted: ""},
+	},
+
+	// MFAModule
+	openapi.Key(fiber.MethodPost, "/v1/users/:id/mfa/enroll"): {
+		Summary:   "Start MFA enrollment",
+		Tag:       "mfa",
+		Secured:   true,
+		Responses: map[int]any{fiber.StatusOK: users.MFAEnrollmentResponse{}},
+	},
+	openapi.Key(fiber.MethodPost, "/v1/users/:id/mfa/confirm"): {
+		Summary:   "Enable MFA with a first code",
+		Tag:       "mfa",
+		Secured:   true,
+		Request:   users.ConfirmMFARequest{},
+		Responses: map[int]any{fiber.StatusOK: users.MFARecoveryCodesResponse{}},
+	},
+	openapi.Key(fiber.MethodDelete, "/v1/users/:id/mfa"): {
+		Summary:   "Disable MFA",
+		Tag:       "mfa",
+		Secured:   true,
+		Request:   users.DisableMFARequest{},
+		Responses: map[int]any{fiber.StatusOK: ""},
+	},
+	openapi.Key(fiber.MethodPost, "/v1/auth/mfa/verify"): {
+		Summary:   "Exchange an MFA challenge token and a code for tokens",
+		Tag:       "mfa",
+		Request:   users.VerifyMFARequest{},
+		Responses: map[int]any{fiber.StatusOK: users.UserResponseWithToken{}},
+	},
+
+	// DocsModule
+	openapi.Key(fiber.MethodGet, "/v1/openapi.json"): {
+		Summary:   "This document",
+		Tag:       "docs",
+		Responses: map[int]any{fiber.StatusOK: map[string]any{}},
+	},
+	openapi.Key(fiber.MethodGet, "/v1/docs"): {
+		Summary:   "Swagger UI for this document",
+		Tag:       "docs",
+		Responses: map[int]any{fiber.StatusOK: openapi.HTML("")},
+	},
+}
@@ -3,35 +3,63 @@ package servers
 import (
 	"context"
 	"encoding/json"
-	"log"
-	"os"
-	"os/signal"
+	"log/slog"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/ritchie-gr8/7solution-be/internal/auth"
 	"github.com/ritchie-gr8/7solution-be/internal/config"
+	databases "github.com/ritchie-gr8/7solution-be/internal/database"
+	"github.com/ritchie-gr8/7solution-be/internal/health"
+	"github.com/ritchie-gr8/7solution-be/internal/lifecycle"
+	"github.com/ritchie-gr8/7solution-be/internal/logging"
+	"github.com/ritchie-gr8/7solution-be/internal/metrics"
+	"github.com/ritchie-gr8/7solution-be/internal/middleware"
 	"github.com/ritchie-gr8/7solution-be/internal/users"
+	"github.com/ritchie-gr8/7solution-be/pkg/response"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type IServer interface {
+	Setup()
 	Start()
+	App() *fiber.App
+	// Metrics is the app serving /metrics on METRICS_ADDR, nil when it is
+	// not set.
+	Metrics() *fiber.App
 	GetServer() *server
 }
 
 type server struct {
-	app    *fiber.App
-	db     *mongo.Client
-	cfg    config.IConfig
-	cancel context.CancelFunc
+	app     *fiber.App
+	metrics *fiber.App
+	db      *mongo.Client
+	cfg     config.IConfig
+	authn   *authComponents
+	limits  *rateLimits
+	health  health.IRegistry
+	lc      lifecycle.IManager
+	// postgres is only connected when DB_USER_STORE is postgres.
+	postgres *pgxpool.Pool
+	// memoryUsers is only set when DB_USER_STORE is memory.
+	memoryUsers users.IMemoryUserRepository
+	userRepo    users.IUserRepository
 }
 
-func NewServer(cfg config.IConfig, db *mongo.Client) IServer {
+// NewServer registers its shutdown phases on lc: readiness, HTTP draining,
+// the metrics listener when METRICS_ADDR is set, background workers and, when users are stored in PostgreSQL or in memory,
+// closing the PostgreSQL pool or writing the last user snapshot, in that
+// order. db is nil with DB_USER_STORE=memory.
+func NewServer(cfg config.IConfig, db *mongo.Client, lc lifecycle.IManager) IServer {
+	if cfg.App().ProxyHeader() != "" && len(cfg.App().TrustedProxies()) == 0 {
+		logging.Fatal("APP_PROXY_HEADER needs APP_TRUSTED_PROXIES, or any client could set its own address")
+	}
+
 	return &server{
 		db:  db,
 		cfg: cfg,
+		lc:  lc,
 		app: fiber.New(fiber.Config{
 			AppName:      cfg.App().Name(),
 			BodyLimit:    cfg.App().BodyLimit(),
@@ -39,6 +67,13 @@ func NewServer(cfg config.IConfig, db *mongo.Client) IServer {
 			WriteTimeout: cfg.App().WriteTimeout(),
 			JSONEncoder:  json.Marshal,
 			JSONDecoder:  json.Unmarshal,
+			ErrorHandler: response.ErrorHandler(users.ErrorCatalog, auth.ErrorCatalog, middleware.ErrorCatalog),
+			// c.IP() is what the rate limits and the login throttle count
+			// per, behind a load balancer it has to be the client's address.
+			ProxyHeader:             cfg.App().ProxyHeader(),
+			EnableTrustedProxyCheck: cfg.App().ProxyHeader() != "",
+			TrustedProxies:          cfg.App().TrustedProxies(),
+			EnableIPValidation:      true,
 		}),
 	}
 }
@@ -47,52 +82,116 @@ func (s *server) GetServer() *server {
 	return s
 }
 
-func (s *server) Start() {
-	s.app.Use(logger.New(logger.Config{
-		Format:     "[${time}] | Status: ${status} | ${method} | Path: '${path}' | IP: ${ip} | Execution Time: ${latency}\n",
-		TimeFormat: "2006-01-02 15:04:05",
-		TimeZone:   "Local",
-	}))
+func (s *server) App() *fiber.App {
+	return s.app
+}
+
+func (s *server) Metrics() *fiber.App {
+	return s.metrics
+}
+
+// Setup registers the middleware, the routes and the shutdown phases without
+// serving, Start calls it.
+func (s *server) Setup() {
+	s.app.Use(middleware.RequestLogger(slog.Default()))
+
+	s.app.Use(middleware.Metrics())
+	if s.cfg.Metrics().Addr() != "" {
+		s.metrics = fiber.New(fiber.Config{AppName: s.cfg.App().Name(), DisableStartupMessage: true})
+		s.metrics.Get("/metrics", metrics.Handler())
+	}
+
+	s.app.Use(middleware.Tracing())
+	s.app.Use(middleware.RequestDeadline(s.cfg.App().RequestTimeout()))
+
+	s.limits = newRateLimits(s)
+	s.app.Use(middleware.APIKey(s.cfg.RateLimit().APIKeyHeader(), s.cfg.RateLimit().APIKeys()))
+	s.app.Use(middleware.RateLimit(s.limits.store, s.limits.global))
+
+	s.userRepo = newUserRepository(s)
+	s.authn = newAuthComponents(s)
+	s.health = newHealthRegistry(s)
+	s.app.Hooks().OnListen(func(fiber.ListenData) error {
+		s.health.SetReady(true)
+		return nil
+	})
 
 	// Set up router groups
 	v1 := s.app.Group("/v1")
 	modules := InitModule(v1, s)
 	modules.HealthModule()
+	modules.AuthModule()
 	modules.UserModule()
+	modules.PasswordModule()
+	modules.VerificationModule()
+	modules.MFAModule()
+	modules.DocsModule()
 
-	ctx, cancel := context.WithCancel(context.Background())
-	s.cancel = cancel
+	userSvc := users.NewUserService(s.userRepo, s.authn.tokens, s.authn.verification, s.authn.throttle, s.authn.mfa)
+	s.lc.Go("user count monitor", func(ctx context.Context) {
+		RunUserCountMonitor(ctx, userSvc)
+	})
+	if s.memoryUsers != nil && s.cfg.DB().MemorySnapshotFile() != "" {
+		s.lc.Go("user snapshots", func(ctx context.Context) {
+			RunUserSnapshots(ctx, s.memoryUsers, s.cfg.DB().MemorySnapshotInterval())
+		})
+	}
 
-	userRepo := users.NewUserRepository(s.db)
-	userSvc := users.NewUserService(userRepo, auth.NewJWTAuthenticator(
-		string(s.cfg.Jwt().SecretKey()),
-		s.cfg.App().Name(),
-		s.cfg.App().Name(),
-		time.Duration(s.cfg.Jwt().AccessExpiresAt())*time.Second))
-	StartUserCountMonitor(ctx, userSvc)
+	s.lc.OnShutdown("readiness", s.stopReadiness)
+	s.lc.OnShutdown("http", s.app.ShutdownWithContext)
+	if s.metrics != nil {
+		s.lc.OnShutdown("metrics", s.metrics.ShutdownWithContext)
+	}
+	s.lc.OnShutdown("workers", s.lc.StopWorkers)
+	if s.postgres != nil {
+		s.lc.OnShutdown("postgres", func(context.Context) error {
+			databases.PostgresDisconnect(s.postgres)
+			return nil
+		})
+	}
+	if s.memoryUsers != nil {
+		s.lc.OnShutdown("snapshot", func(context.Context) error {
+			return s.memoryUsers.Snapshot()
+		})
+	}
+}
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+// inMemory reports whether the server runs without MongoDB.
+func (s *server) inMemory() bool {
+	return s.cfg.DB().UserStore() == users.StoreMemory
+}
 
-	done := make(chan bool, 1)
+// Start sets up the server and serves in the background. The caller waits on
+// the lifecycle manager, which also stops the server.
+func (s *server) Start() {
+	s.Setup()
 
 	go func() {
-		log.Printf("server running on %s", s.cfg.App().Url())
+		slog.Info("server running", "url", s.cfg.App().Url())
 		if err := s.app.Listen(s.cfg.App().Url()); err != nil {
-			log.Printf("server error: %v", err)
+			s.lc.Stop(err)
 		}
-		done <- true
 	}()
 
+	if s.metrics != nil {
+		go func() {
+			slog.Info("metrics running", "url", s.cfg.Metrics().Addr())
+			if err := s.metrics.Listen(s.cfg.Metrics().Addr()); err != nil {
+				s.lc.Stop(err)
+			}
+		}()
+	}
+}
+
+// stopReadiness fails the readiness probe, then waits APP_SHUTDOWN_DELAY for
+// the load balancer to notice before connections are drained.
+func (s *server) stopReadiness(ctx context.Context) error {
+	s.health.SetReady(false)
+
 	select {
-	case <-c:
-		log.Println("shutting down server...")
-		if s.cancel != nil {
-			s.cancel()
-		}
-		s.app.Shutdown()
-		<-done
-	case <-done:
-		log.Println("server stopped")
+	case <-time.After(s.cfg.App().ShutdownDelay()):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
@@ -0,0 +1,87 @@
+package test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestUserETags(t *testing.T) {
+	srv := newMemoryServer(t)
+
+	send := func(method, path, token, body string, headers map[string]string) *http.Response {
+		t.Helper()
+		var reader io.Reader
+		if body != "" {
+			reader = strings.NewReader(body)
+		}
+		req := httptest.NewRequest(method, path, reader)
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		if token != "" {
+			req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+		}
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		res, err := srv.App().Test(req, -1)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		return res
+	}
+
+	var signup struct {
+		ID    string `json:"id"`
+		Token string `json:"token"`
+	}
+	res := send(fiber.MethodPost, "/v1/users", "", `{"name":"Alice Smith","email":"alice@example.com","password":"secret123"}`, nil)
+	json.NewDecoder(res.Body).Decode(&signup)
+	if res.StatusCode != fiber.StatusOK || signup.Token == "" {
+		t.Fatalf("Expected to sign up, got: %d", res.StatusCode)
+	}
+	path := "/v1/users/" + signup.ID
+	update := `{"name":"Alice Jones","email":"alice@example.com"}`
+
+	res = send(fiber.MethodGet, path, "", "", nil)
+	if etag := res.Header.Get(fiber.HeaderETag); etag != `"1"` {
+		t.Fatalf(`Expected ETag "1", got: %q`, etag)
+	}
+
+	res = send(fiber.MethodGet, path, "", "", map[string]string{fiber.HeaderIfNoneMatch: `W/"1"`})
+	if body, _ := io.ReadAll(res.Body); res.StatusCode != fiber.StatusNotModified || len(body) != 0 {
+		t.Errorf("Expected 304 without a body, got: %d %s", res.StatusCode, body)
+	}
+
+	res = send(fiber.MethodPut, path, signup.Token, update, map[string]string{fiber.HeaderIfMatch: `"7"`})
+	if res.StatusCode != fiber.StatusPreconditionFailed {
+		t.Errorf("Expected 412 for a stale ETag, got: %d", res.StatusCode)
+	}
+	res = send(fiber.MethodPut, path, signup.Token, update, map[string]string{fiber.HeaderIfMatch: `W/"1"`})
+	if res.StatusCode != fiber.StatusPreconditionFailed {
+		t.Errorf("Expected 412 for a weak ETag, If-Match compares strongly, got: %d", res.StatusCode)
+	}
+
+	res = send(fiber.MethodPut, path, signup.Token, update, map[string]string{fiber.HeaderIfMatch: `"1"`})
+	if res.StatusCode != fiber.StatusOK || res.Header.Get(fiber.HeaderETag) != `"2"` {
+		t.Fatalf(`Expected 200 with ETag "2", got: %d %q`, res.StatusCode, res.Header.Get(fiber.HeaderETag))
+	}
+
+	res = send(fiber.MethodGet, path, "", "", map[string]string{fiber.HeaderIfNoneMatch: `"1"`})
+	if res.StatusCode != fiber.StatusOK {
+		t.Errorf("Expected 200 once the user changed, got: %d", res.StatusCode)
+	}
+
+	res = send(fiber.MethodDelete, path, signup.Token, "", map[string]string{fiber.HeaderIfMatch: `"1"`})
+	if res.StatusCode != fiber.StatusPreconditionFailed {
+		t.Errorf("Expected 412 deleting a stale version, got: %d", res.StatusCode)
+	}
+	res = send(fiber.MethodDelete, path, signup.Token, "", map[string]string{fiber.HeaderIfMatch: `"5", "2"`})
+	if res.StatusCode != fiber.StatusOK {
+		t.Errorf("Expected a list of ETags including the current one to match, got: %d", res.StatusCode)
+	}
+}
@@ -0,0 +1,80 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ritchie-gr8/7solution-be/internal/config"
+	"github.com/ritchie-gr8/7solution-be/internal/lifecycle"
+	"github.com/ritchie-gr8/7solution-be/internal/servers"
+)
+
+// newMemoryServer sets up a server in memory mode whose users may log in
+// without verifying their email.
+func newMemoryServer(t *testing.T) servers.IServer {
+	t.Helper()
+	return newMemoryServerWithEnv(t, "")
+}
+
+// newMemoryServerWithEnv adds the variables in env, one per line, to the
+// memory server's config.
+func newMemoryServerWithEnv(t *testing.T, env string) servers.IServer {
+	t.Helper()
+	envPath := filepath.Join(t.TempDir(), ".env")
+	env = testEnv + "DB_USER_STORE=memory\nEMAIL_VERIFICATION_POLICY=none\nMAIL_DRIVER=file\nMAIL_FILE_DIR=" + t.TempDir() + "\n" + env
+	if err := os.WriteFile(envPath, []byte(env), 0o600); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	srv := servers.NewServer(config.LoadConfig(envPath), nil, lifecycle.NewManager(time.Second))
+	srv.Setup()
+	return srv
+}
+
+func TestServerRunsWithoutDatabase(t *testing.T) {
+	srv := newMemoryServer(t)
+
+	post := func(path, body string) *tokenResponse {
+		t.Helper()
+		req := httptest.NewRequest(fiber.MethodPost, path, strings.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		res, err := srv.App().Test(req, -1)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		var decoded tokenResponse
+		json.NewDecoder(res.Body).Decode(&decoded)
+		decoded.Status = res.StatusCode
+		return &decoded
+	}
+
+	if res := post("/v1/users", `{"name":"Alice Smith","email":"alice@example.com","password":"secret123"}`); res.Status != fiber.StatusOK {
+		t.Fatalf("Expected status 200 signing up, got: %d", res.Status)
+	}
+	if res := post("/v1/users", `{"name":"Alice Smith","email":"alice@example.com","password":"secret123"}`); res.Status != fiber.StatusConflict {
+		t.Errorf("Expected status 409 signing up twice, got: %d", res.Status)
+	}
+
+	res := post("/v1/users/login", `{"email":"alice@example.com","password":"secret123"}`)
+	if res.Status != fiber.StatusOK || res.RefreshToken == "" {
+		t.Fatalf("Expected tokens logging in, got: %d %+v", res.Status, res)
+	}
+
+	refreshed := post("/v1/auth/refresh", `{"refresh_token":"`+res.RefreshToken+`"}`)
+	if refreshed.Status != fiber.StatusOK || refreshed.RefreshToken == res.RefreshToken {
+		t.Errorf("Expected the refresh token to be stored in memory, got: %d", refreshed.Status)
+	}
+}
+
+// tokenResponse holds the fields of a login or refresh response the test
+// looks at.
+type tokenResponse struct {
+	Status       int
+	RefreshToken string `json:"refresh_token"`
+}
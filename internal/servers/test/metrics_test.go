@@ -0,0 +1,39 @@
+package test
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestMetricsAreNotOnTheAPIPort(t *testing.T) {
+	get := func(app *fiber.App, path string) (int, string) {
+		t.Helper()
+		res, err := app.Test(httptest.NewRequest(fiber.MethodGet, path, nil), -1)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(body)
+	}
+
+	srv := newMemoryServer(t)
+	if srv.Metrics() != nil {
+		t.Error("Expected no metrics listener without METRICS_ADDR")
+	}
+	if status, _ := get(srv.App(), "/metrics"); status != fiber.StatusNotFound {
+		t.Errorf("Expected /metrics not to be served on the API, got: %d", status)
+	}
+
+	srv = newMemoryServerWithEnv(t, "METRICS_ADDR=127.0.0.1:9090\n")
+	if status, _ := get(srv.App(), "/metrics"); status != fiber.StatusNotFound {
+		t.Errorf("Expected /metrics not to be served on the API, got: %d", status)
+	}
+	get(srv.App(), "/v1/health/live")
+	if status, body := get(srv.Metrics(), "/metrics"); status != fiber.StatusOK || !strings.Contains(body, "http_requests_total") {
+		t.Errorf("Expected the metrics listener to serve /metrics, got: %d %s", status, body)
+	}
+}
@@ -0,0 +1,114 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ritchie-gr8/7solution-be/internal/config"
+	"github.com/ritchie-gr8/7solution-be/internal/lifecycle"
+	"github.com/ritchie-gr8/7solution-be/internal/openapi"
+	"github.com/ritchie-gr8/7solution-be/internal/servers"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const testEnv = `APP_PORT=3000
+APP_NAME=test
+APP_VERSION=v0.0.0
+APP_BODY_LIMIT=1048576
+APP_READ_TIMEOUT=5
+APP_WRITE_TIMEOUT=5
+DB_PORT=27017
+DB_MAX_POOL_SIZE=1
+JWT_SECRET_KEY=secret
+JWT_ACCESS_EXPIRES=900
+JWT_REFRESH_EXPIRES=3600
+PASSWORD_RESET_EXPIRES=900
+PASSWORD_RESET_URL=http://localhost/reset
+EMAIL_VERIFICATION_EXPIRES=900
+EMAIL_VERIFICATION_SECRET=secret
+EMAIL_VERIFICATION_URL=http://localhost/verify
+MFA_CHALLENGE_SECRET=secret
+`
+
+// newServer sets up the routes against a MongoDB that is never reached.
+func newServer(t *testing.T) servers.IServer {
+	t.Helper()
+
+	envPath := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(envPath, []byte(testEnv), 0o600); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	db, err := mongo.Connect(context.Background(), options.Client().
+		ApplyURI("mongodb://127.0.0.1:1").
+		SetServerSelectionTimeout(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	srv := servers.NewServer(config.LoadConfig(envPath), db, lifecycle.NewManager(time.Second))
+	srv.Setup()
+	return srv
+}
+
+func TestEveryRouteIsDocumented(t *testing.T) {
+	srv := newServer(t)
+
+	res, err := srv.App().Test(httptest.NewRequest(fiber.MethodGet, "/v1/openapi.json", nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	var doc openapi.Document
+	if err := json.NewDecoder(res.Body).Decode(&doc); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	param := regexp.MustCompile(`:(\w+)`)
+	for _, route := range srv.App().GetRoutes(true) {
+		if route.Method == fiber.MethodHead {
+			continue
+		}
+
+		path := param.ReplaceAllString(route.Path, "{$1}")
+		item, ok := doc.Paths[path]
+		if !ok || (*item)[strings.ToLower(route.Method)] == nil {
+			t.Errorf("Expected %s to be in the OpenAPI document", openapi.Key(route.Method, route.Path))
+		}
+	}
+}
+
+func TestRequestSchemaFollowsValidateTags(t *testing.T) {
+	srv := newServer(t)
+
+	res, err := srv.App().Test(httptest.NewRequest(fiber.MethodGet, "/v1/openapi.json", nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	var doc openapi.Document
+	if err := json.NewDecoder(res.Body).Decode(&doc); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	schema := doc.Components.Schemas["CreateUserRequest"]
+	if schema == nil {
+		t.Fatal("Expected CreateUserRequest to be a component")
+	}
+	if len(schema.Required) != 3 {
+		t.Errorf("Expected name, email and password to be required, got: %v", schema.Required)
+	}
+	if schema.Properties["email"].Format != "email" {
+		t.Errorf("Expected email to have the email format, got: %q", schema.Properties["email"].Format)
+	}
+	if name := schema.Properties["name"]; *name.MinLength != 3 || *name.MaxLength != 50 {
+		t.Errorf("Expected name to be 3 to 50 characters, got: %d to %d", *name.MinLength, *name.MaxLength)
+	}
+}
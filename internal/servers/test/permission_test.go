@@ -0,0 +1,74 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ritchie-gr8/7solution-be/internal/auth"
+	"github.com/ritchie-gr8/7solution-be/pkg/response"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestOnlyAdminsChangeOtherAccounts(t *testing.T) {
+	srv := newMemoryServer(t)
+
+	send := func(method, path, token, body string) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+		res, err := srv.App().Test(req, -1)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		return res
+	}
+	signup := func(name, email string) (id, token string) {
+		t.Helper()
+		var body struct {
+			ID    string `json:"id"`
+			Token string `json:"token"`
+		}
+		res := send(fiber.MethodPost, "/v1/users", "", `{"name":"`+name+`","email":"`+email+`","password":"secret123"}`)
+		json.NewDecoder(res.Body).Decode(&body)
+		if res.StatusCode != fiber.StatusOK || body.Token == "" {
+			t.Fatalf("Expected to sign up, got: %d", res.StatusCode)
+		}
+		return body.ID, body.Token
+	}
+
+	aliceID, _ := signup("Alice Smith", "alice@example.com")
+	_, bobToken := signup("Bob Jones", "bob@example.com")
+
+	// Roles are only granted in the database, so the admin's token is signed
+	// with the test secret directly.
+	jwtAuth := auth.NewJWTAuthenticator("secret", "test", "test", time.Minute)
+	adminToken, err := jwtAuth.GenerateToken(jwtAuth.GenerateClaims(primitive.NewObjectID(), []string{auth.RoleAdmin}))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	path := "/v1/users/" + aliceID
+	update := `{"name":"Alice Jones","email":"alice@example.com"}`
+
+	for _, method := range []string{fiber.MethodPut, fiber.MethodDelete} {
+		res := send(method, path, bobToken, update)
+		var problem response.ErrorResponse
+		json.NewDecoder(res.Body).Decode(&problem)
+		if res.StatusCode != fiber.StatusForbidden || problem.Code != "unauthorized_access" {
+			t.Errorf("Expected %s by another user to be forbidden, got: %d %s", method, res.StatusCode, problem.Code)
+		}
+	}
+
+	if res := send(fiber.MethodPut, path, adminToken, update); res.StatusCode != fiber.StatusOK {
+		t.Errorf("Expected an admin to update another user, got: %d", res.StatusCode)
+	}
+	if res := send(fiber.MethodDelete, path, adminToken, ""); res.StatusCode != fiber.StatusOK {
+		t.Errorf("Expected an admin to delete another user, got: %d", res.StatusCode)
+	}
+}
@@ -0,0 +1,47 @@
+package test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestClientAddressBehindProxy(t *testing.T) {
+	// Requests made with App().Test come from 0.0.0.0.
+	for _, tc := range []struct {
+		name    string
+		trusted string
+		// want is the status of a second client's request once the first
+		// one has used up the limit.
+		want int
+	}{
+		{"trusted proxy", "0.0.0.0", fiber.StatusOK},
+		{"untrusted proxy", "10.0.0.0/8", fiber.StatusTooManyRequests},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newMemoryServerWithEnv(t, "RATE_LIMIT_GLOBAL_REQUESTS=1\nAPP_PROXY_HEADER=X-Real-IP\nAPP_TRUSTED_PROXIES="+tc.trusted+"\n")
+
+			live := func(client string) int {
+				t.Helper()
+				req := httptest.NewRequest(fiber.MethodGet, "/v1/health/live", nil)
+				req.Header.Set("X-Real-IP", client)
+				res, err := srv.App().Test(req, -1)
+				if err != nil {
+					t.Fatalf("Expected no error, got: %v", err)
+				}
+				return res.StatusCode
+			}
+
+			if status := live("203.0.113.1"); status != fiber.StatusOK {
+				t.Fatalf("Expected the first request to pass, got: %d", status)
+			}
+			if status := live("203.0.113.1"); status != fiber.StatusTooManyRequests {
+				t.Errorf("Expected the same client to be limited, got: %d", status)
+			}
+			if status := live("203.0.113.2"); status != tc.want {
+				t.Errorf("Expected status %d for another client, got: %d", tc.want, status)
+			}
+		})
+	}
+}
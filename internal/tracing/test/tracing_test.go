@@ -0,0 +1,82 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ritchie-gr8/7solution-be/internal/middleware"
+	"github.com/ritchie-gr8/7solution-be/pkg/response"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const (
+	traceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	traceparent = "00-" + traceID + "-00f067aa0ba902b7-01"
+)
+
+func setup(t *testing.T) (*fiber.App, *tracetest.SpanRecorder) {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	app := fiber.New()
+	app.Use(middleware.Tracing())
+	app.Get("/widgets/:id", func(c *fiber.Ctx) error {
+		return response.NewResponse(c).Error(fiber.StatusNotFound, "widget not found").Response()
+	})
+	return app, recorder
+}
+
+func TestTraceparentIsPropagated(t *testing.T) {
+	app, recorder := setup(t)
+
+	req := httptest.NewRequest(fiber.MethodGet, "/widgets/1", nil)
+	req.Header.Set("traceparent", traceparent)
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	var body response.ErrorResponse
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if body.TraceId != traceID {
+		t.Errorf("Expected trace_id %s, got: %q", traceID, body.TraceId)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected one span, got: %d", len(spans))
+	}
+	if spans[0].Name() != "GET /widgets/:id" {
+		t.Errorf("Expected the span to be named after the route, got: %q", spans[0].Name())
+	}
+	if spans[0].Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("Expected the span to continue the caller's span, got parent: %s", spans[0].Parent().SpanID())
+	}
+}
+
+func TestNewTraceWithoutTraceparent(t *testing.T) {
+	app, _ := setup(t)
+
+	res, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/widgets/1", nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	var body response.ErrorResponse
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(body.TraceId) != 32 || body.TraceId == traceID {
+		t.Errorf("Expected a fresh trace id, got: %q", body.TraceId)
+	}
+}
@@ -0,0 +1,102 @@
+package tracing
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"github.com/ritchie-gr8/7solution-be/internal/config"
+	"github.com/ritchie-gr8/7solution-be/internal/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+
+	instrumentationName = "github.com/ritchie-gr8/7solution-be"
+)
+
+// Setup installs the global tracer provider and the W3C trace context
+// propagator. With TRACING_EXPORTER=none spans are not recorded, but incoming
+// traceparent headers are still honoured so error responses carry the
+// caller's trace id. The returned provider is nil in that case.
+func Setup(app config.IAppConfig, cfg config.ITracingConfig) *sdktrace.TracerProvider {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+	switch cfg.Exporter() {
+	case ExporterNone, "":
+		return nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	case ExporterOTLP:
+		opts := []otlptracehttp.Option{}
+		if cfg.OTLPEndpoint() != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.OTLPEndpoint()))
+		}
+		if cfg.OTLPInsecure() {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(context.Background(), opts...)
+	default:
+		logging.Fatal("load tracing exporter failed", "exporter", cfg.Exporter(), "error", "unknown exporter")
+	}
+	if err != nil {
+		logging.Fatal("load tracing exporter failed", "error", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(app.Name()),
+		semconv.ServiceVersion(app.Version()),
+	))
+	if err != nil {
+		logging.Fatal("load tracing resource failed", "error", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(float64(cfg.SamplePercent())/100))),
+	)
+	otel.SetTracerProvider(tp)
+
+	slog.Info("tracing enabled", "exporter", cfg.Exporter())
+	return tp
+}
+
+// Shutdown flushes the spans that are still buffered.
+func Shutdown(ctx context.Context, tp *sdktrace.TracerProvider) error {
+	if tp == nil {
+		return nil
+	}
+	return tp.Shutdown(ctx)
+}
+
+// Start starts a span as a child of the span in ctx, if any.
+func Start(ctx context.Context, name string, kind trace.SpanKind, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+}
+
+// End records err on the span, if any, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,24 @@
+package users
+
+import (
+	"time"
+
+	"github.com/ritchie-gr8/7solution-be/internal/metrics"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func hashPassword(password string) (string, error) {
+	defer metrics.ObserveBcrypt("hash", time.Now())
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func comparePassword(hash, password string) error {
+	defer metrics.ObserveBcrypt("compare", time.Now())
+
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+}
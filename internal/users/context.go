@@ -0,0 +1,47 @@
+package users
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type clientKey struct{}
+
+// Client is what the services know about the caller besides its request.
+// Handlers fill it in from the HTTP request; other callers, such as jobs or
+// tools, may leave it out.
+type Client struct {
+	// IP is the address failed logins are counted against.
+	IP string
+	// Locale is the preferred language for messages, e.g. "th-TH". Empty
+	// means the notifier's default.
+	Locale string
+}
+
+// WithClient returns a copy of ctx carrying client.
+func WithClient(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+func clientFrom(ctx context.Context) Client {
+	client, _ := ctx.Value(clientKey{}).(Client)
+	return client
+}
+
+// requestContext is the context handlers pass to the services: the request's
+// user context, with its deadline, span and logger, plus the client.
+func requestContext(c *fiber.Ctx) context.Context {
+	return WithClient(c.UserContext(), Client{IP: c.IP(), Locale: requestLocale(c)})
+}
+
+// requestLocale returns the first language listed in Accept-Language.
+func requestLocale(c *fiber.Ctx) string {
+	first, _, _ := strings.Cut(c.Get(fiber.HeaderAcceptLanguage), ",")
+	locale, _, _ := strings.Cut(first, ";")
+	if locale = strings.TrimSpace(locale); locale == "*" {
+		return ""
+	}
+	return locale
+}
@@ -1,6 +1,11 @@
 package users
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ritchie-gr8/7solution-be/pkg/response"
+)
 
 var (
 	ErrUserNotFound       = errors.New("user: not found")
@@ -13,4 +18,54 @@ var (
 	ErrHashingPassword    = errors.New("user: could not hash password")
 	ErrGeneratingToken    = errors.New("user: could not generate token")
 	ErrInvalidID          = errors.New("user: invalid ID")
+	ErrInvalidPageSize    = errors.New("user: invalid page size")
+	ErrInvalidOffset      = errors.New("user: invalid offset")
+	ErrInvalidSort        = errors.New("user: invalid sort")
+	ErrInvalidCursor      = errors.New("user: invalid cursor")
+	ErrInvalidSearchQuery = errors.New("user: invalid search query")
+	ErrInvalidSearchMode  = errors.New("user: invalid search mode")
+	ErrIncorrectPassword  = errors.New("user: incorrect password")
+	ErrInvalidResetToken  = errors.New("user: invalid or expired password reset token")
+	ErrEmailNotVerified   = errors.New("user: email not verified")
+	ErrInvalidVerifyToken = errors.New("user: invalid or expired email verification token")
+	ErrAccountLocked      = errors.New("user: account temporarily locked")
+	ErrTooManyAttempts    = errors.New("user: too many login attempts")
+	ErrMFARequired        = errors.New("user: mfa required")
+	ErrMFAAlreadyEnabled  = errors.New("user: mfa already enabled")
+	ErrMFANotEnrolled     = errors.New("user: mfa enrollment not started")
+	ErrInvalidMFACode     = errors.New("user: invalid mfa code")
+	ErrInvalidMFAToken    = errors.New("user: invalid or expired mfa token")
+	ErrUnknownStore       = errors.New("user: unknown store")
+	ErrVersionConflict    = errors.New("user: changed concurrently")
+	ErrPreconditionFailed = errors.New("user: precondition failed")
 )
+
+// ErrorCatalog is how the errors above are shown to clients. The codes are
+// part of the API; change a detail freely, but never a code.
+var ErrorCatalog = response.Catalog{
+	{Err: ErrUserNotFound, Status: fiber.StatusNotFound, Code: "user_not_found", Title: "User Not Found", Detail: "The requested user was not found."},
+	{Err: ErrInvalidID, Status: fiber.StatusBadRequest, Code: "invalid_id", Title: "Invalid ID", Detail: "The id is not a valid user id."},
+	{Err: ErrEmailAlreadyExists, Status: fiber.StatusConflict, Code: "email_already_exists", Title: "Email Already Exists", Detail: "A user with this email already exists."},
+	{Err: ErrUnauthorizedAccess, Status: fiber.StatusForbidden, Code: "unauthorized_access", Title: "Unauthorized Access", Detail: "You may only do this for your own account."},
+	{Err: ErrUpdateFailed, Status: fiber.StatusInternalServerError, Code: "update_failed", Title: "Update Failed", Detail: "Failed to update user due to an internal error."},
+	{Err: ErrPreconditionFailed, Status: fiber.StatusPreconditionFailed, Code: "precondition_failed", Title: "Precondition Failed", Detail: "The user has changed since it was read. Fetch it again and retry with its current ETag."},
+	{Err: ErrVersionConflict, Status: fiber.StatusConflict, Code: "version_conflict", Title: "Version Conflict", Detail: "The user was changed by another request at the same time. Please retry."},
+	{Err: ErrDeleteFailed, Status: fiber.StatusInternalServerError, Code: "delete_failed", Title: "Delete Failed", Detail: "Failed to delete user due to an internal error."},
+	{Err: ErrInvalidPageSize, Status: fiber.StatusBadRequest, Code: "invalid_page_size", Title: "Invalid Page Size", Detail: "limit must be a positive number."},
+	{Err: ErrInvalidOffset, Status: fiber.StatusBadRequest, Code: "invalid_offset", Title: "Invalid Offset", Detail: "offset must not be negative and cannot be combined with cursor."},
+	{Err: ErrInvalidSort, Status: fiber.StatusBadRequest, Code: "invalid_sort", Title: "Invalid Sort", Detail: "sort must be a comma separated list of created_at, name or email, optionally prefixed with '-'."},
+	{Err: ErrInvalidCursor, Status: fiber.StatusBadRequest, Code: "invalid_cursor", Title: "Invalid Cursor", Detail: "cursor is invalid or does not match the requested sort."},
+	{Err: ErrInvalidSearchQuery, Status: fiber.StatusBadRequest, Code: "invalid_search_query", Title: "Invalid Search Query", Detail: "q is required and must be at most 100 characters."},
+	{Err: ErrInvalidSearchMode, Status: fiber.StatusBadRequest, Code: "invalid_search_mode", Title: "Invalid Search Mode", Detail: "mode must be either text or prefix."},
+	{Err: ErrInvalidCredentials, Status: fiber.StatusUnauthorized, Code: "invalid_credentials", Title: "Invalid Credentials", Detail: "Invalid email or password provided."},
+	{Err: ErrIncorrectPassword, Status: fiber.StatusUnauthorized, Code: "incorrect_password", Title: "Incorrect Password", Detail: "The password is incorrect."},
+	{Err: ErrEmailNotVerified, Status: fiber.StatusForbidden, Code: "email_not_verified", Title: "Email Not Verified", Detail: "Please verify your email address before logging in."},
+	{Err: ErrAccountLocked, Status: fiber.StatusLocked, Code: "account_locked", Title: "Account Locked", Detail: "Too many failed login attempts. The account is temporarily locked."},
+	{Err: ErrTooManyAttempts, Status: fiber.StatusTooManyRequests, Code: "too_many_login_attempts", Title: "Too Many Login Attempts", Detail: "Too many failed login attempts. Please try again later."},
+	{Err: ErrInvalidResetToken, Status: fiber.StatusBadRequest, Code: "invalid_reset_token", Title: "Invalid Reset Token", Detail: "The password reset token is invalid or has expired."},
+	{Err: ErrInvalidVerifyToken, Status: fiber.StatusBadRequest, Code: "invalid_verification_token", Title: "Invalid Verification Token", Detail: "The verification link is invalid or has expired."},
+	{Err: ErrMFAAlreadyEnabled, Status: fiber.StatusConflict, Code: "mfa_already_enabled", Title: "MFA Already Enabled", Detail: "MFA is already enabled for this user."},
+	{Err: ErrMFANotEnrolled, Status: fiber.StatusConflict, Code: "mfa_not_enrolled", Title: "MFA Not Enrolled", Detail: "MFA enrollment has not been started."},
+	{Err: ErrInvalidMFACode, Status: fiber.StatusUnauthorized, Code: "invalid_mfa_code", Title: "Invalid MFA Code", Detail: "The code is invalid, has expired or has already been used."},
+	{Err: ErrInvalidMFAToken, Status: fiber.StatusUnauthorized, Code: "invalid_mfa_token", Title: "Invalid MFA Token", Detail: "The MFA token is invalid or has expired. Please log in again."},
+}
@@ -0,0 +1,40 @@
+package users
+
+import (
+	"strconv"
+	"strings"
+)
+
+// userETag is the strong ETag of a user at version.
+func userETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// parseUserETag reads a header holding exactly one strong ETag made by
+// userETag.
+func parseUserETag(header string) (int64, bool) {
+	tag := strings.TrimSpace(header)
+	if len(tag) < 3 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+	version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+	return version, true
+}
+
+// etagMatches reports whether an If-Match or If-None-Match header lists etag
+// or is "*". If-None-Match compares weakly, so weak also accepts W/ tags.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
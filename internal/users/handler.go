@@ -3,18 +3,23 @@ package users
 import (
 	"errors"
 	"fmt"
+	"math"
+	"strconv"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/ritchie-gr8/7solution-be/internal/auth"
 	"github.com/ritchie-gr8/7solution-be/pkg/response"
 )
 
 type IUserHandler interface {
 	GetUsers(c *fiber.Ctx) error
 	GetUserById(c *fiber.Ctx) error
+	SearchUsers(c *fiber.Ctx) error
 	Login(c *fiber.Ctx) error
 	CreateUser(c *fiber.Ctx) error
 	UpdateUser(c *fiber.Ctx) error
 	DeleteUser(c *fiber.Ctx) error
+	UnlockUser(c *fiber.Ctx) error
 }
 
 type userHandler struct {
@@ -26,122 +31,346 @@ func NewUserHandler(service IUserService) IUserHandler {
 }
 
 func (uh *userHandler) GetUsers(c *fiber.Ctx) error {
-	users, err := uh.service.GetUsers(c)
+	var query ListUsersQuery
+	if err := c.QueryParser(&query); err != nil {
+		return response.NewResponse(c).Error(fiber.StatusBadRequest, "Invalid query parameters.").Response()
+	}
+
+	page, err := uh.service.GetUsers(requestContext(c), query)
 	if err != nil {
-		return response.NewResponse(c).Error(fiber.StatusInternalServerError, "", "An unexpected error occurred while retrieving users.").Response()
+		return err
 	}
-	return response.NewResponse(c).Success(fiber.StatusOK, users).Response()
+	return response.NewResponse(c).Paginated(fiber.StatusOK, page.Users, response.PageMeta{
+		Total:      page.Total,
+		Limit:      page.Limit,
+		Offset:     page.Offset,
+		NextCursor: page.NextCursor,
+	}).Response()
+}
+
+func (uh *userHandler) SearchUsers(c *fiber.Ctx) error {
+	var query SearchUsersQuery
+	if err := c.QueryParser(&query); err != nil {
+		return response.NewResponse(c).Error(fiber.StatusBadRequest, "Invalid query parameters.").Response()
+	}
+
+	results, err := uh.service.SearchUsers(requestContext(c), query)
+	if err != nil {
+		return err
+	}
+	return response.NewResponse(c).Success(fiber.StatusOK, results).Response()
 }
 
 func (uh *userHandler) GetUserById(c *fiber.Ctx) error {
 	id := c.Params("id")
 	if id == "" {
-		return response.NewResponse(c).Error(fiber.StatusBadRequest, id, "id is required").Response()
+		return response.NewResponse(c).Error(fiber.StatusBadRequest, "id is required").Response()
 	}
 
-	user, err := uh.service.GetUserById(c, id)
+	user, err := uh.service.GetUserById(requestContext(c), id)
 	if err != nil {
-		switch {
-		case errors.Is(err, ErrUserNotFound):
-			return response.NewResponse(c).Error(fiber.StatusNotFound, id, "The requested user was not found.").Response()
-		default:
-			return response.NewResponse(c).Error(fiber.StatusInternalServerError, id, "An unexpected error occurred while retrieving the user.").Response()
-		}
+		return err
+	}
+
+	etag := userETag(user.Version)
+	c.Set(fiber.HeaderETag, etag)
+	if etagMatches(c.Get(fiber.HeaderIfNoneMatch), etag, true) {
+		return c.SendStatus(fiber.StatusNotModified)
 	}
 	return response.NewResponse(c).Success(fiber.StatusOK, user).Response()
 }
 
 func (uh *userHandler) CreateUser(c *fiber.Ctx) error {
-	var userReq CreateUserRequest
-	if err := c.BodyParser(&userReq); err != nil {
-		return response.NewResponse(c).Error(fiber.StatusBadRequest, "", err.Error()).Response()
+	userReq := *c.Locals("body").(*CreateUserRequest)
+
+	user, err := uh.service.CreateUser(requestContext(c), userReq)
+	if err != nil {
+		return err
+	}
+	return response.NewResponse(c).Success(fiber.StatusOK, user).Response()
+}
+
+func (uh *userHandler) UpdateUser(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return response.NewResponse(c).Error(fiber.StatusBadRequest, "id is required").Response()
+	}
+
+	if !mayChange(c, id) {
+		return ErrUnauthorizedAccess
+	}
+
+	userReq := *c.Locals("body").(*UpdateUserRequest)
+
+	version, err := uh.ifMatchVersion(c, id)
+	if err != nil {
+		return err
 	}
 
-	user, err := uh.service.CreateUser(c, userReq)
+	updatedUser, err := uh.service.UpdateUser(requestContext(c), id, userReq, version)
 	if err != nil {
-		switch {
-		case errors.Is(err, ErrEmailAlreadyExists):
-			return response.NewResponse(c).Error(fiber.StatusConflict, "", "A user with this email already exists.").Response()
-		default:
-			return response.NewResponse(c).Error(fiber.StatusInternalServerError, "", "An unexpected error occurred while creating the user.").Response()
+		return err
+	}
+	c.Set(fiber.HeaderETag, userETag(updatedUser.Version))
+	return response.NewResponse(c).Success(fiber.StatusOK, updatedUser).Response()
+}
+
+func (uh *userHandler) DeleteUser(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return response.NewResponse(c).Error(fiber.StatusBadRequest, "id is required").Response()
+	}
+
+	if !mayChange(c, id) {
+		return ErrUnauthorizedAccess
+	}
+
+	version, err := uh.ifMatchVersion(c, id)
+	if err != nil {
+		return err
+	}
+
+	if err := uh.service.DeleteUser(requestContext(c), id, version); err != nil {
+		return err
+	}
+	return response.NewResponse(c).Success(fiber.StatusOK, fmt.Sprintf("User with id %s deleted successfully", id)).Response()
+}
+
+// mayChange reports whether the token's user may change the account id: their
+// own, or any account with users:manage.
+func mayChange(c *fiber.Ctx, id string) bool {
+	granted, _ := c.Locals("permissions").([]auth.Permission)
+	return c.Locals("userId").(string) == id || auth.HasPermission(granted, auth.PermissionUsersManage)
+}
+
+// ifMatchVersion is the version the If-Match header requires the user to be
+// at, 0 without the header. A single ETag is left for the write to check, so
+// it cannot race with another write. A list of ETags or "*" is compared with
+// the current user here.
+func (uh *userHandler) ifMatchVersion(c *fiber.Ctx, id string) (int64, error) {
+	header := c.Get(fiber.HeaderIfMatch)
+	if header == "" {
+		return 0, nil
+	}
+	if version, ok := parseUserETag(header); ok {
+		return version, nil
+	}
+
+	user, err := uh.service.GetUserById(requestContext(c), id)
+	if err != nil {
+		return 0, err
+	}
+	if !etagMatches(header, userETag(user.Version), false) {
+		return 0, ErrPreconditionFailed
+	}
+	return user.Version, nil
+}
+
+func (uh *userHandler) UnlockUser(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return response.NewResponse(c).Error(fiber.StatusBadRequest, "id is required").Response()
+	}
+
+	if err := uh.service.UnlockUser(requestContext(c), id); err != nil {
+		return err
+	}
+	return response.NewResponse(c).Success(fiber.StatusOK, fmt.Sprintf("User with id %s unlocked successfully", id)).Response()
+}
+
+func (uh *userHandler) Login(c *fiber.Ctx) error {
+	loginReq := *c.Locals("body").(*LoginUserRequest)
+
+	user, err := uh.service.Login(requestContext(c), loginReq)
+	if err != nil {
+		var challenge *MFAChallenge
+		if errors.As(err, &challenge) {
+			return response.NewResponse(c).Success(fiber.StatusOK, challenge).Response()
+		}
+
+		var lockout *LockoutError
+		if errors.As(err, &lockout) {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(lockout.RetryAfter.Seconds()))))
 		}
+
+		return err
 	}
 	return response.NewResponse(c).Success(fiber.StatusOK, user).Response()
 }
 
-func (uh *userHandler) UpdateUser(c *fiber.Ctx) error {
+type IMFAHandler interface {
+	Enroll(c *fiber.Ctx) error
+	Confirm(c *fiber.Ctx) error
+	Disable(c *fiber.Ctx) error
+	Verify(c *fiber.Ctx) error
+}
+
+type mfaHandler struct {
+	service IMFAService
+}
+
+func NewMFAHandler(service IMFAService) IMFAHandler {
+	return &mfaHandler{service: service}
+}
+
+func (mh *mfaHandler) Enroll(c *fiber.Ctx) error {
 	id := c.Params("id")
 	if id == "" {
-		return response.NewResponse(c).Error(fiber.StatusBadRequest, id, "id is required").Response()
+		return response.NewResponse(c).Error(fiber.StatusBadRequest, "id is required").Response()
 	}
 
+	// The secret ends up on the device of whoever enrolls, so only the user
+	// themselves may do it.
 	tokenUser := c.Locals("userId").(string)
 	if tokenUser != id {
-		return response.NewResponse(c).Error(fiber.StatusUnauthorized, id, "Unauthorized").Response()
+		return ErrUnauthorizedAccess
 	}
 
-	var userReq UpdateUserRequest
-	if err := c.BodyParser(&userReq); err != nil {
-		return response.NewResponse(c).Error(fiber.StatusBadRequest, id, err.Error()).Response()
+	enrollment, err := mh.service.Enroll(requestContext(c), id)
+	if err != nil {
+		return err
+	}
+	return response.NewResponse(c).Success(fiber.StatusOK, enrollment).Response()
+}
+
+func (mh *mfaHandler) Confirm(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return response.NewResponse(c).Error(fiber.StatusBadRequest, "id is required").Response()
+	}
+
+	tokenUser := c.Locals("userId").(string)
+	if tokenUser != id {
+		return ErrUnauthorizedAccess
 	}
 
-	updatedUser, err := uh.service.UpdateUser(c, id, userReq)
+	req := *c.Locals("body").(*ConfirmMFARequest)
+
+	codes, err := mh.service.Confirm(requestContext(c), id, req)
 	if err != nil {
-		switch {
-		case errors.Is(err, ErrUserNotFound):
-			return response.NewResponse(c).Error(fiber.StatusNotFound, id, "The user you are trying to update was not found.").Response()
-		case errors.Is(err, ErrEmailAlreadyExists):
-			return response.NewResponse(c).Error(fiber.StatusConflict, id, "Cannot update user: email already exists.").Response()
-		case errors.Is(err, ErrUpdateFailed):
-			return response.NewResponse(c).Error(fiber.StatusInternalServerError, id, "Failed to update user due to an internal error.").Response()
-		default:
-			return response.NewResponse(c).Error(fiber.StatusInternalServerError, id, "An unexpected error occurred while updating the user.").Response()
-		}
+		return err
 	}
-	return response.NewResponse(c).Success(fiber.StatusOK, updatedUser).Response()
+	return response.NewResponse(c).Success(fiber.StatusOK, codes).Response()
 }
 
-func (uh *userHandler) DeleteUser(c *fiber.Ctx) error {
+func (mh *mfaHandler) Disable(c *fiber.Ctx) error {
 	id := c.Params("id")
 	if id == "" {
-		return response.NewResponse(c).Error(fiber.StatusBadRequest, id, "id is required").Response()
+		return response.NewResponse(c).Error(fiber.StatusBadRequest, "id is required").Response()
 	}
 
 	tokenUser := c.Locals("userId").(string)
 	if tokenUser != id {
-		return response.NewResponse(c).Error(fiber.StatusUnauthorized, id, "Unauthorized").Response()
+		return ErrUnauthorizedAccess
 	}
 
-	err := uh.service.DeleteUser(c, id)
+	req := *c.Locals("body").(*DisableMFARequest)
+
+	if err := mh.service.Disable(requestContext(c), id, req); err != nil {
+		return err
+	}
+	return response.NewResponse(c).Success(fiber.StatusOK, "MFA disabled successfully.").Response()
+}
+
+func (mh *mfaHandler) Verify(c *fiber.Ctx) error {
+	req := *c.Locals("body").(*VerifyMFARequest)
+
+	user, err := mh.service.Verify(requestContext(c), req)
 	if err != nil {
-		switch {
-		case errors.Is(err, ErrUserNotFound):
-			return response.NewResponse(c).Error(fiber.StatusNotFound, id, "The user you are trying to delete was not found.").Response()
-		case errors.Is(err, ErrDeleteFailed):
-			return response.NewResponse(c).Error(fiber.StatusInternalServerError, id, "Failed to delete user due to an internal error.").Response()
-		default:
-			return response.NewResponse(c).Error(fiber.StatusInternalServerError, id, "An unexpected error occurred while deleting the user.").Response()
+		var lockout *LockoutError
+		if errors.As(err, &lockout) {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(lockout.RetryAfter.Seconds()))))
 		}
+
+		return err
 	}
-	return response.NewResponse(c).Success(fiber.StatusOK, fmt.Sprintf("User with id %s deleted successfully", id)).Response()
+	return response.NewResponse(c).Success(fiber.StatusOK, user).Response()
 }
 
-func (uh *userHandler) Login(c *fiber.Ctx) error {
-	var loginReq LoginUserRequest
-	if err := c.BodyParser(&loginReq); err != nil {
-		return response.NewResponse(c).Error(fiber.StatusBadRequest, "", err.Error()).Response()
+type IPasswordHandler interface {
+	ChangePassword(c *fiber.Ctx) error
+	ForgotPassword(c *fiber.Ctx) error
+	ResetPassword(c *fiber.Ctx) error
+}
+
+type passwordHandler struct {
+	service IPasswordService
+}
+
+func NewPasswordHandler(service IPasswordService) IPasswordHandler {
+	return &passwordHandler{service: service}
+}
+
+func (ph *passwordHandler) ChangePassword(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return response.NewResponse(c).Error(fiber.StatusBadRequest, "id is required").Response()
 	}
 
-	user, err := uh.service.Login(c, loginReq)
+	// Not even users:manage may change someone else's password here, since it
+	// takes the current password. Admins should use the reset flow.
+	tokenUser := c.Locals("userId").(string)
+	if tokenUser != id {
+		return ErrUnauthorizedAccess
+	}
+
+	req := *c.Locals("body").(*ChangePasswordRequest)
+
+	user, err := ph.service.ChangePassword(requestContext(c), id, req)
 	if err != nil {
-		switch {
-		case errors.Is(err, ErrInvalidCredentials):
-			return response.NewResponse(c).Error(fiber.StatusUnauthorized, "", "Invalid email or password provided.").Response()
-		case errors.Is(err, ErrUserNotFound):
-			return response.NewResponse(c).Error(fiber.StatusNotFound, "", "User not found.").Response()
-		default:
-			return response.NewResponse(c).Error(fiber.StatusInternalServerError, "", "An unexpected error occurred during login.").Response()
-		}
+		return err
 	}
 	return response.NewResponse(c).Success(fiber.StatusOK, user).Response()
 }
+
+func (ph *passwordHandler) ForgotPassword(c *fiber.Ctx) error {
+	req := *c.Locals("body").(*ForgotPasswordRequest)
+
+	if err := ph.service.ForgotPassword(requestContext(c), req); err != nil {
+		return err
+	}
+	return response.NewResponse(c).Success(fiber.StatusAccepted, "If an account with this email exists, a password reset link has been sent.").Response()
+}
+
+func (ph *passwordHandler) ResetPassword(c *fiber.Ctx) error {
+	req := *c.Locals("body").(*ResetPasswordRequest)
+
+	if err := ph.service.ResetPassword(requestContext(c), req); err != nil {
+		return err
+	}
+	return response.NewResponse(c).Success(fiber.StatusOK, "Password has been reset successfully.").Response()
+}
+
+type IVerificationHandler interface {
+	VerifyEmail(c *fiber.Ctx) error
+	ResendVerification(c *fiber.Ctx) error
+}
+
+type verificationHandler struct {
+	service IVerificationService
+}
+
+func NewVerificationHandler(service IVerificationService) IVerificationHandler {
+	return &verificationHandler{service: service}
+}
+
+func (vh *verificationHandler) VerifyEmail(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if token == "" {
+		return response.NewResponse(c).Error(fiber.StatusBadRequest, "token is required").Response()
+	}
+
+	if err := vh.service.VerifyEmail(requestContext(c), token); err != nil {
+		return err
+	}
+	return response.NewResponse(c).Success(fiber.StatusOK, "Email address verified successfully.").Response()
+}
+
+func (vh *verificationHandler) ResendVerification(c *fiber.Ctx) error {
+	req := *c.Locals("body").(*ResendVerificationRequest)
+
+	if err := vh.service.ResendVerification(requestContext(c), req); err != nil {
+		return err
+	}
+	return response.NewResponse(c).Success(fiber.StatusAccepted, "If this email belongs to an unverified account, a verification link has been sent.").Response()
+}
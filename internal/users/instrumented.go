@@ -0,0 +1,99 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ritchie-gr8/7solution-be/internal/metrics"
+	"github.com/ritchie-gr8/7solution-be/internal/tracing"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentedCollection records the latency and errors of every operation on
+// the wrapped collection, and traces it as a child of the span in ctx.
+type instrumentedCollection struct {
+	collection MongoCollection
+	name       string
+}
+
+func newInstrumentedCollection(collection *mongo.Collection) MongoCollection {
+	return &instrumentedCollection{collection: collection, name: collection.Name()}
+}
+
+func (i *instrumentedCollection) Find(ctx context.Context, filter any, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	ctx, done := i.observe(ctx, "find")
+	cursor, err := i.collection.Find(ctx, filter, opts...)
+	done(err)
+	return cursor, err
+}
+
+func (i *instrumentedCollection) FindOne(ctx context.Context, filter any, opts ...*options.FindOneOptions) *mongo.SingleResult {
+	ctx, done := i.observe(ctx, "find_one")
+	result := i.collection.FindOne(ctx, filter, opts...)
+	done(result.Err())
+	return result
+}
+
+func (i *instrumentedCollection) InsertOne(ctx context.Context, document any, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	ctx, done := i.observe(ctx, "insert_one")
+	result, err := i.collection.InsertOne(ctx, document, opts...)
+	done(err)
+	return result, err
+}
+
+func (i *instrumentedCollection) FindOneAndUpdate(ctx context.Context, filter any, update any, opts ...*options.FindOneAndUpdateOptions) *mongo.SingleResult {
+	ctx, done := i.observe(ctx, "find_one_and_update")
+	result := i.collection.FindOneAndUpdate(ctx, filter, update, opts...)
+	done(result.Err())
+	return result
+}
+
+func (i *instrumentedCollection) UpdateMany(ctx context.Context, filter any, update any, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	ctx, done := i.observe(ctx, "update_many")
+	result, err := i.collection.UpdateMany(ctx, filter, update, opts...)
+	done(err)
+	return result, err
+}
+
+func (i *instrumentedCollection) DeleteOne(ctx context.Context, filter any, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	ctx, done := i.observe(ctx, "delete_one")
+	result, err := i.collection.DeleteOne(ctx, filter, opts...)
+	done(err)
+	return result, err
+}
+
+func (i *instrumentedCollection) CountDocuments(ctx context.Context, filter any, opts ...*options.CountOptions) (int64, error) {
+	ctx, done := i.observe(ctx, "count_documents")
+	count, err := i.collection.CountDocuments(ctx, filter, opts...)
+	done(err)
+	return count, err
+}
+
+func (i *instrumentedCollection) Aggregate(ctx context.Context, pipeline any, opts ...*options.AggregateOptions) (*mongo.Cursor, error) {
+	ctx, done := i.observe(ctx, "aggregate")
+	cursor, err := i.collection.Aggregate(ctx, pipeline, opts...)
+	done(err)
+	return cursor, err
+}
+
+// observe starts a span for op and returns the function that ends it and
+// records the operation's metrics.
+func (i *instrumentedCollection) observe(ctx context.Context, op string) (context.Context, func(err error)) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "mongo."+op, trace.SpanKindClient,
+		semconv.DBSystemMongoDB,
+		semconv.DBCollectionName(i.name),
+		semconv.DBOperationName(op),
+	)
+	return ctx, func(err error) {
+		metrics.ObserveMongo(i.name, op, start, err)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			err = nil
+		}
+		tracing.End(span, err)
+	}
+}
@@ -0,0 +1,638 @@
+package users
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/ritchie-gr8/7solution-be/internal/auth"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Text search weights, the same as in the MongoDB users_text index.
+const (
+	memoryNameWeight  = 10
+	memoryEmailWeight = 5
+)
+
+// IMemoryUserRepository keeps users in process. Snapshot writes them to the
+// snapshot file, if there is one, so they survive a restart.
+type IMemoryUserRepository interface {
+	IUserRepository
+	Snapshot() error
+}
+
+// memoryUserRepository never changes a stored user in place, updates replace
+// it with a changed copy. Users read under the lock can therefore still be
+// used once it is released.
+type memoryUserRepository struct {
+	mu     sync.RWMutex
+	users  map[primitive.ObjectID]*User
+	emails map[string]primitive.ObjectID
+	// snapshotPath is empty when users are not persisted.
+	snapshotPath string
+}
+
+// NewMemoryUserRepository loads the users from snapshotPath when the file
+// exists. An empty path keeps users in memory only.
+func NewMemoryUserRepository(snapshotPath string) (IMemoryUserRepository, error) {
+	r := &memoryUserRepository{
+		users:        make(map[primitive.ObjectID]*User),
+		emails:       make(map[string]primitive.ObjectID),
+		snapshotPath: snapshotPath,
+	}
+	if snapshotPath == "" {
+		return r, nil
+	}
+
+	if err := r.load(); err != nil {
+		return nil, fmt.Errorf("user: load snapshot %s: %w", snapshotPath, err)
+	}
+	return r, nil
+}
+
+func (r *memoryUserRepository) GetUsers(ctx context.Context, opts *ListUsersOptions) (*UserPage, error) {
+	var after *User
+	if opts.After != nil {
+		pivot, err := cursorUser(opts)
+		if err != nil {
+			return nil, err
+		}
+		after = pivot
+	}
+
+	r.mu.RLock()
+	var matched []*User
+	for _, user := range r.users {
+		if matchesListFilter(user, opts) {
+			matched = append(matched, user)
+		}
+	}
+	r.mu.RUnlock()
+
+	page := &UserPage{Total: int64(len(matched))}
+
+	slices.SortFunc(matched, func(a, b *User) int { return compareUsers(a, b, opts.Sort) })
+	if after != nil {
+		start, _ := slices.BinarySearchFunc(matched, after, func(u, pivot *User) int {
+			if compareUsers(u, pivot, opts.Sort) <= 0 {
+				return -1
+			}
+			return 1
+		})
+		matched = matched[start:]
+	}
+	matched = matched[min(opts.Offset, len(matched)):]
+
+	users := make([]User, 0, min(opts.Limit, len(matched)))
+	for _, user := range matched[:min(opts.Limit, len(matched))] {
+		users = append(users, *cloneUser(user))
+	}
+	if len(matched) > opts.Limit {
+		page.NextCursor = opts.NextCursor(&users[len(users)-1])
+	}
+	page.Users = users
+	return page, nil
+}
+
+func (r *memoryUserRepository) GetUserById(ctx context.Context, id string) (*User, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[objectID]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return cloneUser(user), nil
+}
+
+func (r *memoryUserRepository) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, ok := r.emails[email]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return cloneUser(r.users[id]), nil
+}
+
+// SearchUsers ranks users by relevance. Text mode matches whole words of name
+// and email, weighted like the MongoDB text index but without stemming, and
+// leaves out users with a negated word. Prefix mode scores matches the same
+// way as the MongoDB repository.
+func (r *memoryUserRepository) SearchUsers(ctx context.Context, opts *SearchUsersOptions) ([]UserSearchHit, error) {
+	score := textScore(opts)
+	if opts.Mode == SearchModePrefix {
+		score = prefixScore(opts.Query)
+	}
+
+	r.mu.RLock()
+	var hits []UserSearchHit
+	for _, user := range r.users {
+		if s := score(user); s > 0 {
+			hits = append(hits, UserSearchHit{User: *cloneUser(user), Score: s})
+		}
+	}
+	r.mu.RUnlock()
+
+	slices.SortFunc(hits, func(a, b UserSearchHit) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID.Hex(), b.ID.Hex())
+	})
+	return hits[:min(opts.Limit, len(hits))], nil
+}
+
+func (r *memoryUserRepository) CreateUser(ctx context.Context, userReq CreateUserRequest) (*User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.emails[userReq.Email]; ok {
+		return nil, ErrEmailAlreadyExists
+	}
+
+	now := time.Now()
+	user := &User{
+		ID:            primitive.NewObjectID(),
+		Name:          userReq.Name,
+		Email:         userReq.Email,
+		Password:      userReq.Password,
+		Roles:         []string{auth.RoleUser},
+		EmailVerified: new(bool),
+		Version:       1,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	r.users[user.ID] = user
+	r.emails[user.Email] = user.ID
+	return cloneUser(user), nil
+}
+
+func (r *memoryUserRepository) UpdateUser(ctx context.Context, id string, userReq UpdateUserRequest, version int64) (*User, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if owner, ok := r.emails[userReq.Email]; ok && owner != objectID {
+		return nil, ErrEmailAlreadyExists
+	}
+
+	stored, ok := r.users[objectID]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	if version != 0 && stored.Version != version {
+		return nil, ErrVersionConflict
+	}
+
+	user := cloneUser(stored)
+	if user.Email != userReq.Email {
+		user.EmailVerified = new(bool)
+	}
+	user.Name = userReq.Name
+	user.Email = userReq.Email
+	user.Version++
+	user.UpdatedAt = time.Now()
+
+	delete(r.emails, stored.Email)
+	r.users[objectID] = user
+	r.emails[user.Email] = objectID
+	return cloneUser(user), nil
+}
+
+// UpdatePassword stores an already hashed password.
+func (r *memoryUserRepository) UpdatePassword(ctx context.Context, id primitive.ObjectID, password string) error {
+	return r.update(id, ErrUserNotFound, func(user *User) bool {
+		user.Password = password
+		user.UpdatedAt = time.Now()
+		return true
+	})
+}
+
+// SetEmailVerified only matches while the user still has the given email, so
+// a link sent to a previous address cannot verify the current one.
+func (r *memoryUserRepository) SetEmailVerified(ctx context.Context, id primitive.ObjectID, email string, verified bool) error {
+	return r.update(id, ErrUserNotFound, func(user *User) bool {
+		if user.Email != email {
+			return false
+		}
+		user.EmailVerified = &verified
+		user.Version++
+		user.UpdatedAt = time.Now()
+		return true
+	})
+}
+
+func (r *memoryUserRepository) SetPendingMFASecret(ctx context.Context, id primitive.ObjectID, secret string) error {
+	return r.update(id, ErrUserNotFound, func(user *User) bool {
+		if user.MFA == nil {
+			user.MFA = &MFA{}
+		}
+		user.MFA.PendingSecret = secret
+		return true
+	})
+}
+
+// EnableMFA only matches while the pending secret is still the one that was
+// confirmed, so a concurrent re-enrollment cannot be enabled unconfirmed.
+func (r *memoryUserRepository) EnableMFA(ctx context.Context, id primitive.ObjectID, secret string, recoveryCodes []string) error {
+	return r.update(id, ErrMFANotEnrolled, func(user *User) bool {
+		if user.MFA == nil || user.MFA.PendingSecret == "" || user.MFA.PendingSecret != secret {
+			return false
+		}
+		user.MFA = &MFA{Enabled: true, Secret: secret, RecoveryCodes: slices.Clone(recoveryCodes)}
+		user.Version++
+		return true
+	})
+}
+
+func (r *memoryUserRepository) DisableMFA(ctx context.Context, id primitive.ObjectID) error {
+	return r.update(id, ErrUserNotFound, func(user *User) bool {
+		user.MFA = nil
+		user.Version++
+		return true
+	})
+}
+
+// MarkTOTPStepUsed fails with ErrInvalidMFACode when a code of the same or a
+// later step was already accepted, which rejects replayed codes.
+func (r *memoryUserRepository) MarkTOTPStepUsed(ctx context.Context, id primitive.ObjectID, step int64) error {
+	return r.update(id, ErrInvalidMFACode, func(user *User) bool {
+		if user.MFA == nil {
+			user.MFA = &MFA{}
+		}
+		if user.MFA.LastUsedStep >= step {
+			return false
+		}
+		user.MFA.LastUsedStep = step
+		return true
+	})
+}
+
+// ConsumeRecoveryCode removes the code in the same update that finds it, so
+// each recovery code works once.
+func (r *memoryUserRepository) ConsumeRecoveryCode(ctx context.Context, id primitive.ObjectID, hash string) error {
+	return r.update(id, ErrInvalidMFACode, func(user *User) bool {
+		if user.MFA == nil || !slices.Contains(user.MFA.RecoveryCodes, hash) {
+			return false
+		}
+		user.MFA.RecoveryCodes = slices.DeleteFunc(user.MFA.RecoveryCodes, func(code string) bool { return code == hash })
+		return true
+	})
+}
+
+// update applies change to the user under the write lock. change reports
+// whether the user matched; notMatched is returned when it did not or when
+// there is no such user.
+func (r *memoryUserRepository) update(id primitive.ObjectID, notMatched error, change func(user *User) bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return notMatched
+	}
+
+	updated := cloneUser(user)
+	if !change(updated) {
+		return notMatched
+	}
+	r.users[id] = updated
+	return nil
+}
+
+func (r *memoryUserRepository) DeleteUser(ctx context.Context, id string, version int64) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrInvalidID
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[objectID]
+	if !ok {
+		return ErrUserNotFound
+	}
+	if version != 0 && user.Version != version {
+		return ErrVersionConflict
+	}
+	delete(r.users, objectID)
+	delete(r.emails, user.Email)
+	return nil
+}
+
+func (r *memoryUserRepository) CountUsers(ctx context.Context) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return int64(len(r.users)), nil
+}
+
+// memorySnapshot is the file format of a snapshot. User leaves the password
+// and the MFA settings out of its JSON, so users are stored as records.
+type memorySnapshot struct {
+	Users []memoryUserRecord `json:"users"`
+}
+
+type memoryUserRecord struct {
+	ID            primitive.ObjectID `json:"id"`
+	Name          string             `json:"name"`
+	Email         string             `json:"email"`
+	Password      string             `json:"password"`
+	Roles         []string           `json:"roles"`
+	EmailVerified *bool              `json:"email_verified,omitempty"`
+	MFA           *memoryMFARecord   `json:"mfa,omitempty"`
+	Version       int64              `json:"version"`
+	CreatedAt     time.Time          `json:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+}
+
+type memoryMFARecord struct {
+	Enabled       bool     `json:"enabled"`
+	Secret        string   `json:"secret,omitempty"`
+	PendingSecret string   `json:"pending_secret,omitempty"`
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+	LastUsedStep  int64    `json:"last_used_step,omitempty"`
+}
+
+// Snapshot writes every user to the snapshot file. The file is replaced
+// atomically, so a crash while writing leaves the previous snapshot intact.
+// It holds password hashes and MFA secrets and is only readable by the owner.
+func (r *memoryUserRepository) Snapshot() error {
+	if r.snapshotPath == "" {
+		return nil
+	}
+
+	r.mu.RLock()
+	snapshot := memorySnapshot{Users: make([]memoryUserRecord, 0, len(r.users))}
+	for _, user := range r.users {
+		snapshot.Users = append(snapshot.Users, toUserRecord(user))
+	}
+	r.mu.RUnlock()
+
+	slices.SortFunc(snapshot.Users, func(a, b memoryUserRecord) int { return strings.Compare(a.ID.Hex(), b.ID.Hex()) })
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.snapshotPath), filepath.Base(r.snapshotPath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), r.snapshotPath)
+}
+
+func (r *memoryUserRepository) load() error {
+	data, err := os.ReadFile(r.snapshotPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var snapshot memorySnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return err
+	}
+
+	for _, record := range snapshot.Users {
+		user := record.toUser()
+		if _, ok := r.emails[user.Email]; ok {
+			return fmt.Errorf("%w: %s", ErrEmailAlreadyExists, user.Email)
+		}
+		r.users[user.ID] = user
+		r.emails[user.Email] = user.ID
+	}
+	return nil
+}
+
+func toUserRecord(user *User) memoryUserRecord {
+	record := memoryUserRecord{
+		ID:            user.ID,
+		Name:          user.Name,
+		Email:         user.Email,
+		Password:      user.Password,
+		Roles:         user.Roles,
+		EmailVerified: user.EmailVerified,
+		Version:       user.Version,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+	}
+	if user.MFA != nil {
+		record.MFA = &memoryMFARecord{
+			Enabled:       user.MFA.Enabled,
+			Secret:        user.MFA.Secret,
+			PendingSecret: user.MFA.PendingSecret,
+			RecoveryCodes: user.MFA.RecoveryCodes,
+			LastUsedStep:  user.MFA.LastUsedStep,
+		}
+	}
+	return record
+}
+
+func (record memoryUserRecord) toUser() *User {
+	user := &User{
+		ID:            record.ID,
+		Name:          record.Name,
+		Email:         record.Email,
+		Password:      record.Password,
+		Roles:         record.Roles,
+		EmailVerified: record.EmailVerified,
+		// Snapshots written before users had a version start at 1.
+		Version:   max(record.Version, 1),
+		CreatedAt: record.CreatedAt,
+		UpdatedAt: record.UpdatedAt,
+	}
+	if record.MFA != nil {
+		user.MFA = &MFA{
+			Enabled:       record.MFA.Enabled,
+			Secret:        record.MFA.Secret,
+			PendingSecret: record.MFA.PendingSecret,
+			RecoveryCodes: record.MFA.RecoveryCodes,
+			LastUsedStep:  record.MFA.LastUsedStep,
+		}
+	}
+	return user
+}
+
+// cloneUser copies user deeply, so callers cannot change stored users.
+func cloneUser(user *User) *User {
+	clone := *user
+	clone.Roles = slices.Clone(user.Roles)
+	if user.EmailVerified != nil {
+		verified := *user.EmailVerified
+		clone.EmailVerified = &verified
+	}
+	if user.MFA != nil {
+		mfa := *user.MFA
+		mfa.RecoveryCodes = slices.Clone(user.MFA.RecoveryCodes)
+		clone.MFA = &mfa
+	}
+	return &clone
+}
+
+// matchesListFilter mirrors userListFilter: the name contains the filter and
+// the email equals it, both ignoring case.
+func matchesListFilter(user *User, opts *ListUsersOptions) bool {
+	if opts.Name != "" && !strings.Contains(strings.ToLower(user.Name), strings.ToLower(opts.Name)) {
+		return false
+	}
+	if opts.Email != "" && !strings.EqualFold(user.Email, opts.Email) {
+		return false
+	}
+	return true
+}
+
+// compareUsers orders users like sortDocument: by the sort fields, then by id.
+// Strings compare bytewise as in MongoDB.
+func compareUsers(a, b *User, sort []SortField) int {
+	for _, field := range sort {
+		var c int
+		switch field.Field {
+		case "created_at":
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		case "name":
+			c = strings.Compare(a.Name, b.Name)
+		case "email":
+			c = strings.Compare(a.Email, b.Email)
+		}
+		if field.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return strings.Compare(a.ID.Hex(), b.ID.Hex())
+}
+
+// cursorUser is a user with the sort values and id of the cursor, which the
+// next page starts after.
+func cursorUser(opts *ListUsersOptions) (*User, error) {
+	id, err := opts.CursorID()
+	if err != nil {
+		return nil, err
+	}
+
+	pivot := &User{ID: id}
+	for i, field := range opts.Sort {
+		value, err := opts.CursorValue(i)
+		if err != nil {
+			return nil, err
+		}
+		switch field.Field {
+		case "created_at":
+			pivot.CreatedAt = value.(time.Time)
+		case "name":
+			pivot.Name = value.(string)
+		case "email":
+			pivot.Email = value.(string)
+		}
+	}
+	return pivot, nil
+}
+
+func textScore(opts *SearchUsersOptions) func(user *User) float64 {
+	var negated []string
+	for _, word := range strings.Fields(opts.Query) {
+		if strings.HasPrefix(word, "-") && len(word) > 1 {
+			negated = append(negated, strings.ToLower(word[1:]))
+		}
+	}
+	var terms []string
+	for _, term := range opts.Terms() {
+		terms = append(terms, words(term)...)
+	}
+
+	return func(user *User) float64 {
+		name, email := words(user.Name), words(user.Email)
+		for _, word := range negated {
+			if slices.Contains(name, word) || slices.Contains(email, word) {
+				return 0
+			}
+		}
+
+		var score float64
+		for _, term := range terms {
+			if slices.Contains(name, term) {
+				score += memoryNameWeight
+			}
+			if slices.Contains(email, term) {
+				score += memoryEmailWeight
+			}
+		}
+		return score
+	}
+}
+
+func prefixScore(query string) func(user *User) float64 {
+	exact, prefix, wordPrefix := prefixPatterns(query)
+	exactRe := regexp.MustCompile("(?i)" + exact)
+	prefixRe := regexp.MustCompile("(?i)" + prefix)
+	wordPrefixRe := regexp.MustCompile("(?i)" + wordPrefix)
+
+	return func(user *User) float64 {
+		if !wordPrefixRe.MatchString(user.Name) && !prefixRe.MatchString(user.Email) {
+			return 0
+		}
+
+		var score float64
+		for _, match := range []struct {
+			re    *regexp.Regexp
+			value string
+			score float64
+		}{
+			{exactRe, user.Name, 8},
+			{prefixRe, user.Name, 4},
+			{wordPrefixRe, user.Name, 2},
+			{exactRe, user.Email, 6},
+			{prefixRe, user.Email, 1},
+		} {
+			if match.re.MatchString(match.value) {
+				score += match.score
+			}
+		}
+		return score
+	}
+}
+
+// words splits text into lower case words at everything that is not a letter
+// or a digit, e.g. an email into its local part and domain labels.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
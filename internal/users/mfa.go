@@ -0,0 +1,302 @@
+package users
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"image/png"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"github.com/ritchie-gr8/7solution-be/internal/auth"
+	"github.com/ritchie-gr8/7solution-be/internal/metrics"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	totpPeriod = 30
+	// totpSkew accepts codes from one step before and after the current one
+	// to tolerate clock drift between the server and the authenticator.
+	totpSkew = 1
+
+	recoveryCodeCount = 10
+	qrCodeSize        = 256
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// MFAChallenge is returned by Login instead of tokens when the user has MFA
+// enabled. The token is exchanged for tokens together with a TOTP or recovery
+// code via IMFAService.Verify.
+type MFAChallenge struct {
+	MFARequired bool      `json:"mfa_required"`
+	Token       string    `json:"mfa_token"`
+	ExpiresAt   time.Time `json:"mfa_token_expires_at"`
+}
+
+func (c *MFAChallenge) Error() string { return ErrMFARequired.Error() }
+func (c *MFAChallenge) Unwrap() error { return ErrMFARequired }
+
+type IMFAService interface {
+	// Enroll generates a new secret that becomes active once confirmed.
+	Enroll(ctx context.Context, id string) (*MFAEnrollmentResponse, error)
+	// Confirm enables MFA and returns the recovery codes, which are only ever
+	// shown this once.
+	Confirm(ctx context.Context, id string, req ConfirmMFARequest) (*MFARecoveryCodesResponse, error)
+	Disable(ctx context.Context, id string, req DisableMFARequest) error
+	Challenge(user *User) (*MFAChallenge, error)
+	Verify(ctx context.Context, req VerifyMFARequest) (*UserResponseWithToken, error)
+}
+
+type mfaService struct {
+	repo               IUserRepository
+	tokens             auth.ITokenService
+	verification       IVerificationService
+	throttle           ILoginThrottle
+	signer             *tokenSigner
+	issuer             string
+	challengeExpiresAt time.Duration
+}
+
+func NewMFAService(repo IUserRepository, tokens auth.ITokenService, verification IVerificationService, throttle ILoginThrottle, secret []byte, issuer string, challengeExpiresAt time.Duration) IMFAService {
+	return &mfaService{
+		repo:               repo,
+		tokens:             tokens,
+		verification:       verification,
+		throttle:           throttle,
+		signer:             newTokenSigner(secret, "mfa_challenge"),
+		issuer:             issuer,
+		challengeExpiresAt: challengeExpiresAt,
+	}
+}
+
+type challengeClaims struct {
+	UserID string `json:"sub"`
+	Exp    int64  `json:"exp"`
+}
+
+func (s *mfaService) Enroll(ctx context.Context, id string) (*MFAEnrollmentResponse, error) {
+	user, err := s.repo.GetUserById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsMFAEnabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      s.issuer,
+		AccountName: user.Email,
+		Period:      totpPeriod,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.SetPendingMFASecret(ctx, user.ID, key.Secret()); err != nil {
+		return nil, err
+	}
+
+	qrCode, err := qrCodeDataURI(key)
+	if err != nil {
+		return nil, err
+	}
+	return &MFAEnrollmentResponse{
+		Secret:     key.Secret(),
+		OtpauthURI: key.URL(),
+		QRCode:     qrCode,
+	}, nil
+}
+
+func (s *mfaService) Confirm(ctx context.Context, id string, req ConfirmMFARequest) (*MFARecoveryCodesResponse, error) {
+	user, err := s.repo.GetUserById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsMFAEnabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if user.MFA == nil || user.MFA.PendingSecret == "" {
+		return nil, ErrMFANotEnrolled
+	}
+
+	step, ok := matchTOTP(user.MFA.PendingSecret, req.Code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.EnableMFA(ctx, user.ID, user.MFA.PendingSecret, hashes); err != nil {
+		return nil, err
+	}
+	// The confirmation code is spent too, it must not log in a second time.
+	if err := s.repo.MarkTOTPStepUsed(ctx, user.ID, step); err != nil {
+		return nil, err
+	}
+	return &MFARecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// Disable asks for the password again, so a stolen access token alone is not
+// enough to turn MFA off.
+func (s *mfaService) Disable(ctx context.Context, id string, req DisableMFARequest) error {
+	user, err := s.repo.GetUserById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := comparePassword(user.Password, req.Password); err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrIncorrectPassword
+		}
+		return err
+	}
+
+	if user.MFA == nil {
+		return nil
+	}
+	return s.repo.DisableMFA(ctx, user.ID)
+}
+
+func (s *mfaService) Challenge(user *User) (*MFAChallenge, error) {
+	expiresAt := time.Now().Add(s.challengeExpiresAt)
+	token, err := s.signer.sign(challengeClaims{UserID: user.ID.Hex(), Exp: expiresAt.Unix()})
+	if err != nil {
+		return nil, err
+	}
+	return &MFAChallenge{MFARequired: true, Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// Verify goes through the login throttle like the password step, otherwise a
+// challenge token would allow guessing the six digit code without limit.
+func (s *mfaService) Verify(ctx context.Context, req VerifyMFARequest) (*UserResponseWithToken, error) {
+	var claims challengeClaims
+	if err := s.signer.verify(req.Token, &claims); err != nil || time.Now().Unix() >= claims.Exp {
+		return nil, ErrInvalidMFAToken
+	}
+
+	user, err := s.repo.GetUserById(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, ErrInvalidMFAToken
+		}
+		return nil, err
+	}
+	if !user.IsMFAEnabled() {
+		return nil, ErrInvalidMFAToken
+	}
+
+	if err := s.throttle.Check(ctx, user.Email, clientFrom(ctx).IP); err != nil {
+		metrics.RecordLogin(metrics.LoginLocked)
+		return nil, err
+	}
+
+	if err := s.checkCode(ctx, user, req.Code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			metrics.RecordLogin(metrics.LoginFailure)
+			if err := s.throttle.RecordFailure(ctx, user.Email, clientFrom(ctx).IP); err != nil {
+				return nil, err
+			}
+		}
+		return nil, err
+	}
+
+	if err := s.throttle.RecordSuccess(ctx, user.Email); err != nil {
+		return nil, err
+	}
+
+	roles, err := s.verification.TokenRoles(user)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.tokens.IssueTokens(ctx, user.ID, roles)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.RecordLogin(metrics.LoginSuccess)
+	return user.ToResponseWithToken(tokens), nil
+}
+
+// checkCode accepts a TOTP code at most once and a recovery code exactly once.
+func (s *mfaService) checkCode(ctx context.Context, user *User, code string) error {
+	code = strings.TrimSpace(code)
+	if len(code) != int(otp.DigitsSix) {
+		return s.repo.ConsumeRecoveryCode(ctx, user.ID, hashRecoveryCode(code))
+	}
+
+	step, ok := matchTOTP(user.MFA.Secret, code, time.Now())
+	if !ok {
+		return ErrInvalidMFACode
+	}
+	return s.repo.MarkTOTPStepUsed(ctx, user.ID, step)
+}
+
+// matchTOTP returns the time step code belongs to, which the caller stores to
+// reject the same code a second time.
+func matchTOTP(secret, code string, now time.Time) (int64, bool) {
+	for skew := -totpSkew; skew <= totpSkew; skew++ {
+		at := now.Add(time.Duration(skew*totpPeriod) * time.Second)
+		expected, err := totp.GenerateCodeCustom(secret, at, totp.ValidateOpts{
+			Period:    totpPeriod,
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return at.Unix() / totpPeriod, true
+		}
+	}
+	return 0, false
+}
+
+// generateRecoveryCodes returns the codes to show the user, formatted as
+// xxxxx-xxxxx, and the hashes to store.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))[:10]
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode ignores case and separators, so codes can be typed the way
+// they were written down.
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return auth.HashToken(code)
+}
+
+func qrCodeDataURI(key *otp.Key) (string, error) {
+	img, err := key.Image(qrCodeSize, qrCodeSize)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
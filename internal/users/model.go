@@ -3,16 +3,35 @@ package users
 import (
 	"time"
 
+	"github.com/ritchie-gr8/7solution-be/internal/auth"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type User struct {
-	ID        primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
-	Name      string             `json:"name" bson:"name"`
-	Email     string             `json:"email" bson:"email"`
-	Password  string             `json:"password,omitempty" bson:"password"`
-	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
-	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
+	ID            primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Name          string             `json:"name" bson:"name"`
+	Email         string             `json:"email" bson:"email"`
+	Password      string             `json:"password,omitempty" bson:"password"`
+	Roles         []string           `json:"roles" bson:"roles"`
+	EmailVerified *bool              `json:"email_verified,omitempty" bson:"email_verified,omitempty"`
+	MFA           *MFA               `json:"-" bson:"mfa,omitempty"`
+	// Version starts at 1 and goes up with every change to what a
+	// UserResponse shows, so it doubles as the user's ETag.
+	Version   int64     `json:"version" bson:"version"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// MFA holds the TOTP settings of a user. PendingSecret is set between
+// enrollment and confirmation, Secret once MFA is enabled.
+type MFA struct {
+	Enabled       bool     `bson:"enabled"`
+	Secret        string   `bson:"secret,omitempty"`
+	PendingSecret string   `bson:"pending_secret,omitempty"`
+	RecoveryCodes []string `bson:"recovery_codes,omitempty"`
+	// LastUsedStep is the TOTP time step of the last accepted code, so a code
+	// cannot be replayed within its validity window.
+	LastUsedStep int64 `bson:"last_used_step,omitempty"`
 }
 
 type LoginUserRequest struct {
@@ -31,10 +50,82 @@ type UpdateUserRequest struct {
 	Email string `json:"email" validate:"required,email"`
 }
 
+type ConfirmMFARequest struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+type DisableMFARequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+type VerifyMFARequest struct {
+	Token string `json:"mfa_token" validate:"required"`
+	// Code is either a TOTP code or one of the recovery codes.
+	Code string `json:"code" validate:"required,max=20"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=6,max=50"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=6,max=50"`
+}
+
+// PasswordResetToken is stored by hash only. A token is spent once UsedAt is
+// set, either by a reset or by a newer reset request for the same user.
+type PasswordResetToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	TokenHash string             `bson:"token_hash"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	UsedAt    *time.Time         `bson:"used_at,omitempty"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
 type UserResponse struct {
-	ID    primitive.ObjectID `json:"id,omitempty"`
-	Name  string             `json:"name"`
-	Email string             `json:"email"`
+	ID            primitive.ObjectID `json:"id,omitempty"`
+	Name          string             `json:"name"`
+	Email         string             `json:"email"`
+	Roles         []string           `json:"roles"`
+	EmailVerified bool               `json:"email_verified"`
+	MFAEnabled    bool               `json:"mfa_enabled"`
+	Version       int64              `json:"version"`
+}
+
+type UserPageResponse struct {
+	Users      []*UserResponse
+	Total      int64
+	Limit      int
+	Offset     int
+	NextCursor string
+}
+
+type UserSearchResponse struct {
+	UserResponse
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+type MFAEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauth_uri"`
+	// QRCode is a PNG data URI of OtpauthURI for authenticator apps to scan.
+	QRCode string `json:"qr_code"`
+}
+
+type MFARecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
 }
 
 type UserResponseWithMessage struct {
@@ -42,37 +133,76 @@ type UserResponseWithMessage struct {
 	Message string `json:"message"`
 }
 
+// UserResponseWithToken leaves the token fields out when TokenResponse is nil,
+// e.g. after signing up while unverified accounts may not log in.
 type UserResponseWithToken struct {
 	UserResponse
-	Token string `json:"token"`
+	*TokenResponse
+}
+
+type TokenResponse struct {
+	Token                 string    `json:"token"`
+	TokenExpiresAt        time.Time `json:"token_expires_at"`
+	RefreshToken          string    `json:"refresh_token"`
+	RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at"`
+}
+
+// IsEmailVerified treats accounts that predate email verification, which have
+// no email_verified field, as verified so they are not locked out.
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerified == nil || *u.EmailVerified
+}
+
+func (u *User) IsMFAEnabled() bool {
+	return u.MFA != nil && u.MFA.Enabled
 }
 
 func (u *User) ToResponse() *UserResponse {
 	return &UserResponse{
-		ID:    u.ID,
-		Name:  u.Name,
-		Email: u.Email,
+		ID:            u.ID,
+		Name:          u.Name,
+		Email:         u.Email,
+		Roles:         auth.NormalizeRoles(u.Roles),
+		EmailVerified: u.IsEmailVerified(),
+		MFAEnabled:    u.IsMFAEnabled(),
+		Version:       u.Version,
 	}
 }
 
 func (u *User) ToResponseWithMessage(message string) *UserResponseWithMessage {
 	return &UserResponseWithMessage{
 		UserResponse: UserResponse{
-			ID:    u.ID,
-			Name:  u.Name,
-			Email: u.Email,
+			ID:            u.ID,
+			Name:          u.Name,
+			Email:         u.Email,
+			Roles:         auth.NormalizeRoles(u.Roles),
+			EmailVerified: u.IsEmailVerified(),
+			MFAEnabled:    u.IsMFAEnabled(),
+			Version:       u.Version,
 		},
 		Message: message,
 	}
 }
 
-func (u *User) ToResponseWithToken(token string) *UserResponseWithToken {
-	return &UserResponseWithToken{
+func (u *User) ToResponseWithToken(tokens *auth.TokenPair) *UserResponseWithToken {
+	res := &UserResponseWithToken{
 		UserResponse: UserResponse{
-			ID:    u.ID,
-			Name:  u.Name,
-			Email: u.Email,
+			ID:            u.ID,
+			Name:          u.Name,
+			Email:         u.Email,
+			Roles:         auth.NormalizeRoles(u.Roles),
+			EmailVerified: u.IsEmailVerified(),
+			MFAEnabled:    u.IsMFAEnabled(),
+			Version:       u.Version,
 		},
-		Token: token,
 	}
+	if tokens != nil {
+		res.TokenResponse = &TokenResponse{
+			Token:                 tokens.AccessToken,
+			TokenExpiresAt:        tokens.AccessTokenExpiresAt,
+			RefreshToken:          tokens.RefreshToken,
+			RefreshTokenExpiresAt: tokens.RefreshTokenExpiresAt,
+		}
+	}
+	return res
 }
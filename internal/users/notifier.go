@@ -0,0 +1,92 @@
+package users
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/ritchie-gr8/7solution-be/internal/mail"
+)
+
+// notifyTimeout bounds how long a notifier may take once the request that
+// triggered it has already been answered.
+const notifyTimeout = 30 * time.Second
+
+// Notice carries a link that lets a user act on their account, e.g. to reset
+// a password or verify an email address.
+type Notice struct {
+	User      *User
+	Token     string
+	Link      string
+	ExpiresAt time.Time
+	// Locale is the preferred language of the request that caused the
+	// notice, e.g. "th-TH". Empty means the notifier's default.
+	Locale string
+}
+
+// INotifier delivers account messages to users. Implementations decide on the
+// channel, e.g. email.
+type INotifier interface {
+	SendPasswordReset(ctx context.Context, notice *Notice) error
+	SendEmailVerification(ctx context.Context, notice *Notice) error
+}
+
+// Mail template names, see internal/mail/templates.
+const (
+	passwordResetTemplate     = "password_reset"
+	emailVerificationTemplate = "email_verification"
+)
+
+type mailNotifier struct {
+	mailer    mail.IMailer
+	templates *mail.Templates
+}
+
+func NewMailNotifier(mailer mail.IMailer, templates *mail.Templates) INotifier {
+	return &mailNotifier{mailer: mailer, templates: templates}
+}
+
+func (n *mailNotifier) SendPasswordReset(ctx context.Context, notice *Notice) error {
+	return n.send(ctx, passwordResetTemplate, notice)
+}
+
+func (n *mailNotifier) SendEmailVerification(ctx context.Context, notice *Notice) error {
+	return n.send(ctx, emailVerificationTemplate, notice)
+}
+
+func (n *mailNotifier) send(ctx context.Context, template string, notice *Notice) error {
+	msg, err := n.templates.Render(template, notice.Locale, map[string]any{
+		"Name":      notice.User.Name,
+		"Link":      notice.Link,
+		"ExpiresAt": notice.ExpiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	msg.To = []string{notice.User.Email}
+	return n.mailer.Send(ctx, msg)
+}
+
+// notifyAsync sends in the background so the response does not wait for, or
+// reveal anything through the timing of, the delivery.
+func notifyAsync(kind string, notice *Notice, send func(ctx context.Context, notice *Notice) error) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+
+		if err := send(ctx, notice); err != nil {
+			slog.Error("send notification failed", "kind", kind, "user_id", notice.User.ID.Hex(), "error", err)
+		}
+	}()
+}
+
+// linkWithToken appends the token to base as the token query parameter.
+func linkWithToken(base *url.URL, token string) string {
+	link := *base
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String()
+}
@@ -0,0 +1,199 @@
+package users
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+	DefaultSort     = "created_at"
+)
+
+// sortableFields lists the fields GET /v1/users can be sorted by. The field
+// names are shared by the JSON and the BSON representation of a user.
+var sortableFields = map[string]bool{
+	"created_at": true,
+	"name":       true,
+	"email":      true,
+}
+
+type ListUsersQuery struct {
+	Limit  int    `query:"limit"`
+	Offset int    `query:"offset"`
+	Cursor string `query:"cursor"`
+	Name   string `query:"name"`
+	Email  string `query:"email"`
+	Sort   string `query:"sort"`
+}
+
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ListUsersOptions is the validated form of ListUsersQuery that repositories
+// work with.
+type ListUsersOptions struct {
+	Limit  int
+	Offset int
+	Name   string
+	Email  string
+	Sort   []SortField
+	After  *PageCursor
+}
+
+// PageCursor points just past the last user of a page. It stores the sort key
+// of that user plus its id as a tie breaker, so the next page can be fetched
+// with a range query instead of skipping over everything before it.
+type PageCursor struct {
+	Sort   string   `json:"s"`
+	Values []string `json:"v"`
+	ID     string   `json:"id"`
+}
+
+type UserPage struct {
+	Users      []User
+	Total      int64
+	NextCursor string
+}
+
+func (q ListUsersQuery) Parse() (*ListUsersOptions, error) {
+	opts := &ListUsersOptions{
+		Limit:  q.Limit,
+		Offset: q.Offset,
+		Name:   strings.TrimSpace(q.Name),
+		Email:  strings.TrimSpace(q.Email),
+	}
+
+	switch {
+	case opts.Limit < 0:
+		return nil, ErrInvalidPageSize
+	case opts.Limit == 0:
+		opts.Limit = DefaultPageSize
+	case opts.Limit > MaxPageSize:
+		opts.Limit = MaxPageSize
+	}
+
+	if opts.Offset < 0 || (opts.Offset > 0 && q.Cursor != "") {
+		return nil, ErrInvalidOffset
+	}
+
+	sortSpec := q.Sort
+	if sortSpec == "" {
+		sortSpec = DefaultSort
+	}
+	sort, err := parseSort(sortSpec)
+	if err != nil {
+		return nil, err
+	}
+	opts.Sort = sort
+
+	if q.Cursor != "" {
+		after, err := decodeCursor(q.Cursor)
+		if err != nil || after.Sort != opts.SortSpec() || len(after.Values) != len(sort) {
+			return nil, ErrInvalidCursor
+		}
+		opts.After = after
+
+		if _, err := opts.CursorID(); err != nil {
+			return nil, err
+		}
+		for i := range sort {
+			if _, err := opts.CursorValue(i); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return opts, nil
+}
+
+// SortSpec renders the sort back into its query string form, e.g. "created_at,-name".
+func (o *ListUsersOptions) SortSpec() string {
+	parts := make([]string, len(o.Sort))
+	for i, field := range o.Sort {
+		parts[i] = field.Field
+		if field.Desc {
+			parts[i] = "-" + field.Field
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// CursorValue returns the i-th sort value of the cursor converted back to the
+// type of the field it belongs to.
+func (o *ListUsersOptions) CursorValue(i int) (any, error) {
+	raw := o.After.Values[i]
+	if o.Sort[i].Field == "created_at" {
+		t, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		return t, nil
+	}
+	return raw, nil
+}
+
+func (o *ListUsersOptions) CursorID() (primitive.ObjectID, error) {
+	id, err := primitive.ObjectIDFromHex(o.After.ID)
+	if err != nil {
+		return primitive.NilObjectID, ErrInvalidCursor
+	}
+	return id, nil
+}
+
+// NextCursor builds the cursor that continues after user.
+func (o *ListUsersOptions) NextCursor(user *User) string {
+	cursor := PageCursor{
+		Sort:   o.SortSpec(),
+		Values: make([]string, len(o.Sort)),
+		ID:     user.ID.Hex(),
+	}
+	for i, field := range o.Sort {
+		switch field.Field {
+		case "created_at":
+			cursor.Values[i] = user.CreatedAt.UTC().Format(time.RFC3339Nano)
+		case "name":
+			cursor.Values[i] = user.Name
+		case "email":
+			cursor.Values[i] = user.Email
+		}
+	}
+
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func parseSort(spec string) ([]SortField, error) {
+	var fields []SortField
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		field := SortField{Field: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+		if !sortableFields[field.Field] || seen[field.Field] {
+			return nil, ErrInvalidSort
+		}
+		seen[field.Field] = true
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func decodeCursor(raw string) (*PageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor PageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,133 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/ritchie-gr8/7solution-be/internal/auth"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type IPasswordService interface {
+	ChangePassword(ctx context.Context, id string, req ChangePasswordRequest) (*UserResponseWithToken, error)
+	ForgotPassword(ctx context.Context, req ForgotPasswordRequest) error
+	ResetPassword(ctx context.Context, req ResetPasswordRequest) error
+}
+
+type passwordService struct {
+	users          IUserRepository
+	resets         IPasswordResetRepository
+	tokens         auth.ITokenService
+	notifier       INotifier
+	resetUrl       *url.URL
+	resetExpiresAt time.Duration
+}
+
+func NewPasswordService(users IUserRepository, resets IPasswordResetRepository, tokens auth.ITokenService, notifier INotifier, resetUrl *url.URL, resetExpiresAt time.Duration) IPasswordService {
+	return &passwordService{
+		users:          users,
+		resets:         resets,
+		tokens:         tokens,
+		notifier:       notifier,
+		resetUrl:       resetUrl,
+		resetExpiresAt: resetExpiresAt,
+	}
+}
+
+// ChangePassword signs out every session of the user, so the caller gets a
+// fresh token pair back to stay logged in.
+func (s *passwordService) ChangePassword(ctx context.Context, id string, req ChangePasswordRequest) (*UserResponseWithToken, error) {
+	user, err := s.users.GetUserById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := comparePassword(user.Password, req.CurrentPassword); err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return nil, ErrIncorrectPassword
+		}
+		return nil, err
+	}
+
+	if err := s.setPassword(ctx, user.ID, req.NewPassword); err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.tokens.IssueTokens(ctx, user.ID, user.Roles)
+	if err != nil {
+		return nil, err
+	}
+	return user.ToResponseWithToken(tokens), nil
+}
+
+// ForgotPassword succeeds whether or not the email belongs to an account, and
+// the notifier runs after the response, so callers cannot probe for accounts.
+// A new request invalidates the reset links sent before it.
+func (s *passwordService) ForgotPassword(ctx context.Context, req ForgotPasswordRequest) error {
+	user, err := s.users.GetUserByEmail(ctx, req.Email)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if err := s.resets.InvalidateUserResetTokens(ctx, user.ID); err != nil {
+		return err
+	}
+
+	token, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	stored := &PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: now.Add(s.resetExpiresAt),
+		CreatedAt: now,
+	}
+	if err := s.resets.CreateResetToken(ctx, stored); err != nil {
+		return err
+	}
+
+	notifyAsync("password reset", &Notice{
+		User:      user,
+		Token:     token,
+		Link:      linkWithToken(s.resetUrl, token),
+		ExpiresAt: stored.ExpiresAt,
+		Locale:    clientFrom(ctx).Locale,
+	}, s.notifier.SendPasswordReset)
+	return nil
+}
+
+func (s *passwordService) ResetPassword(ctx context.Context, req ResetPasswordRequest) error {
+	stored, err := s.resets.ConsumeResetToken(ctx, auth.HashToken(req.Token))
+	if err != nil {
+		return err
+	}
+
+	if err := s.setPassword(ctx, stored.UserID, req.NewPassword); err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *passwordService) setPassword(ctx context.Context, userId primitive.ObjectID, password string) error {
+	hashPassword, err := hashPassword(password)
+	if err != nil {
+		return ErrHashingPassword
+	}
+
+	if err := s.users.UpdatePassword(ctx, userId, hashPassword); err != nil {
+		return err
+	}
+	return s.tokens.RevokeUserTokens(ctx, userId)
+}
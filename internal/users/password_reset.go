@@ -0,0 +1,135 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	databases "github.com/ritchie-gr8/7solution-be/internal/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type IPasswordResetRepository interface {
+	CreateResetToken(ctx context.Context, token *PasswordResetToken) error
+	ConsumeResetToken(ctx context.Context, hash string) (*PasswordResetToken, error)
+	InvalidateUserResetTokens(ctx context.Context, userID primitive.ObjectID) error
+}
+
+type passwordResetRepository struct {
+	collection MongoCollection
+}
+
+func NewPasswordResetRepository(db *mongo.Client) IPasswordResetRepository {
+	database := db.Database("userdb")
+	collection := database.Collection("password_reset_tokens")
+	databases.EnsureIndexes(collection, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return &passwordResetRepository{collection: newInstrumentedCollection(collection)}
+}
+
+func NewPasswordResetRepositoryWithCollection(collection MongoCollection) IPasswordResetRepository {
+	return &passwordResetRepository{collection: collection}
+}
+
+func (r *passwordResetRepository) CreateResetToken(ctx context.Context, token *PasswordResetToken) error {
+	result, err := r.collection.InsertOne(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	token.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// ConsumeResetToken marks the token as used and returns it. Matching and
+// marking happen in one update, so a token can only ever be redeemed once.
+func (r *passwordResetRepository) ConsumeResetToken(ctx context.Context, hash string) (*PasswordResetToken, error) {
+	now := time.Now()
+
+	var token PasswordResetToken
+	err := r.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"token_hash": hash, "used_at": nil, "expires_at": bson.M{"$gt": now}},
+		bson.M{"$set": bson.M{"used_at": now}},
+	).Decode(&token)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrInvalidResetToken
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *passwordResetRepository) InvalidateUserResetTokens(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{"user_id": userID, "used_at": nil},
+		bson.M{"$set": bson.M{"used_at": time.Now()}},
+	)
+	return err
+}
+
+type memoryPasswordResetRepository struct {
+	mu     sync.Mutex
+	tokens map[string]*PasswordResetToken
+}
+
+// NewMemoryPasswordResetRepository keeps reset tokens in process, by hash.
+func NewMemoryPasswordResetRepository() IPasswordResetRepository {
+	return &memoryPasswordResetRepository{tokens: make(map[string]*PasswordResetToken)}
+}
+
+func (r *memoryPasswordResetRepository) CreateResetToken(ctx context.Context, token *PasswordResetToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for hash, stored := range r.tokens {
+		if !stored.ExpiresAt.After(now) {
+			delete(r.tokens, hash)
+		}
+	}
+
+	token.ID = primitive.NewObjectID()
+	stored := *token
+	r.tokens[token.TokenHash] = &stored
+	return nil
+}
+
+// ConsumeResetToken marks the token as used and returns it. Matching and
+// marking happen under one lock, so a token can only ever be redeemed once.
+func (r *memoryPasswordResetRepository) ConsumeResetToken(ctx context.Context, hash string) (*PasswordResetToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	token, ok := r.tokens[hash]
+	if !ok || token.UsedAt != nil || !token.ExpiresAt.After(now) {
+		return nil, ErrInvalidResetToken
+	}
+
+	token.UsedAt = &now
+	consumed := *token
+	return &consumed, nil
+}
+
+func (r *memoryPasswordResetRepository) InvalidateUserResetTokens(ctx context.Context, userID primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, token := range r.tokens {
+		if token.UserID == userID && token.UsedAt == nil {
+			token.UsedAt = &now
+		}
+	}
+	return nil
+}
@@ -0,0 +1,448 @@
+package users
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/ritchie-gr8/7solution-be/internal/auth"
+	databases "github.com/ritchie-gr8/7solution-be/internal/database"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//go:embed postgres_schema.sql
+var postgresSchema string
+
+// pgUniqueViolation is the SQLSTATE of a unique constraint violation.
+const pgUniqueViolation = "23505"
+
+const userColumns = `id, name, email, password, roles, email_verified,
+	mfa_enabled, mfa_secret, mfa_pending_secret, mfa_recovery_codes, mfa_last_used_step,
+	version, created_at, updated_at`
+
+// PostgresDB is the part of *pgxpool.Pool the repository uses, so tests can
+// run it inside a transaction or a connection of their own.
+type PostgresDB interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+type postgresUserRepository struct {
+	db PostgresDB
+}
+
+// NewPostgresUserRepository stores users in the users table of the pool's
+// database and creates the table if it does not exist.
+func NewPostgresUserRepository(pool *pgxpool.Pool) IUserRepository {
+	databases.EnsureSchema(pool, "users", postgresSchema)
+	return &postgresUserRepository{db: pool}
+}
+
+func NewPostgresUserRepositoryWithDB(db PostgresDB) IUserRepository {
+	return &postgresUserRepository{db: db}
+}
+
+func (r *postgresUserRepository) GetUsers(ctx context.Context, opts *ListUsersOptions) (*UserPage, error) {
+	var q pgQuery
+	if opts.Name != "" {
+		q.where = append(q.where, "name ~* "+q.arg(regexp.QuoteMeta(opts.Name)))
+	}
+	if opts.Email != "" {
+		q.where = append(q.where, "lower(email) = lower("+q.arg(opts.Email)+")")
+	}
+
+	var total int64
+	if err := r.db.QueryRow(ctx, "SELECT count(*) FROM users"+q.whereClause(), q.args...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	if opts.After != nil {
+		after, err := q.cursorCondition(opts)
+		if err != nil {
+			return nil, err
+		}
+		q.where = append(q.where, after)
+	}
+
+	// One extra row tells whether there is a next page.
+	sql := "SELECT " + userColumns + " FROM users" + q.whereClause() +
+		" ORDER BY " + orderBy(opts.Sort) +
+		" LIMIT " + q.arg(opts.Limit+1) + " OFFSET " + q.arg(opts.Offset)
+
+	rows, err := r.db.Query(ctx, sql, q.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (User, error) {
+		user, err := scanUser(row)
+		if err != nil {
+			return User{}, err
+		}
+		return *user, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &UserPage{Total: total}
+	if len(users) > opts.Limit {
+		users = users[:opts.Limit]
+		page.NextCursor = opts.NextCursor(&users[len(users)-1])
+	}
+	page.Users = users
+	return page, nil
+}
+
+func (r *postgresUserRepository) GetUserById(ctx context.Context, id string) (*User, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+
+	user, err := scanUser(r.db.QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", objectID.Hex()))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *postgresUserRepository) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	user, err := scanUser(r.db.QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE email = $1", email))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// SearchUsers ranks users by relevance. Text mode matches whole words (with
+// stemming) against the search column; websearch_to_tsquery accepts quotes
+// and negated words like MongoDB's $search. Prefix mode scores matches the
+// same way as the MongoDB repository.
+func (r *postgresUserRepository) SearchUsers(ctx context.Context, opts *SearchUsersOptions) ([]UserSearchHit, error) {
+	var sql string
+	var args []any
+	switch opts.Mode {
+	case SearchModePrefix:
+		exact, prefix, wordPrefix := prefixPatterns(opts.Query)
+		sql = `SELECT ` + userColumns + `, (
+				CASE WHEN name ~* $1 THEN 8 ELSE 0 END +
+				CASE WHEN name ~* $2 THEN 4 ELSE 0 END +
+				CASE WHEN name ~* $3 THEN 2 ELSE 0 END +
+				CASE WHEN email ~* $1 THEN 6 ELSE 0 END +
+				CASE WHEN email ~* $2 THEN 1 ELSE 0 END
+			)::float8 AS score
+			FROM users
+			WHERE name ~* $3 OR email ~* $2
+			ORDER BY score DESC, id
+			LIMIT $4`
+		args = []any{exact, prefix, wordPrefix, opts.Limit}
+	default:
+		sql = `SELECT ` + userColumns + `, ts_rank(search, query)::float8 AS score
+			FROM users, websearch_to_tsquery('english', $1) AS query
+			WHERE search @@ query
+			ORDER BY score DESC, id
+			LIMIT $2`
+		args = []any{opts.Query, opts.Limit}
+	}
+
+	rows, err := r.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (UserSearchHit, error) {
+		var hit UserSearchHit
+		user, err := scanUser(row, &hit.Score)
+		if err != nil {
+			return hit, err
+		}
+		hit.User = *user
+		return hit, nil
+	})
+}
+
+func (r *postgresUserRepository) CreateUser(ctx context.Context, userReq CreateUserRequest) (*User, error) {
+	// PostgreSQL keeps microseconds, so the returned user matches what a
+	// later read returns.
+	now := time.Now().Truncate(time.Microsecond)
+	user := User{
+		ID:            primitive.NewObjectID(),
+		Name:          userReq.Name,
+		Email:         userReq.Email,
+		Password:      userReq.Password,
+		Roles:         []string{auth.RoleUser},
+		EmailVerified: new(bool),
+		Version:       1,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	_, err := r.db.Exec(ctx,
+		`INSERT INTO users (id, name, email, password, roles, email_verified, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		user.ID.Hex(), user.Name, user.Email, user.Password, user.Roles, *user.EmailVerified, user.CreatedAt, user.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrEmailAlreadyExists
+		}
+		return nil, ErrInsertFailed
+	}
+	return &user, nil
+}
+
+func (r *postgresUserRepository) UpdateUser(ctx context.Context, id string, userReq UpdateUserRequest, version int64) (*User, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+
+	user, err := scanUser(r.db.QueryRow(ctx,
+		`UPDATE users SET name = $2, email = $3, version = version + 1, updated_at = now(),
+			email_verified = CASE WHEN email = $3 THEN email_verified ELSE false END
+		WHERE id = $1 AND ($4::bigint = 0 OR version = $4)
+		RETURNING `+userColumns,
+		objectID.Hex(), userReq.Name, userReq.Email, version))
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, r.notMatched(ctx, objectID, version, ErrUpdateFailed)
+		case isUniqueViolation(err):
+			return nil, ErrEmailAlreadyExists
+		}
+		return nil, ErrUpdateFailed
+	}
+	return user, nil
+}
+
+// UpdatePassword stores an already hashed password.
+func (r *postgresUserRepository) UpdatePassword(ctx context.Context, id primitive.ObjectID, password string) error {
+	return r.update(ctx,
+		"UPDATE users SET password = $2, updated_at = now() WHERE id = $1",
+		[]any{id.Hex(), password}, ErrUserNotFound)
+}
+
+// SetEmailVerified only matches while the user still has the given email, so
+// a link sent to a previous address cannot verify the current one.
+func (r *postgresUserRepository) SetEmailVerified(ctx context.Context, id primitive.ObjectID, email string, verified bool) error {
+	return r.update(ctx,
+		"UPDATE users SET email_verified = $3, version = version + 1, updated_at = now() WHERE id = $1 AND email = $2",
+		[]any{id.Hex(), email, verified}, ErrUserNotFound)
+}
+
+func (r *postgresUserRepository) SetPendingMFASecret(ctx context.Context, id primitive.ObjectID, secret string) error {
+	return r.update(ctx,
+		"UPDATE users SET mfa_enabled = coalesce(mfa_enabled, false), mfa_pending_secret = $2 WHERE id = $1",
+		[]any{id.Hex(), secret}, ErrUserNotFound)
+}
+
+// EnableMFA only matches while the pending secret is still the one that was
+// confirmed, so a concurrent re-enrollment cannot be enabled unconfirmed.
+func (r *postgresUserRepository) EnableMFA(ctx context.Context, id primitive.ObjectID, secret string, recoveryCodes []string) error {
+	return r.update(ctx,
+		`UPDATE users SET mfa_enabled = true, mfa_secret = $2, mfa_recovery_codes = $3,
+			mfa_pending_secret = '', mfa_last_used_step = 0, version = version + 1
+		WHERE id = $1 AND mfa_pending_secret = $2 AND mfa_pending_secret <> ''`,
+		[]any{id.Hex(), secret, recoveryCodes}, ErrMFANotEnrolled)
+}
+
+func (r *postgresUserRepository) DisableMFA(ctx context.Context, id primitive.ObjectID) error {
+	return r.update(ctx,
+		`UPDATE users SET mfa_enabled = NULL, mfa_secret = '', mfa_pending_secret = '',
+			mfa_recovery_codes = '{}', mfa_last_used_step = 0, version = version + 1
+		WHERE id = $1`,
+		[]any{id.Hex()}, ErrUserNotFound)
+}
+
+// MarkTOTPStepUsed fails with ErrInvalidMFACode when a code of the same or a
+// later step was already accepted, which rejects replayed codes.
+func (r *postgresUserRepository) MarkTOTPStepUsed(ctx context.Context, id primitive.ObjectID, step int64) error {
+	return r.update(ctx,
+		"UPDATE users SET mfa_last_used_step = $2 WHERE id = $1 AND mfa_last_used_step < $2",
+		[]any{id.Hex(), step}, ErrInvalidMFACode)
+}
+
+// ConsumeRecoveryCode removes the code in the same update that finds it, so
+// each recovery code works once.
+func (r *postgresUserRepository) ConsumeRecoveryCode(ctx context.Context, id primitive.ObjectID, hash string) error {
+	return r.update(ctx,
+		"UPDATE users SET mfa_recovery_codes = array_remove(mfa_recovery_codes, $2) WHERE id = $1 AND $2 = ANY(mfa_recovery_codes)",
+		[]any{id.Hex(), hash}, ErrInvalidMFACode)
+}
+
+// update runs a single row UPDATE and returns notMatched when no row matched.
+func (r *postgresUserRepository) update(ctx context.Context, sql string, args []any, notMatched error) error {
+	tag, err := r.db.Exec(ctx, sql, args...)
+	if err != nil {
+		return ErrUpdateFailed
+	}
+	if tag.RowsAffected() == 0 {
+		return notMatched
+	}
+	return nil
+}
+
+func (r *postgresUserRepository) DeleteUser(ctx context.Context, id string, version int64) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrInvalidID
+	}
+
+	tag, err := r.db.Exec(ctx, "DELETE FROM users WHERE id = $1 AND ($2::bigint = 0 OR version = $2)", objectID.Hex(), version)
+	if err != nil {
+		return ErrDeleteFailed
+	}
+
+	if tag.RowsAffected() == 0 {
+		return r.notMatched(ctx, objectID, version, ErrDeleteFailed)
+	}
+	return nil
+}
+
+// notMatched tells apart a user that is gone from one at another version
+// after a write checking the version matched no row.
+func (r *postgresUserRepository) notMatched(ctx context.Context, id primitive.ObjectID, version int64, failed error) error {
+	if version == 0 {
+		return ErrUserNotFound
+	}
+
+	var exists bool
+	if err := r.db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)", id.Hex()).Scan(&exists); err != nil {
+		return failed
+	}
+	if exists {
+		return ErrVersionConflict
+	}
+	return ErrUserNotFound
+}
+
+func (r *postgresUserRepository) CountUsers(ctx context.Context) (int64, error) {
+	var count int64
+	if err := r.db.QueryRow(ctx, "SELECT count(*) FROM users").Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// scanUser reads a row selected with userColumns, followed by extra columns
+// scanned into extra.
+func scanUser(row pgx.Row, extra ...any) (*User, error) {
+	var (
+		user       User
+		id         string
+		mfaEnabled *bool
+		mfa        MFA
+	)
+	dest := append([]any{
+		&id, &user.Name, &user.Email, &user.Password, &user.Roles, &user.EmailVerified,
+		&mfaEnabled, &mfa.Secret, &mfa.PendingSecret, &mfa.RecoveryCodes, &mfa.LastUsedStep,
+		&user.Version, &user.CreatedAt, &user.UpdatedAt,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("user: invalid id %q in postgres: %w", id, err)
+	}
+	user.ID = objectID
+
+	if mfaEnabled != nil {
+		mfa.Enabled = *mfaEnabled
+		if len(mfa.RecoveryCodes) == 0 {
+			mfa.RecoveryCodes = nil
+		}
+		user.MFA = &mfa
+	}
+	return &user, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation
+}
+
+// pgQuery collects WHERE conditions and their numbered parameters.
+type pgQuery struct {
+	where []string
+	args  []any
+}
+
+// arg adds a parameter and returns its placeholder.
+func (q *pgQuery) arg(value any) string {
+	q.args = append(q.args, value)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+func (q *pgQuery) whereClause() string {
+	if len(q.where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(q.where, " AND ")
+}
+
+// cursorCondition matches rows that sort after the cursor, i.e. for a sort of
+// (a, b, id): a > va OR (a = va AND b > vb) OR (a = va AND b = vb AND id > id0).
+func (q *pgQuery) cursorCondition(opts *ListUsersOptions) (string, error) {
+	id, err := opts.CursorID()
+	if err != nil {
+		return "", err
+	}
+
+	var clauses []string
+	var equal []string
+	for i, field := range opts.Sort {
+		value, err := opts.CursorValue(i)
+		if err != nil {
+			return "", err
+		}
+
+		op := " > "
+		if field.Desc {
+			op = " < "
+		}
+
+		placeholder := q.arg(value)
+		clauses = append(clauses, conjunction(equal, field.Field+op+placeholder))
+		equal = append(equal, field.Field+" = "+placeholder)
+	}
+	clauses = append(clauses, conjunction(equal, "id > "+q.arg(id.Hex())))
+
+	return "(" + strings.Join(clauses, " OR ") + ")", nil
+}
+
+// conjunction ANDs the conditions in equal with last.
+func conjunction(equal []string, last string) string {
+	conditions := append(append([]string{}, equal...), last)
+	return "(" + strings.Join(conditions, " AND ") + ")"
+}
+
+// orderBy is the ORDER BY list for sort. Sort fields are checked against
+// sortableFields when the query is parsed, so they are safe to inline.
+func orderBy(sort []SortField) string {
+	parts := make([]string, 0, len(sort)+1)
+	for _, field := range sort {
+		if field.Desc {
+			parts = append(parts, field.Field+" DESC")
+		} else {
+			parts = append(parts, field.Field+" ASC")
+		}
+	}
+	return strings.Join(append(parts, "id ASC"), ", ")
+}
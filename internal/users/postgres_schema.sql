@@ -0,0 +1,35 @@
+-- Users in PostgreSQL mirror the MongoDB documents. Ids are ObjectID hex
+-- strings so both backends hand out ids of the same shape, and the text
+-- columns compare bytewise (COLLATE "C") like MongoDB does, so sorting and
+-- page cursors behave the same.
+CREATE TABLE IF NOT EXISTS users (
+    id                 CHAR(24) COLLATE "C" PRIMARY KEY,
+    name               TEXT COLLATE "C" NOT NULL,
+    email              TEXT COLLATE "C" NOT NULL,
+    password           TEXT NOT NULL,
+    roles              TEXT[] NOT NULL DEFAULT '{}',
+    -- NULL for accounts that predate email verification.
+    email_verified     BOOLEAN,
+    -- NULL while the user has never started MFA enrollment.
+    mfa_enabled        BOOLEAN,
+    mfa_secret         TEXT NOT NULL DEFAULT '',
+    mfa_pending_secret TEXT NOT NULL DEFAULT '',
+    mfa_recovery_codes TEXT[] NOT NULL DEFAULT '{}',
+    mfa_last_used_step BIGINT NOT NULL DEFAULT 0,
+    version            BIGINT NOT NULL DEFAULT 1,
+    created_at         TIMESTAMPTZ NOT NULL,
+    updated_at         TIMESTAMPTZ NOT NULL,
+    -- Emails are split on @ and . so their parts are searchable as words,
+    -- and names weigh more than emails as in the MongoDB text index.
+    search             TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', name), 'A') ||
+        setweight(to_tsvector('english', translate(email, '@.', '  ')), 'B')
+    ) STORED,
+    CONSTRAINT users_email_key UNIQUE (email)
+);
+
+-- Tables created before users had a version.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+
+CREATE INDEX IF NOT EXISTS users_search_idx ON users USING GIN (search);
+CREATE INDEX IF NOT EXISTS users_created_at_idx ON users (created_at, id);
@@ -3,31 +3,56 @@ package users
 import (
 	"context"
 	"errors"
+	"regexp"
 	"time"
 
-	"github.com/gofiber/fiber/v2"
+	"github.com/ritchie-gr8/7solution-be/internal/auth"
+	databases "github.com/ritchie-gr8/7solution-be/internal/database"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// The backends DB_USER_STORE selects between. With StoreMemory everything
+// else that would live in MongoDB is kept in memory too.
+const (
+	StoreMongo    = "mongo"
+	StorePostgres = "postgres"
+	StoreMemory   = "memory"
+)
+
 type MongoCollection interface {
 	Find(ctx context.Context, filter any, opts ...*options.FindOptions) (*mongo.Cursor, error)
 	FindOne(ctx context.Context, filter any, opts ...*options.FindOneOptions) *mongo.SingleResult
 	InsertOne(ctx context.Context, document any, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error)
 	FindOneAndUpdate(ctx context.Context, filter any, update any, opts ...*options.FindOneAndUpdateOptions) *mongo.SingleResult
+	UpdateMany(ctx context.Context, filter any, update any, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
 	DeleteOne(ctx context.Context, filter any, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error)
 	CountDocuments(ctx context.Context, filter any, opts ...*options.CountOptions) (int64, error)
+	Aggregate(ctx context.Context, pipeline any, opts ...*options.AggregateOptions) (*mongo.Cursor, error)
 }
 
 type IUserRepository interface {
-	GetUsers(c *fiber.Ctx) ([]User, error)
-	GetUserById(c *fiber.Ctx, id string) (*User, error)
-	GetUserByEmail(c *fiber.Ctx, email string) (*User, error)
-	CreateUser(c *fiber.Ctx, user CreateUserRequest) (*User, error)
-	UpdateUser(c *fiber.Ctx, id string, user UpdateUserRequest) (*User, error)
-	DeleteUser(c *fiber.Ctx, id string) error
+	GetUsers(ctx context.Context, opts *ListUsersOptions) (*UserPage, error)
+	GetUserById(ctx context.Context, id string) (*User, error)
+	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	SearchUsers(ctx context.Context, opts *SearchUsersOptions) ([]UserSearchHit, error)
+	CreateUser(ctx context.Context, user CreateUserRequest) (*User, error)
+	// UpdateUser and DeleteUser fail with ErrVersionConflict when the user is
+	// no longer at version, e.g. because another request changed it after it
+	// was read. A version of 0 skips the check.
+	//
+	// UpdateUser marks the user as unverified when the email changes.
+	UpdateUser(ctx context.Context, id string, user UpdateUserRequest, version int64) (*User, error)
+	UpdatePassword(ctx context.Context, id primitive.ObjectID, password string) error
+	SetEmailVerified(ctx context.Context, id primitive.ObjectID, email string, verified bool) error
+	SetPendingMFASecret(ctx context.Context, id primitive.ObjectID, secret string) error
+	EnableMFA(ctx context.Context, id primitive.ObjectID, secret string, recoveryCodes []string) error
+	DisableMFA(ctx context.Context, id primitive.ObjectID) error
+	MarkTOTPStepUsed(ctx context.Context, id primitive.ObjectID, step int64) error
+	ConsumeRecoveryCode(ctx context.Context, id primitive.ObjectID, hash string) error
+	DeleteUser(ctx context.Context, id string, version int64) error
 	CountUsers(ctx context.Context) (int64, error)
 }
 
@@ -36,37 +61,79 @@ type userRepository struct {
 }
 
 func NewUserRepository(db *mongo.Client) IUserRepository {
-	database := db.Database("userdb")
+	return NewUserRepositoryWithDatabase(db.Database("userdb"))
+}
+
+// NewUserRepositoryWithDatabase lets tests keep their users in a database of
+// their own.
+func NewUserRepositoryWithDatabase(database *mongo.Database) IUserRepository {
 	collection := database.Collection("users")
-	return &userRepository{collection: collection}
+	databases.EnsureIndexes(collection, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "name", Value: "text"}, {Key: "email", Value: "text"}},
+			Options: options.Index().
+				SetName("users_text").
+				SetWeights(bson.D{{Key: "name", Value: 10}, {Key: "email", Value: 5}}),
+		},
+	})
+	return &userRepository{collection: newInstrumentedCollection(collection)}
 }
 
 func NewUserRepositoryWithCollection(collection MongoCollection) IUserRepository {
 	return &userRepository{collection: collection}
 }
 
-func (r *userRepository) GetUsers(c *fiber.Ctx) ([]User, error) {
-	cursor, err := r.collection.Find(c.Context(), bson.D{})
+func (r *userRepository) GetUsers(ctx context.Context, opts *ListUsersOptions) (*UserPage, error) {
+	filter := userListFilter(opts)
+
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	pageFilter := filter
+	if opts.After != nil {
+		after, err := cursorFilter(opts)
+		if err != nil {
+			return nil, err
+		}
+		pageFilter = bson.M{"$and": bson.A{filter, after}}
+	}
+
+	// One extra document tells whether there is a next page.
+	findOpts := options.Find().SetSort(sortDocument(opts.Sort)).SetLimit(int64(opts.Limit + 1))
+	if opts.Offset > 0 {
+		findOpts.SetSkip(int64(opts.Offset))
+	}
+
+	cursor, err := r.collection.Find(ctx, pageFilter, findOpts)
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(c.Context())
+	defer cursor.Close(ctx)
 
 	var users []User
-	if err := cursor.All(c.Context(), &users); err != nil {
+	if err := cursor.All(ctx, &users); err != nil {
 		return nil, err
 	}
-	return users, nil
+
+	page := &UserPage{Total: total}
+	if len(users) > opts.Limit {
+		users = users[:opts.Limit]
+		page.NextCursor = opts.NextCursor(&users[len(users)-1])
+	}
+	page.Users = users
+	return page, nil
 }
 
-func (r *userRepository) GetUserById(c *fiber.Ctx, id string) (*User, error) {
+func (r *userRepository) GetUserById(ctx context.Context, id string) (*User, error) {
 	var user User
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, ErrInvalidID
 	}
 
-	err = r.collection.FindOne(c.Context(), bson.M{"_id": objectID}).Decode(&user)
+	err = r.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&user)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, ErrUserNotFound
@@ -76,9 +143,9 @@ func (r *userRepository) GetUserById(c *fiber.Ctx, id string) (*User, error) {
 	return &user, nil
 }
 
-func (r *userRepository) GetUserByEmail(c *fiber.Ctx, email string) (*User, error) {
+func (r *userRepository) GetUserByEmail(ctx context.Context, email string) (*User, error) {
 	var user User
-	err := r.collection.FindOne(c.Context(), bson.M{"email": email}).Decode(&user)
+	err := r.collection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, ErrUserNotFound
@@ -88,20 +155,60 @@ func (r *userRepository) GetUserByEmail(c *fiber.Ctx, email string) (*User, erro
 	return &user, nil
 }
 
-func (r *userRepository) CreateUser(c *fiber.Ctx, userReq CreateUserRequest) (*User, error) {
-	if err := r.checkEmailUniqueness(c.Context(), userReq.Email); err != nil {
+// SearchUsers ranks users by relevance. Text mode uses the users_text index,
+// so it matches whole words (with stemming) in name and email. Prefix mode
+// matches the query at the start of a name word or of the email and scores
+// exact matches above prefixes and name matches above email matches.
+func (r *userRepository) SearchUsers(ctx context.Context, opts *SearchUsersOptions) ([]UserSearchHit, error) {
+	var pipeline mongo.Pipeline
+	switch opts.Mode {
+	case SearchModePrefix:
+		pipeline = prefixSearchPipeline(opts.Query)
+	default:
+		pipeline = mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"$text": bson.M{"$search": opts.Query}}}},
+			{{Key: "$addFields", Value: bson.M{"score": bson.M{"$meta": "textScore"}}}},
+		}
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "score", Value: -1}, {Key: "_id", Value: 1}}}},
+		bson.D{{Key: "$limit", Value: opts.Limit}},
+	)
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var hits []UserSearchHit
+	if err := cursor.All(ctx, &hits); err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
+
+func (r *userRepository) CreateUser(ctx context.Context, userReq CreateUserRequest) (*User, error) {
+	if err := r.checkEmailUniqueness(ctx, userReq.Email); err != nil {
 		return nil, err
 	}
 
 	user := User{
-		Name:      userReq.Name,
-		Email:     userReq.Email,
-		Password:  userReq.Password,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		Name:          userReq.Name,
+		Email:         userReq.Email,
+		Password:      userReq.Password,
+		Roles:         []string{auth.RoleUser},
+		EmailVerified: new(bool),
+		Version:       1,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
 	}
 
-	result, err := r.collection.InsertOne(c.Context(), user)
+	result, err := r.collection.InsertOne(ctx, user)
+	// The unique email index catches a signup racing past checkEmailUniqueness.
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrEmailAlreadyExists
+	}
 	if err != nil {
 		return nil, ErrInsertFailed
 	}
@@ -110,32 +217,45 @@ func (r *userRepository) CreateUser(c *fiber.Ctx, userReq CreateUserRequest) (*U
 	return &user, nil
 }
 
-func (r *userRepository) UpdateUser(c *fiber.Ctx, id string, userReq UpdateUserRequest) (*User, error) {
+func (r *userRepository) UpdateUser(ctx context.Context, id string, userReq UpdateUserRequest, version int64) (*User, error) {
 	var user User
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, ErrInvalidID
 	}
 
-	if err := r.checkEmailUniqueness(c.Context(), userReq.Email, objectID); err != nil {
+	if err := r.checkEmailUniqueness(ctx, userReq.Email, objectID); err != nil {
 		return nil, err
 	}
 
+	// A pipeline update compares the new email with the stored one, so a new
+	// address is marked unverified in the same write that stores it. The
+	// request values are literals, or a name starting with $ would be read as
+	// a field path.
 	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
 	err = r.collection.FindOneAndUpdate(
-		c.Context(),
-		bson.M{"_id": objectID},
-		bson.M{"$set": bson.M{
-			"name":      userReq.Name,
-			"email":     userReq.Email,
-			"updatedAt": time.Now(),
-		}},
+		ctx,
+		versionFilter(objectID, version),
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"name":       bson.M{"$literal": userReq.Name},
+			"email":      bson.M{"$literal": userReq.Email},
+			"updated_at": time.Now(),
+			"email_verified": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{"$email", bson.M{"$literal": userReq.Email}}},
+				"$email_verified",
+				false,
+			}},
+			"version": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$version", 0}}, 1}},
+		}}}},
 		opts,
 	).Decode(&user)
 
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
-			return nil, ErrUserNotFound
+			return nil, r.notMatched(ctx, objectID, version, ErrUpdateFailed)
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrEmailAlreadyExists
 		}
 
 		return nil, ErrUpdateFailed
@@ -143,23 +263,151 @@ func (r *userRepository) UpdateUser(c *fiber.Ctx, id string, userReq UpdateUserR
 	return &user, nil
 }
 
-func (r *userRepository) DeleteUser(c *fiber.Ctx, id string) error {
+// UpdatePassword stores an already hashed password.
+func (r *userRepository) UpdatePassword(ctx context.Context, id primitive.ObjectID, password string) error {
+	var user User
+	err := r.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{
+			"password":   password,
+			"updated_at": time.Now(),
+		}},
+	).Decode(&user)
+
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrUserNotFound
+		}
+		return ErrUpdateFailed
+	}
+	return nil
+}
+
+// SetEmailVerified only matches while the user still has the given email, so
+// a link sent to a previous address cannot verify the current one.
+func (r *userRepository) SetEmailVerified(ctx context.Context, id primitive.ObjectID, email string, verified bool) error {
+	var user User
+	err := r.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": id, "email": email},
+		bson.M{
+			"$set": bson.M{
+				"email_verified": verified,
+				"updated_at":     time.Now(),
+			},
+			"$inc": bson.M{"version": 1},
+		},
+	).Decode(&user)
+
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrUserNotFound
+		}
+		return ErrUpdateFailed
+	}
+	return nil
+}
+
+func (r *userRepository) SetPendingMFASecret(ctx context.Context, id primitive.ObjectID, secret string) error {
+	return r.updateMFA(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"mfa.pending_secret": secret}}, ErrUserNotFound)
+}
+
+// EnableMFA only matches while the pending secret is still the one that was
+// confirmed, so a concurrent re-enrollment cannot be enabled unconfirmed.
+func (r *userRepository) EnableMFA(ctx context.Context, id primitive.ObjectID, secret string, recoveryCodes []string) error {
+	return r.updateMFA(ctx,
+		bson.M{"_id": id, "mfa.pending_secret": secret},
+		bson.M{
+			"$set": bson.M{
+				"mfa.enabled":        true,
+				"mfa.secret":         secret,
+				"mfa.recovery_codes": recoveryCodes,
+			},
+			"$unset": bson.M{"mfa.pending_secret": "", "mfa.last_used_step": ""},
+			"$inc":   bson.M{"version": 1},
+		},
+		ErrMFANotEnrolled)
+}
+
+func (r *userRepository) DisableMFA(ctx context.Context, id primitive.ObjectID) error {
+	return r.updateMFA(ctx, bson.M{"_id": id}, bson.M{"$unset": bson.M{"mfa": ""}, "$inc": bson.M{"version": 1}}, ErrUserNotFound)
+}
+
+// MarkTOTPStepUsed fails with ErrInvalidMFACode when a code of the same or a
+// later step was already accepted, which rejects replayed codes.
+func (r *userRepository) MarkTOTPStepUsed(ctx context.Context, id primitive.ObjectID, step int64) error {
+	return r.updateMFA(ctx,
+		bson.M{"_id": id, "$or": bson.A{
+			bson.M{"mfa.last_used_step": bson.M{"$lt": step}},
+			bson.M{"mfa.last_used_step": bson.M{"$exists": false}},
+		}},
+		bson.M{"$set": bson.M{"mfa.last_used_step": step}},
+		ErrInvalidMFACode)
+}
+
+// ConsumeRecoveryCode removes the code in the same update that finds it, so
+// each recovery code works once.
+func (r *userRepository) ConsumeRecoveryCode(ctx context.Context, id primitive.ObjectID, hash string) error {
+	return r.updateMFA(ctx,
+		bson.M{"_id": id, "mfa.recovery_codes": hash},
+		bson.M{"$pull": bson.M{"mfa.recovery_codes": hash}},
+		ErrInvalidMFACode)
+}
+
+func (r *userRepository) updateMFA(ctx context.Context, filter bson.M, update bson.M, notMatched error) error {
+	var user User
+	err := r.collection.FindOneAndUpdate(ctx, filter, update).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return notMatched
+		}
+		return ErrUpdateFailed
+	}
+	return nil
+}
+
+func (r *userRepository) DeleteUser(ctx context.Context, id string, version int64) error {
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return ErrInvalidID
 	}
 
-	result, err := r.collection.DeleteOne(c.Context(), bson.M{"_id": objectID})
+	result, err := r.collection.DeleteOne(ctx, versionFilter(objectID, version))
 	if err != nil {
 		return ErrDeleteFailed
 	}
 
 	if result.DeletedCount == 0 {
-		return ErrUserNotFound
+		return r.notMatched(ctx, objectID, version, ErrDeleteFailed)
 	}
 	return nil
 }
 
+func versionFilter(id primitive.ObjectID, version int64) bson.M {
+	if version == 0 {
+		return bson.M{"_id": id}
+	}
+	return bson.M{"_id": id, "version": version}
+}
+
+// notMatched tells apart a user that is gone from one at another version
+// after a write filtered by versionFilter matched nothing.
+func (r *userRepository) notMatched(ctx context.Context, id primitive.ObjectID, version int64, failed error) error {
+	if version == 0 {
+		return ErrUserNotFound
+	}
+
+	count, err := r.collection.CountDocuments(ctx, bson.M{"_id": id})
+	if err != nil {
+		return failed
+	}
+	if count > 0 {
+		return ErrVersionConflict
+	}
+	return ErrUserNotFound
+}
+
 func (r *userRepository) CountUsers(ctx context.Context) (int64, error) {
 	count, err := r.collection.CountDocuments(ctx, bson.D{})
 	if err != nil {
@@ -194,3 +442,97 @@ func (r *userRepository) checkEmailUniqueness(ctx context.Context, email string,
 
 	return nil
 }
+
+func userListFilter(opts *ListUsersOptions) bson.M {
+	filter := bson.M{}
+	if opts.Name != "" {
+		filter["name"] = primitive.Regex{Pattern: regexp.QuoteMeta(opts.Name), Options: "i"}
+	}
+	if opts.Email != "" {
+		filter["email"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(opts.Email) + "$", Options: "i"}
+	}
+	return filter
+}
+
+func sortDocument(sort []SortField) bson.D {
+	doc := bson.D{}
+	for _, field := range sort {
+		direction := 1
+		if field.Desc {
+			direction = -1
+		}
+		doc = append(doc, bson.E{Key: field.Field, Value: direction})
+	}
+	return append(doc, bson.E{Key: "_id", Value: 1})
+}
+
+// cursorFilter matches documents that sort after the cursor, i.e. for a sort of
+// (a, b, _id): a > va OR (a = va AND b > vb) OR (a = va AND b = vb AND _id > id).
+func cursorFilter(opts *ListUsersOptions) (bson.M, error) {
+	id, err := opts.CursorID()
+	if err != nil {
+		return nil, err
+	}
+
+	var clauses bson.A
+	equal := bson.M{}
+	for i, field := range opts.Sort {
+		value, err := opts.CursorValue(i)
+		if err != nil {
+			return nil, err
+		}
+
+		op := "$gt"
+		if field.Desc {
+			op = "$lt"
+		}
+
+		clause := bson.M{field.Field: bson.M{op: value}}
+		for k, v := range equal {
+			clause[k] = v
+		}
+		clauses = append(clauses, clause)
+		equal[field.Field] = value
+	}
+
+	last := bson.M{"_id": bson.M{"$gt": id}}
+	for k, v := range equal {
+		last[k] = v
+	}
+	clauses = append(clauses, last)
+
+	return bson.M{"$or": clauses}, nil
+}
+
+// prefixPatterns returns the case-insensitive patterns prefix search scores
+// with: the whole value, the start of the value and the start of a word.
+func prefixPatterns(query string) (exact, prefix, wordPrefix string) {
+	quoted := regexp.QuoteMeta(query)
+	return "^" + quoted + "$", "^" + quoted, `(^|\s)` + quoted
+}
+
+func prefixSearchPipeline(query string) mongo.Pipeline {
+	exact, prefix, wordPrefix := prefixPatterns(query)
+
+	matches := func(field, pattern string, score int) bson.M {
+		return bson.M{"$cond": bson.A{
+			bson.M{"$regexMatch": bson.M{"input": "$" + field, "regex": pattern, "options": "i"}},
+			score,
+			0,
+		}}
+	}
+
+	return mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"$or": bson.A{
+			bson.M{"name": primitive.Regex{Pattern: wordPrefix, Options: "i"}},
+			bson.M{"email": primitive.Regex{Pattern: prefix, Options: "i"}},
+		}}}},
+		{{Key: "$addFields", Value: bson.M{"score": bson.M{"$add": bson.A{
+			matches("name", exact, 8),
+			matches("name", prefix, 4),
+			matches("name", wordPrefix, 2),
+			matches("email", exact, 6),
+			matches("email", prefix, 1),
+		}}}}},
+	}
+}
@@ -0,0 +1,141 @@
+package users
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	SearchModeText   = "text"
+	SearchModePrefix = "prefix"
+
+	maxSearchQueryLength = 100
+)
+
+type SearchUsersQuery struct {
+	Q     string `query:"q"`
+	Mode  string `query:"mode"`
+	Limit int    `query:"limit"`
+}
+
+// SearchUsersOptions is the validated form of SearchUsersQuery.
+type SearchUsersOptions struct {
+	Query string
+	Mode  string
+	Limit int
+}
+
+// UserSearchHit is a user matched by a search together with its relevance.
+// Higher scores rank first.
+type UserSearchHit struct {
+	User  `bson:",inline"`
+	Score float64 `bson:"score"`
+}
+
+func (q SearchUsersQuery) Parse() (*SearchUsersOptions, error) {
+	opts := &SearchUsersOptions{
+		Query: strings.TrimSpace(q.Q),
+		Mode:  q.Mode,
+		Limit: q.Limit,
+	}
+
+	if opts.Query == "" || utf8.RuneCountInString(opts.Query) > maxSearchQueryLength {
+		return nil, ErrInvalidSearchQuery
+	}
+
+	switch opts.Mode {
+	case "":
+		opts.Mode = SearchModeText
+	case SearchModeText, SearchModePrefix:
+	default:
+		return nil, ErrInvalidSearchMode
+	}
+
+	switch {
+	case opts.Limit < 0:
+		return nil, ErrInvalidPageSize
+	case opts.Limit == 0:
+		opts.Limit = DefaultPageSize
+	case opts.Limit > MaxPageSize:
+		opts.Limit = MaxPageSize
+	}
+
+	return opts, nil
+}
+
+// Terms returns the words to highlight. In text mode negated words and quotes
+// follow MongoDB's $search syntax and are not highlighted; in prefix mode the
+// query is a single term.
+func (o *SearchUsersOptions) Terms() []string {
+	if o.Mode == SearchModePrefix {
+		return []string{o.Query}
+	}
+
+	var terms []string
+	for _, word := range strings.Fields(o.Query) {
+		if strings.HasPrefix(word, "-") {
+			continue
+		}
+		if word = strings.Trim(word, `"`); word != "" {
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// highlight wraps every case-insensitive occurrence of the terms in <mark>
+// tags. The rest of the text is HTML escaped so the result is safe to render.
+// ok is false when none of the terms occur in text.
+func highlight(text string, terms []string) (string, bool) {
+	type span struct{ start, end int }
+
+	var spans []span
+	for _, term := range terms {
+		re := regexp.MustCompile("(?i)" + regexp.QuoteMeta(term))
+		for _, loc := range re.FindAllStringIndex(text, -1) {
+			spans = append(spans, span{loc[0], loc[1]})
+		}
+	}
+	if len(spans) == 0 {
+		return "", false
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	var b strings.Builder
+	pos := 0
+	for _, s := range spans {
+		if s.end <= pos {
+			continue
+		}
+		if s.start < pos {
+			s.start = pos
+		}
+		b.WriteString(html.EscapeString(text[pos:s.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[s.start:s.end]))
+		b.WriteString("</mark>")
+		pos = s.end
+	}
+	b.WriteString(html.EscapeString(text[pos:]))
+	return b.String(), true
+}
+
+func (h *UserSearchHit) ToSearchResponse(terms []string) *UserSearchResponse {
+	highlights := make(map[string]string)
+	if name, ok := highlight(h.Name, terms); ok {
+		highlights["name"] = name
+	}
+	if email, ok := highlight(h.Email, terms); ok {
+		highlights["email"] = email
+	}
+
+	return &UserSearchResponse{
+		UserResponse: *h.ToResponse(),
+		Score:        h.Score,
+		Highlights:   highlights,
+	}
+}
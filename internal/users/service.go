@@ -4,44 +4,83 @@ import (
 	"context"
 	"errors" // Added for errors.Is
 
-	"github.com/gofiber/fiber/v2"
 	"github.com/ritchie-gr8/7solution-be/internal/auth"
+	"github.com/ritchie-gr8/7solution-be/internal/metrics"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type IUserService interface {
-	GetUsers(c *fiber.Ctx) ([]*UserResponse, error)
-	GetUserById(c *fiber.Ctx, id string) (*UserResponse, error)
-	Login(c *fiber.Ctx, user LoginUserRequest) (*UserResponseWithToken, error)
-	CreateUser(c *fiber.Ctx, user CreateUserRequest) (*UserResponseWithToken, error)
-	UpdateUser(c *fiber.Ctx, id string, user UpdateUserRequest) (*UserResponseWithMessage, error)
-	DeleteUser(c *fiber.Ctx, id string) error
+	GetUsers(ctx context.Context, query ListUsersQuery) (*UserPageResponse, error)
+	GetUserById(ctx context.Context, id string) (*UserResponse, error)
+	SearchUsers(ctx context.Context, query SearchUsersQuery) ([]*UserSearchResponse, error)
+	Login(ctx context.Context, user LoginUserRequest) (*UserResponseWithToken, error)
+	CreateUser(ctx context.Context, user CreateUserRequest) (*UserResponseWithToken, error)
+	// UpdateUser and DeleteUser fail with ErrPreconditionFailed when the user
+	// is no longer at version. A version of 0 skips the check.
+	UpdateUser(ctx context.Context, id string, user UpdateUserRequest, version int64) (*UserResponseWithMessage, error)
+	DeleteUser(ctx context.Context, id string, version int64) error
+	UnlockUser(ctx context.Context, id string) error
 	CountUsers(context context.Context) (int64, error)
 }
 
 type userService struct {
-	repo IUserRepository
-	jwt  auth.IAuthenticator
+	repo         IUserRepository
+	tokens       auth.ITokenService
+	verification IVerificationService
+	throttle     ILoginThrottle
+	mfa          IMFAService
 }
 
-func NewUserService(repo IUserRepository, jwt auth.IAuthenticator) IUserService {
-	return &userService{repo: repo, jwt: jwt}
+func NewUserService(repo IUserRepository, tokens auth.ITokenService, verification IVerificationService, throttle ILoginThrottle, mfa IMFAService) IUserService {
+	return newTracedUserService(&userService{repo: repo, tokens: tokens, verification: verification, throttle: throttle, mfa: mfa})
 }
 
-func (s *userService) GetUsers(c *fiber.Ctx) ([]*UserResponse, error) {
-	users, err := s.repo.GetUsers(c)
+func (s *userService) GetUsers(ctx context.Context, query ListUsersQuery) (*UserPageResponse, error) {
+	opts, err := query.Parse()
 	if err != nil {
 		return nil, err
 	}
-	var userResponses []*UserResponse
-	for _, user := range users {
+
+	page, err := s.repo.GetUsers(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	userResponses := make([]*UserResponse, 0, len(page.Users))
+	for _, user := range page.Users {
 		userResponses = append(userResponses, user.ToResponse())
 	}
-	return userResponses, nil
+	return &UserPageResponse{
+		Users:      userResponses,
+		Total:      page.Total,
+		Limit:      opts.Limit,
+		Offset:     opts.Offset,
+		NextCursor: page.NextCursor,
+	}, nil
 }
 
-func (s *userService) GetUserById(c *fiber.Ctx, id string) (*UserResponse, error) {
-	user, err := s.repo.GetUserById(c, id)
+func (s *userService) SearchUsers(ctx context.Context, query SearchUsersQuery) ([]*UserSearchResponse, error) {
+	opts, err := query.Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	hits, err := s.repo.SearchUsers(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	terms := opts.Terms()
+	results := make([]*UserSearchResponse, 0, len(hits))
+	for _, hit := range hits {
+		results = append(results, hit.ToSearchResponse(terms))
+	}
+	return results, nil
+}
+
+func (s *userService) GetUserById(ctx context.Context, id string) (*UserResponse, error) {
+	user, err := s.repo.GetUserById(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -49,66 +88,154 @@ func (s *userService) GetUserById(c *fiber.Ctx, id string) (*UserResponse, error
 	return user.ToResponse(), nil
 }
 
-func (s *userService) CreateUser(c *fiber.Ctx, userReq CreateUserRequest) (*UserResponseWithToken, error) {
-	hashPassword, err := bcrypt.GenerateFromPassword([]byte(userReq.Password), bcrypt.DefaultCost)
+func (s *userService) CreateUser(ctx context.Context, userReq CreateUserRequest) (*UserResponseWithToken, error) {
+	hashPassword, err := hashPassword(userReq.Password)
 	if err != nil {
 		return nil, err
 	}
-	userReq.Password = string(hashPassword)
+	userReq.Password = hashPassword
 
-	user, err := s.repo.CreateUser(c, userReq)
+	user, err := s.repo.CreateUser(ctx, userReq)
 	if err != nil {
 		return nil, err
 	}
 
-	claims := s.jwt.GenerateClaims(user.ID)
+	if err := s.verification.SendVerification(ctx, user); err != nil {
+		return nil, err
+	}
 
-	token, err := s.jwt.GenerateToken(claims)
+	roles, err := s.verification.TokenRoles(user)
 	if err != nil {
+		if errors.Is(err, ErrEmailNotVerified) {
+			return user.ToResponseWithToken(nil), nil
+		}
 		return nil, err
 	}
 
-	return user.ToResponseWithToken(token), nil
+	tokens, err := s.tokens.IssueTokens(ctx, user.ID, roles)
+	if err != nil {
+		return nil, err
+	}
+
+	return user.ToResponseWithToken(tokens), nil
 }
 
-func (s *userService) UpdateUser(c *fiber.Ctx, id string, userReq UpdateUserRequest) (*UserResponseWithMessage, error) {
-	user, err := s.repo.UpdateUser(c, id, userReq)
+// UpdateUser sends a verification link when the email address changed. The
+// repository marks the new address as unverified in the update itself.
+//
+// The update is made against the version that was read, so a change made in
+// between is never overwritten. It then fails with ErrPreconditionFailed when
+// the caller asked for a version, or ErrVersionConflict when it did not.
+func (s *userService) UpdateUser(ctx context.Context, id string, userReq UpdateUserRequest, version int64) (*UserResponseWithMessage, error) {
+	current, err := s.repo.GetUserById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && current.Version != version {
+		return nil, ErrPreconditionFailed
+	}
+
+	user, err := s.repo.UpdateUser(ctx, id, userReq, current.Version)
 	if err != nil {
+		if errors.Is(err, ErrVersionConflict) && version != 0 {
+			return nil, ErrPreconditionFailed
+		}
 		return nil, err
 	}
 
+	if current.Email != user.Email {
+		if err := s.verification.SendVerification(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+
 	return user.ToResponseWithMessage("User updated successfully"), nil
 }
 
-func (s *userService) DeleteUser(c *fiber.Ctx, id string) error {
-	return s.repo.DeleteUser(c, id)
+func (s *userService) DeleteUser(ctx context.Context, id string, version int64) error {
+	if err := s.repo.DeleteUser(ctx, id, version); err != nil {
+		if errors.Is(err, ErrVersionConflict) {
+			return ErrPreconditionFailed
+		}
+		return err
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrInvalidID
+	}
+	return s.tokens.RevokeUserTokens(ctx, objectID)
 }
 
-func (s *userService) Login(c *fiber.Ctx, userReq LoginUserRequest) (*UserResponseWithToken, error) {
-	user, err := s.repo.GetUserByEmail(c, userReq.Email)
+// Login checks the throttle before bcrypt, so a locked account costs no hashing
+// work. Unknown emails count as failures too, otherwise they could be probed
+// without limit. With MFA enabled the correct password only earns an
+// *MFAChallenge error, and the failure count is reset once the code checks out.
+func (s *userService) Login(ctx context.Context, userReq LoginUserRequest) (*UserResponseWithToken, error) {
+	if err := s.throttle.Check(ctx, userReq.Email, clientFrom(ctx).IP); err != nil {
+		metrics.RecordLogin(metrics.LoginLocked)
+		return nil, err
+	}
+
+	user, err := s.repo.GetUserByEmail(ctx, userReq.Email)
 	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			metrics.RecordLogin(metrics.LoginFailure)
+			if err := s.throttle.RecordFailure(ctx, userReq.Email, clientFrom(ctx).IP); err != nil {
+				return nil, err
+			}
+		}
 		return nil, err
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(userReq.Password)); err != nil {
+	if err := comparePassword(user.Password, userReq.Password); err != nil {
 		// If passwords don't match, return specific error
 		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			metrics.RecordLogin(metrics.LoginFailure)
+			if err := s.throttle.RecordFailure(ctx, userReq.Email, clientFrom(ctx).IP); err != nil {
+				return nil, err
+			}
 			return nil, ErrInvalidCredentials
 		}
 		// For other bcrypt errors, return the original error
 		return nil, err
 	}
 
-	claims := s.jwt.GenerateClaims(user.ID)
+	if user.IsMFAEnabled() {
+		challenge, err := s.mfa.Challenge(user)
+		if err != nil {
+			return nil, err
+		}
+		metrics.RecordLogin(metrics.LoginMFARequired)
+		return nil, challenge
+	}
 
-	token, err := s.jwt.GenerateToken(claims)
+	if err := s.throttle.RecordSuccess(ctx, userReq.Email); err != nil {
+		return nil, err
+	}
+
+	roles, err := s.verification.TokenRoles(user)
 	if err != nil {
 		return nil, err
 	}
 
-	return user.ToResponseWithToken(token), nil
+	tokens, err := s.tokens.IssueTokens(ctx, user.ID, roles)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.RecordLogin(metrics.LoginSuccess)
+	return user.ToResponseWithToken(tokens), nil
+}
+
+func (s *userService) UnlockUser(ctx context.Context, id string) error {
+	user, err := s.repo.GetUserById(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.throttle.Unlock(ctx, user.Email)
 }
 
-func (s *userService) CountUsers(c context.Context) (int64, error) {
-	return s.repo.CountUsers(c)
+func (s *userService) CountUsers(ctx context.Context) (int64, error) {
+	return s.repo.CountUsers(ctx)
 }
@@ -0,0 +1,466 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/ritchie-gr8/7solution-be/internal/auth"
+	"github.com/ritchie-gr8/7solution-be/internal/users"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The conformance suite always runs against the memory repository. The
+// database backends are skipped unless TEST_MONGO_URL or TEST_POSTGRES_URL is
+// set, e.g. to the databases of docker-compose.yml (see make
+// test_conformance). CI sets both and TEST_REQUIRE_DATABASES=true.
+
+// databaseURL returns the URL in env and skips the test when it is not set.
+// With TEST_REQUIRE_DATABASES=true a missing URL fails the test instead, so a
+// backend cannot go untested without anyone noticing.
+func databaseURL(t *testing.T, env, service string) string {
+	t.Helper()
+	if url := os.Getenv(env); url != "" {
+		return url
+	}
+
+	how := fmt.Sprintf("%s is not set: start the database with `docker-compose --profile postgres up -d %s` and run `make test_conformance`, or set %s to a database of your own", env, service, env)
+	if os.Getenv("TEST_REQUIRE_DATABASES") == "true" {
+		t.Fatal(how)
+	}
+	t.Skip(how)
+	return ""
+}
+
+func TestMemoryRepositoryConformance(t *testing.T) {
+	runRepositoryConformance(t, func(t *testing.T) users.IUserRepository {
+		repo, err := users.NewMemoryUserRepository("")
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		return repo
+	})
+}
+
+func TestMongoRepositoryConformance(t *testing.T) {
+	url := databaseURL(t, "TEST_MONGO_URL", "db")
+
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(url))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+
+	runRepositoryConformance(t, func(t *testing.T) users.IUserRepository {
+		database := client.Database("conformance_" + primitive.NewObjectID().Hex())
+		t.Cleanup(func() { database.Drop(context.Background()) })
+		return users.NewUserRepositoryWithDatabase(database)
+	})
+}
+
+func TestPostgresRepositoryConformance(t *testing.T) {
+	url := databaseURL(t, "TEST_POSTGRES_URL", "postgres")
+
+	admin, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	t.Cleanup(admin.Close)
+
+	// Every test gets a schema of its own, which the pool's search_path
+	// points at.
+	runRepositoryConformance(t, func(t *testing.T) users.IUserRepository {
+		schema := "conformance_" + primitive.NewObjectID().Hex()
+		if _, err := admin.Exec(context.Background(), "CREATE SCHEMA "+schema); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		cfg, err := pgxpool.ParseConfig(url)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		cfg.ConnConfig.RuntimeParams["search_path"] = schema
+		pool, err := pgxpool.NewWithConfig(context.Background(), cfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		t.Cleanup(func() {
+			pool.Close()
+			admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE")
+		})
+
+		return users.NewPostgresUserRepository(pool)
+	})
+}
+
+// runRepositoryConformance checks the behavior every IUserRepository must
+// share. newRepo returns an empty repository for each test.
+func runRepositoryConformance(t *testing.T, newRepo func(t *testing.T) users.IUserRepository) {
+	ctx := context.Background()
+
+	create := func(t *testing.T, repo users.IUserRepository, name, email string) *users.User {
+		t.Helper()
+		user, err := repo.CreateUser(ctx, users.CreateUserRequest{Name: name, Email: email, Password: "hashed"})
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		return user
+	}
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		repo := newRepo(t)
+		created := create(t, repo, "Alice Smith", "alice@example.com")
+
+		if created.ID.IsZero() || created.IsEmailVerified() || created.IsMFAEnabled() {
+			t.Errorf("Expected a new unverified user without MFA, got: %+v", created)
+		}
+		if len(created.Roles) != 1 || created.Roles[0] != auth.RoleUser {
+			t.Errorf("Expected roles [%s], got: %v", auth.RoleUser, created.Roles)
+		}
+
+		for name, get := range map[string]func() (*users.User, error){
+			"by id":    func() (*users.User, error) { return repo.GetUserById(ctx, created.ID.Hex()) },
+			"by email": func() (*users.User, error) { return repo.GetUserByEmail(ctx, "alice@example.com") },
+		} {
+			got, err := get()
+			if err != nil {
+				t.Fatalf("Expected no error getting %s, got: %v", name, err)
+			}
+			if got.ID != created.ID || got.Name != created.Name || got.Email != created.Email || got.Password != "hashed" {
+				t.Errorf("Expected %+v getting %s, got: %+v", created, name, got)
+			}
+			if got.IsEmailVerified() || got.IsMFAEnabled() {
+				t.Errorf("Expected an unverified user without MFA getting %s, got: %+v", name, got)
+			}
+			if got.CreatedAt.Sub(created.CreatedAt).Abs() > time.Millisecond {
+				t.Errorf("Expected created_at %v getting %s, got: %v", created.CreatedAt, name, got.CreatedAt)
+			}
+		}
+	})
+
+	t.Run("CreateDuplicateEmail", func(t *testing.T) {
+		repo := newRepo(t)
+		create(t, repo, "Alice Smith", "alice@example.com")
+
+		_, err := repo.CreateUser(ctx, users.CreateUserRequest{Name: "Other Alice", Email: "alice@example.com", Password: "hashed"})
+		if !errors.Is(err, users.ErrEmailAlreadyExists) {
+			t.Errorf("Expected ErrEmailAlreadyExists, got: %v", err)
+		}
+	})
+
+	t.Run("GetMissing", func(t *testing.T) {
+		repo := newRepo(t)
+
+		if _, err := repo.GetUserById(ctx, primitive.NewObjectID().Hex()); !errors.Is(err, users.ErrUserNotFound) {
+			t.Errorf("Expected ErrUserNotFound, got: %v", err)
+		}
+		if _, err := repo.GetUserById(ctx, "not-an-id"); !errors.Is(err, users.ErrInvalidID) {
+			t.Errorf("Expected ErrInvalidID, got: %v", err)
+		}
+		if _, err := repo.GetUserByEmail(ctx, "nobody@example.com"); !errors.Is(err, users.ErrUserNotFound) {
+			t.Errorf("Expected ErrUserNotFound, got: %v", err)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		repo := newRepo(t)
+		alice := create(t, repo, "Alice Smith", "alice@example.com")
+		create(t, repo, "Bob Jones", "bob@example.com")
+
+		updated, err := repo.UpdateUser(ctx, alice.ID.Hex(), users.UpdateUserRequest{Name: "Alice Jones", Email: "alice.jones@example.com"}, 0)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if updated.ID != alice.ID || updated.Name != "Alice Jones" || updated.Email != "alice.jones@example.com" {
+			t.Errorf("Expected the updated user, got: %+v", updated)
+		}
+
+		if _, err := repo.UpdateUser(ctx, alice.ID.Hex(), users.UpdateUserRequest{Name: "Alice Jones", Email: "bob@example.com"}, 0); !errors.Is(err, users.ErrEmailAlreadyExists) {
+			t.Errorf("Expected ErrEmailAlreadyExists, got: %v", err)
+		}
+		if _, err := repo.UpdateUser(ctx, primitive.NewObjectID().Hex(), users.UpdateUserRequest{Name: "Nobody", Email: "nobody@example.com"}, 0); !errors.Is(err, users.ErrUserNotFound) {
+			t.Errorf("Expected ErrUserNotFound, got: %v", err)
+		}
+		if _, err := repo.UpdateUser(ctx, "not-an-id", users.UpdateUserRequest{Name: "Nobody", Email: "nobody@example.com"}, 0); !errors.Is(err, users.ErrInvalidID) {
+			t.Errorf("Expected ErrInvalidID, got: %v", err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		repo := newRepo(t)
+		alice := create(t, repo, "Alice Smith", "alice@example.com")
+
+		if err := repo.DeleteUser(ctx, alice.ID.Hex(), 0); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if err := repo.DeleteUser(ctx, alice.ID.Hex(), 0); !errors.Is(err, users.ErrUserNotFound) {
+			t.Errorf("Expected ErrUserNotFound deleting twice, got: %v", err)
+		}
+		if _, err := repo.GetUserById(ctx, alice.ID.Hex()); !errors.Is(err, users.ErrUserNotFound) {
+			t.Errorf("Expected ErrUserNotFound after deleting, got: %v", err)
+		}
+		if err := repo.DeleteUser(ctx, "not-an-id", 0); !errors.Is(err, users.ErrInvalidID) {
+			t.Errorf("Expected ErrInvalidID, got: %v", err)
+		}
+	})
+
+	t.Run("Versions", func(t *testing.T) {
+		repo := newRepo(t)
+		alice := create(t, repo, "Alice Smith", "alice@example.com")
+		if alice.Version != 1 {
+			t.Fatalf("Expected a new user at version 1, got: %d", alice.Version)
+		}
+
+		updated, err := repo.UpdateUser(ctx, alice.ID.Hex(), users.UpdateUserRequest{Name: "Alice Jones", Email: "alice@example.com"}, 1)
+		if err != nil || updated.Version != 2 {
+			t.Fatalf("Expected version 2 after updating, got: %+v, %v", updated, err)
+		}
+		if _, err := repo.UpdateUser(ctx, alice.ID.Hex(), users.UpdateUserRequest{Name: "Alice Brown", Email: "alice@example.com"}, 1); !errors.Is(err, users.ErrVersionConflict) {
+			t.Errorf("Expected ErrVersionConflict updating a stale version, got: %v", err)
+		}
+		if _, err := repo.UpdateUser(ctx, primitive.NewObjectID().Hex(), users.UpdateUserRequest{Name: "Nobody", Email: "nobody@example.com"}, 1); !errors.Is(err, users.ErrUserNotFound) {
+			t.Errorf("Expected ErrUserNotFound, got: %v", err)
+		}
+
+		if err := repo.SetEmailVerified(ctx, alice.ID, "alice@example.com", true); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if err := repo.UpdatePassword(ctx, alice.ID, "rehashed"); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		got, err := repo.GetUserById(ctx, alice.ID.Hex())
+		if err != nil || got.Version != 3 || got.Name != "Alice Jones" {
+			t.Fatalf("Expected version 3 after verifying but not after a password change, got: %+v, %v", got, err)
+		}
+
+		updated, err = repo.UpdateUser(ctx, alice.ID.Hex(), users.UpdateUserRequest{Name: "Alice Brown", Email: "alice@example.com"}, 3)
+		if err != nil || updated.Version != 4 || !updated.IsEmailVerified() {
+			t.Fatalf("Expected version 4 and a verified email keeping the address, got: %+v, %v", updated, err)
+		}
+		updated, err = repo.UpdateUser(ctx, alice.ID.Hex(), users.UpdateUserRequest{Name: "Alice Brown", Email: "alice.brown@example.com"}, 4)
+		if err != nil || updated.Version != 5 || updated.IsEmailVerified() {
+			t.Fatalf("Expected version 5 and an unverified email after changing the address, got: %+v, %v", updated, err)
+		}
+
+		if err := repo.DeleteUser(ctx, alice.ID.Hex(), 4); !errors.Is(err, users.ErrVersionConflict) {
+			t.Errorf("Expected ErrVersionConflict deleting a stale version, got: %v", err)
+		}
+		if err := repo.DeleteUser(ctx, alice.ID.Hex(), 5); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if err := repo.DeleteUser(ctx, alice.ID.Hex(), 5); !errors.Is(err, users.ErrUserNotFound) {
+			t.Errorf("Expected ErrUserNotFound deleting twice, got: %v", err)
+		}
+	})
+
+	t.Run("Count", func(t *testing.T) {
+		repo := newRepo(t)
+		create(t, repo, "Alice Smith", "alice@example.com")
+		create(t, repo, "Bob Jones", "bob@example.com")
+
+		if count, err := repo.CountUsers(ctx); err != nil || count != 2 {
+			t.Errorf("Expected 2 users, got: %d, %v", count, err)
+		}
+	})
+
+	t.Run("ListPages", func(t *testing.T) {
+		repo := newRepo(t)
+		names := []string{"Alice Smith", "Bob Jones", "Carol White", "Dave Brown", "Eve Black"}
+		for i := len(names) - 1; i >= 0; i-- {
+			create(t, repo, names[i], string(rune('a'+i))+"@example.com")
+		}
+
+		var got []string
+		cursor := ""
+		for pages := 0; pages < len(names); pages++ {
+			opts, err := users.ListUsersQuery{Limit: 2, Sort: "name", Cursor: cursor}.Parse()
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			page, err := repo.GetUsers(ctx, opts)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if page.Total != int64(len(names)) {
+				t.Errorf("Expected a total of %d, got: %d", len(names), page.Total)
+			}
+			for _, user := range page.Users {
+				got = append(got, user.Name)
+			}
+			if cursor = page.NextCursor; cursor == "" {
+				break
+			}
+		}
+
+		if len(got) != len(names) {
+			t.Fatalf("Expected %v, got: %v", names, got)
+		}
+		for i := range names {
+			if got[i] != names[i] {
+				t.Errorf("Expected %v, got: %v", names, got)
+				break
+			}
+		}
+	})
+
+	t.Run("ListFiltersAndOffset", func(t *testing.T) {
+		repo := newRepo(t)
+		create(t, repo, "Alice Smith", "alice@example.com")
+		create(t, repo, "Bob Smith", "bob@example.com")
+		create(t, repo, "Carol (Smith)", "carol@example.com")
+		create(t, repo, "Dave Jones", "dave@example.com")
+
+		list := func(q users.ListUsersQuery) []users.User {
+			t.Helper()
+			opts, err := q.Parse()
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			page, err := repo.GetUsers(ctx, opts)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			return page.Users
+		}
+
+		if got := list(users.ListUsersQuery{Name: "smith"}); len(got) != 3 {
+			t.Errorf("Expected 3 users named smith, got: %d", len(got))
+		}
+		if got := list(users.ListUsersQuery{Name: "(smith)"}); len(got) != 1 || got[0].Name != "Carol (Smith)" {
+			t.Errorf("Expected the name filter to match literally, got: %+v", got)
+		}
+		if got := list(users.ListUsersQuery{Email: "BOB@example.com"}); len(got) != 1 || got[0].Name != "Bob Smith" {
+			t.Errorf("Expected the email filter to ignore case, got: %+v", got)
+		}
+		if got := list(users.ListUsersQuery{Sort: "name", Offset: 2, Limit: 1}); len(got) != 1 || got[0].Name != "Carol (Smith)" {
+			t.Errorf("Expected the third user by name, got: %+v", got)
+		}
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		repo := newRepo(t)
+		create(t, repo, "Alice Smith", "alice@example.com")
+		create(t, repo, "Alicia Keys", "keys@example.com")
+		create(t, repo, "Bob Jones", "bob@example.com")
+
+		search := func(q users.SearchUsersQuery) []string {
+			t.Helper()
+			opts, err := q.Parse()
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			hits, err := repo.SearchUsers(ctx, opts)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			var names []string
+			for i, hit := range hits {
+				if i > 0 && hit.Score > hits[i-1].Score {
+					t.Errorf("Expected hits by descending score, got: %+v", hits)
+				}
+				names = append(names, hit.Name)
+			}
+			return names
+		}
+
+		if got := search(users.SearchUsersQuery{Q: "alice"}); len(got) == 0 || got[0] != "Alice Smith" || slices.Contains(got, "Bob Jones") {
+			t.Errorf("Expected Alice Smith first and no Bob Jones, got: %v", got)
+		}
+		if got := search(users.SearchUsersQuery{Q: "ali", Mode: users.SearchModePrefix}); len(got) != 2 || got[0] != "Alice Smith" || got[1] != "Alicia Keys" {
+			t.Errorf("Expected [Alice Smith Alicia Keys], got: %v", got)
+		}
+	})
+
+	t.Run("PasswordAndEmailVerification", func(t *testing.T) {
+		repo := newRepo(t)
+		alice := create(t, repo, "Alice Smith", "alice@example.com")
+
+		if err := repo.UpdatePassword(ctx, alice.ID, "rehashed"); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if err := repo.UpdatePassword(ctx, primitive.NewObjectID(), "rehashed"); !errors.Is(err, users.ErrUserNotFound) {
+			t.Errorf("Expected ErrUserNotFound, got: %v", err)
+		}
+
+		if err := repo.SetEmailVerified(ctx, alice.ID, "old@example.com", true); !errors.Is(err, users.ErrUserNotFound) {
+			t.Errorf("Expected a stale email to match no user, got: %v", err)
+		}
+		if err := repo.SetEmailVerified(ctx, alice.ID, "alice@example.com", true); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		got, err := repo.GetUserById(ctx, alice.ID.Hex())
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if got.Password != "rehashed" || !got.IsEmailVerified() {
+			t.Errorf("Expected the new password and a verified email, got: %+v", got)
+		}
+	})
+
+	t.Run("MFA", func(t *testing.T) {
+		repo := newRepo(t)
+		alice := create(t, repo, "Alice Smith", "alice@example.com")
+
+		get := func() *users.User {
+			t.Helper()
+			user, err := repo.GetUserById(ctx, alice.ID.Hex())
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			return user
+		}
+
+		if err := repo.EnableMFA(ctx, alice.ID, "secret", []string{"h1"}); !errors.Is(err, users.ErrMFANotEnrolled) {
+			t.Errorf("Expected ErrMFANotEnrolled before enrolling, got: %v", err)
+		}
+		if err := repo.SetPendingMFASecret(ctx, alice.ID, "secret"); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if user := get(); user.MFA == nil || user.MFA.Enabled || user.MFA.PendingSecret != "secret" {
+			t.Errorf("Expected a pending enrollment, got: %+v", user.MFA)
+		}
+		if err := repo.EnableMFA(ctx, alice.ID, "other", []string{"h1"}); !errors.Is(err, users.ErrMFANotEnrolled) {
+			t.Errorf("Expected ErrMFANotEnrolled for another secret, got: %v", err)
+		}
+		if err := repo.EnableMFA(ctx, alice.ID, "secret", []string{"h1", "h2"}); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if user := get(); !user.IsMFAEnabled() || user.MFA.Secret != "secret" || user.MFA.PendingSecret != "" || len(user.MFA.RecoveryCodes) != 2 {
+			t.Errorf("Expected MFA to be enabled, got: %+v", user.MFA)
+		}
+
+		for _, step := range []struct {
+			step int64
+			want error
+		}{{10, nil}, {10, users.ErrInvalidMFACode}, {9, users.ErrInvalidMFACode}, {11, nil}} {
+			if err := repo.MarkTOTPStepUsed(ctx, alice.ID, step.step); !errors.Is(err, step.want) {
+				t.Errorf("Expected %v marking step %d, got: %v", step.want, step.step, err)
+			}
+		}
+
+		if err := repo.ConsumeRecoveryCode(ctx, alice.ID, "h1"); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if err := repo.ConsumeRecoveryCode(ctx, alice.ID, "h1"); !errors.Is(err, users.ErrInvalidMFACode) {
+			t.Errorf("Expected a recovery code to work once, got: %v", err)
+		}
+		if user := get(); len(user.MFA.RecoveryCodes) != 1 || user.MFA.RecoveryCodes[0] != "h2" {
+			t.Errorf("Expected [h2] to be left, got: %v", user.MFA.RecoveryCodes)
+		}
+
+		if err := repo.DisableMFA(ctx, alice.ID); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if user := get(); user.MFA != nil {
+			t.Errorf("Expected no MFA settings after disabling, got: %+v", user.MFA)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ritchie-gr8/7solution-be/internal/users"
+)
+
+func TestMemoryRepositorySnapshot(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "users.json")
+
+	repo, err := users.NewMemoryUserRepository(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	alice, err := repo.CreateUser(ctx, users.CreateUserRequest{Name: "Alice Smith", Email: "alice@example.com", Password: "hashed"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := repo.SetPendingMFASecret(ctx, alice.ID, "secret"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := repo.EnableMFA(ctx, alice.ID, "secret", []string{"h1"}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := repo.Snapshot(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected a snapshot only the owner can read, got: %v, %v", info, err)
+	}
+
+	restored, err := users.NewMemoryUserRepository(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	got, err := restored.GetUserByEmail(ctx, "alice@example.com")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if got.ID != alice.ID || got.Password != "hashed" || !got.IsMFAEnabled() || got.MFA.Secret != "secret" || len(got.MFA.RecoveryCodes) != 1 {
+		t.Errorf("Expected the snapshotted user, got: %+v %+v", got, got.MFA)
+	}
+	if _, err := restored.CreateUser(ctx, users.CreateUserRequest{Name: "Alice", Email: "alice@example.com", Password: "hashed"}); !errors.Is(err, users.ErrEmailAlreadyExists) {
+		t.Errorf("Expected restored emails to stay unique, got: %v", err)
+	}
+}
+
+func TestMemoryRepositoryMissingSnapshot(t *testing.T) {
+	repo, err := users.NewMemoryUserRepository(filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if count, _ := repo.CountUsers(context.Background()); count != 0 {
+		t.Errorf("Expected no users, got: %d", count)
+	}
+}
+
+func TestMemoryRepositoryConcurrentSignups(t *testing.T) {
+	repo, err := users.NewMemoryUserRepository("")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	var created atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.CreateUser(context.Background(), users.CreateUserRequest{Name: "Alice", Email: "alice@example.com", Password: "hashed"})
+			switch {
+			case err == nil:
+				created.Add(1)
+			case !errors.Is(err, users.ErrEmailAlreadyExists):
+				t.Errorf("Expected ErrEmailAlreadyExists, got: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if created.Load() != 1 {
+		t.Errorf("Expected exactly one signup to succeed, got: %d", created.Load())
+	}
+}
@@ -0,0 +1,232 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+	"github.com/ritchie-gr8/7solution-be/internal/users"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func (m *MockUserRepository) SetPendingMFASecret(ctx context.Context, id primitive.ObjectID, secret string) error {
+	if m.user == nil || m.user.ID != id {
+		return users.ErrUserNotFound
+	}
+	if m.user.MFA == nil {
+		m.user.MFA = &users.MFA{}
+	}
+	m.user.MFA.PendingSecret = secret
+	return nil
+}
+
+func (m *MockUserRepository) EnableMFA(ctx context.Context, id primitive.ObjectID, secret string, recoveryCodes []string) error {
+	if m.user == nil || m.user.ID != id || m.user.MFA == nil || m.user.MFA.PendingSecret != secret {
+		return users.ErrMFANotEnrolled
+	}
+	m.user.MFA = &users.MFA{Enabled: true, Secret: secret, RecoveryCodes: recoveryCodes}
+	return nil
+}
+
+func (m *MockUserRepository) DisableMFA(ctx context.Context, id primitive.ObjectID) error {
+	if m.user == nil || m.user.ID != id {
+		return users.ErrUserNotFound
+	}
+	m.user.MFA = nil
+	return nil
+}
+
+func (m *MockUserRepository) MarkTOTPStepUsed(ctx context.Context, id primitive.ObjectID, step int64) error {
+	if m.user == nil || m.user.ID != id || m.user.MFA.LastUsedStep >= step {
+		return users.ErrInvalidMFACode
+	}
+	m.user.MFA.LastUsedStep = step
+	return nil
+}
+
+func (m *MockUserRepository) ConsumeRecoveryCode(ctx context.Context, id primitive.ObjectID, hash string) error {
+	if m.user == nil || m.user.ID != id {
+		return users.ErrInvalidMFACode
+	}
+	i := slices.Index(m.user.MFA.RecoveryCodes, hash)
+	if i < 0 {
+		return users.ErrInvalidMFACode
+	}
+	m.user.MFA.RecoveryCodes = slices.Delete(m.user.MFA.RecoveryCodes, i, i+1)
+	return nil
+}
+
+type mfaFixture struct {
+	service users.IMFAService
+	users   users.IUserService
+	user    *users.User
+}
+
+func newMFAFixture(t *testing.T) *mfaFixture {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+
+	f := &mfaFixture{user: &users.User{ID: primitive.NewObjectID(), Email: "test@example.com", Password: string(hash)}}
+	repo := &MockUserRepository{user: f.user}
+	tokens := &MockTokenService{}
+	verification := newVerificationService(f.user, NewMockNotifier(), time.Hour, users.VerificationPolicyNone)
+	throttle := newLoginThrottle()
+
+	f.service = users.NewMFAService(repo, tokens, verification, throttle, []byte("secret"), "7solution", time.Minute)
+	f.users = users.NewUserService(repo, tokens, verification, throttle, f.service)
+	return f
+}
+
+// enable runs enrollment and confirmation and returns the TOTP secret and the
+// recovery codes.
+func (f *mfaFixture) enable(t *testing.T) (string, []string) {
+	t.Helper()
+
+	enrollment, err := f.service.Enroll(context.Background(), f.user.ID.Hex())
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	code, _ := totp.GenerateCode(enrollment.Secret, time.Now())
+	res, err := f.service.Confirm(context.Background(), f.user.ID.Hex(), users.ConfirmMFARequest{Code: code})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	return enrollment.Secret, res.RecoveryCodes
+}
+
+func (f *mfaFixture) challenge(t *testing.T) *users.MFAChallenge {
+	t.Helper()
+
+	_, err := f.users.Login(context.Background(), users.LoginUserRequest{Email: f.user.Email, Password: "password123"})
+
+	var challenge *users.MFAChallenge
+	if !errors.As(err, &challenge) || !errors.Is(err, users.ErrMFARequired) {
+		t.Fatalf("Expected an MFA challenge, got: %v", err)
+	}
+	return challenge
+}
+
+func TestMFAEnrollment(t *testing.T) {
+	t.Run("Confirm with wrong code", func(t *testing.T) {
+		f := newMFAFixture(t)
+
+		enrollment, err := f.service.Enroll(context.Background(), f.user.ID.Hex())
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if !strings.HasPrefix(enrollment.OtpauthURI, "otpauth://totp/7solution:test@example.com?") {
+			t.Errorf("Unexpected otpauth URI: %s", enrollment.OtpauthURI)
+		}
+		if !strings.HasPrefix(enrollment.QRCode, "data:image/png;base64,") {
+			t.Errorf("Expected a PNG data URI, got: %.40s", enrollment.QRCode)
+		}
+
+		_, err = f.service.Confirm(context.Background(), f.user.ID.Hex(), users.ConfirmMFARequest{Code: "000000"})
+		if !errors.Is(err, users.ErrInvalidMFACode) {
+			t.Errorf("Expected users.ErrInvalidMFACode, got: %v", err)
+		}
+		if f.user.IsMFAEnabled() {
+			t.Error("Expected MFA to stay disabled")
+		}
+	})
+
+	t.Run("Confirm without enrollment", func(t *testing.T) {
+		f := newMFAFixture(t)
+
+		_, err := f.service.Confirm(context.Background(), f.user.ID.Hex(), users.ConfirmMFARequest{Code: "123456"})
+		if !errors.Is(err, users.ErrMFANotEnrolled) {
+			t.Errorf("Expected users.ErrMFANotEnrolled, got: %v", err)
+		}
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		f := newMFAFixture(t)
+		_, codes := f.enable(t)
+
+		if !f.user.IsMFAEnabled() || len(codes) != 10 {
+			t.Fatalf("Expected MFA enabled with 10 recovery codes, got %d", len(codes))
+		}
+		if slices.Contains(f.user.MFA.RecoveryCodes, codes[0]) {
+			t.Error("Expected recovery codes to be stored hashed")
+		}
+
+		if _, err := f.service.Enroll(context.Background(), f.user.ID.Hex()); !errors.Is(err, users.ErrMFAAlreadyEnabled) {
+			t.Errorf("Expected users.ErrMFAAlreadyEnabled, got: %v", err)
+		}
+	})
+}
+
+func TestMFALogin(t *testing.T) {
+	t.Run("TOTP code", func(t *testing.T) {
+		f := newMFAFixture(t)
+		secret, _ := f.enable(t)
+
+		// The current step was spent on confirmation, the next one is in skew.
+		code, _ := totp.GenerateCode(secret, time.Now().Add(30*time.Second))
+		res, err := f.service.Verify(context.Background(), users.VerifyMFARequest{Token: f.challenge(t).Token, Code: code})
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if res.Token == "" || !res.MFAEnabled {
+			t.Error("Expected tokens for an MFA enabled user")
+		}
+
+		_, err = f.service.Verify(context.Background(), users.VerifyMFARequest{Token: f.challenge(t).Token, Code: code})
+		if !errors.Is(err, users.ErrInvalidMFACode) {
+			t.Errorf("Expected a replayed code to fail, got: %v", err)
+		}
+	})
+
+	t.Run("Recovery code", func(t *testing.T) {
+		f := newMFAFixture(t)
+		_, codes := f.enable(t)
+
+		req := users.VerifyMFARequest{Token: f.challenge(t).Token, Code: strings.ToUpper(codes[3])}
+		if _, err := f.service.Verify(context.Background(), req); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if _, err := f.service.Verify(context.Background(), req); !errors.Is(err, users.ErrInvalidMFACode) {
+			t.Errorf("Expected users.ErrInvalidMFACode on reuse, got: %v", err)
+		}
+	})
+
+	t.Run("Invalid token", func(t *testing.T) {
+		f := newMFAFixture(t)
+		secret, _ := f.enable(t)
+		challenge := f.challenge(t)
+
+		code, _ := totp.GenerateCode(secret, time.Now().Add(30*time.Second))
+		_, err := f.service.Verify(context.Background(), users.VerifyMFARequest{Token: challenge.Token + "x", Code: code})
+		if !errors.Is(err, users.ErrInvalidMFAToken) {
+			t.Errorf("Expected users.ErrInvalidMFAToken, got: %v", err)
+		}
+	})
+
+	t.Run("Disable", func(t *testing.T) {
+		f := newMFAFixture(t)
+		f.enable(t)
+
+		err := f.service.Disable(context.Background(), f.user.ID.Hex(), users.DisableMFARequest{Password: "wrong"})
+		if !errors.Is(err, users.ErrIncorrectPassword) {
+			t.Fatalf("Expected users.ErrIncorrectPassword, got: %v", err)
+		}
+
+		if err := f.service.Disable(context.Background(), f.user.ID.Hex(), users.DisableMFARequest{Password: "password123"}); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		res, err := f.users.Login(context.Background(), users.LoginUserRequest{Email: f.user.Email, Password: "password123"})
+		if err != nil || res.Token == "" {
+			t.Errorf("Expected a password-only login, got: %v", err)
+		}
+	})
+}
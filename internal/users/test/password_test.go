@@ -0,0 +1,271 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ritchie-gr8/7solution-be/internal/auth"
+	"github.com/ritchie-gr8/7solution-be/internal/users"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type MockUserRepository struct {
+	users.IUserRepository
+	user *users.User
+}
+
+func (m *MockUserRepository) GetUserById(ctx context.Context, id string) (*users.User, error) {
+	if m.user == nil || m.user.ID.Hex() != id {
+		return nil, users.ErrUserNotFound
+	}
+	return m.user, nil
+}
+
+func (m *MockUserRepository) GetUserByEmail(ctx context.Context, email string) (*users.User, error) {
+	if m.user == nil || m.user.Email != email {
+		return nil, users.ErrUserNotFound
+	}
+	return m.user, nil
+}
+
+func (m *MockUserRepository) UpdatePassword(ctx context.Context, id primitive.ObjectID, password string) error {
+	if m.user == nil || m.user.ID != id {
+		return users.ErrUserNotFound
+	}
+	m.user.Password = password
+	return nil
+}
+
+func (m *MockUserRepository) SetEmailVerified(ctx context.Context, id primitive.ObjectID, email string, verified bool) error {
+	if m.user == nil || m.user.ID != id || m.user.Email != email {
+		return users.ErrUserNotFound
+	}
+	m.user.EmailVerified = &verified
+	return nil
+}
+
+type MockPasswordResetRepository struct {
+	tokens []*users.PasswordResetToken
+}
+
+func (m *MockPasswordResetRepository) CreateResetToken(ctx context.Context, token *users.PasswordResetToken) error {
+	token.ID = primitive.NewObjectID()
+	m.tokens = append(m.tokens, token)
+	return nil
+}
+
+func (m *MockPasswordResetRepository) ConsumeResetToken(ctx context.Context, hash string) (*users.PasswordResetToken, error) {
+	for _, token := range m.tokens {
+		if token.TokenHash == hash && token.UsedAt == nil && token.ExpiresAt.After(time.Now()) {
+			now := time.Now()
+			token.UsedAt = &now
+			return token, nil
+		}
+	}
+	return nil, users.ErrInvalidResetToken
+}
+
+func (m *MockPasswordResetRepository) InvalidateUserResetTokens(ctx context.Context, userID primitive.ObjectID) error {
+	for _, token := range m.tokens {
+		if token.UserID == userID && token.UsedAt == nil {
+			now := time.Now()
+			token.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+type MockTokenService struct {
+	auth.ITokenService
+	revoked []primitive.ObjectID
+}
+
+func (m *MockTokenService) IssueTokens(ctx context.Context, userId primitive.ObjectID, roles []string) (*auth.TokenPair, error) {
+	return &auth.TokenPair{AccessToken: "access", RefreshToken: "refresh"}, nil
+}
+
+func (m *MockTokenService) RevokeUserTokens(ctx context.Context, userId primitive.ObjectID) error {
+	m.revoked = append(m.revoked, userId)
+	return nil
+}
+
+type MockNotifier struct {
+	notices       chan *users.Notice
+	verifications chan *users.Notice
+}
+
+func NewMockNotifier() *MockNotifier {
+	return &MockNotifier{
+		notices:       make(chan *users.Notice, 1),
+		verifications: make(chan *users.Notice, 1),
+	}
+}
+
+func (m *MockNotifier) SendPasswordReset(ctx context.Context, notice *users.Notice) error {
+	m.notices <- notice
+	return nil
+}
+
+func (m *MockNotifier) SendEmailVerification(ctx context.Context, notice *users.Notice) error {
+	m.verifications <- notice
+	return nil
+}
+
+type passwordFixture struct {
+	service  users.IPasswordService
+	user     *users.User
+	resets   *MockPasswordResetRepository
+	tokens   *MockTokenService
+	notifier *MockNotifier
+}
+
+func newPasswordFixture(t *testing.T, password string) *passwordFixture {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	resetUrl, _ := url.Parse("https://app.example.com/reset?lang=en")
+
+	f := &passwordFixture{
+		user:     &users.User{ID: primitive.NewObjectID(), Email: "test@example.com", Password: string(hash)},
+		resets:   &MockPasswordResetRepository{},
+		tokens:   &MockTokenService{},
+		notifier: NewMockNotifier(),
+	}
+	f.service = users.NewPasswordService(
+		&MockUserRepository{user: f.user},
+		f.resets,
+		f.tokens,
+		f.notifier,
+		resetUrl,
+		time.Hour)
+	return f
+}
+
+func (f *passwordFixture) requestReset(t *testing.T) *users.Notice {
+	t.Helper()
+
+	if err := f.service.ForgotPassword(context.Background(), users.ForgotPasswordRequest{Email: f.user.Email}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	select {
+	case notice := <-f.notifier.notices:
+		return notice
+	case <-time.After(time.Second):
+		t.Fatal("Expected a password reset notice")
+		return nil
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	t.Run("Wrong current password", func(t *testing.T) {
+		f := newPasswordFixture(t, "password123")
+
+		_, err := f.service.ChangePassword(context.Background(), f.user.ID.Hex(), users.ChangePasswordRequest{
+			CurrentPassword: "wrong",
+			NewPassword:     "newpassword",
+		})
+		if !errors.Is(err, users.ErrIncorrectPassword) {
+			t.Errorf("Expected users.ErrIncorrectPassword, got: %v", err)
+		}
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		f := newPasswordFixture(t, "password123")
+
+		res, err := f.service.ChangePassword(context.Background(), f.user.ID.Hex(), users.ChangePasswordRequest{
+			CurrentPassword: "password123",
+			NewPassword:     "newpassword",
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		if err := bcrypt.CompareHashAndPassword([]byte(f.user.Password), []byte("newpassword")); err != nil {
+			t.Error("Expected the new password to be stored hashed")
+		}
+		if len(f.tokens.revoked) != 1 {
+			t.Error("Expected existing sessions to be revoked")
+		}
+		if res.Token == "" || res.RefreshToken == "" {
+			t.Error("Expected a new token pair")
+		}
+	})
+}
+
+func TestForgotPassword(t *testing.T) {
+	t.Run("Unknown email", func(t *testing.T) {
+		f := newPasswordFixture(t, "password123")
+
+		if err := f.service.ForgotPassword(context.Background(), users.ForgotPasswordRequest{Email: "nobody@example.com"}); err != nil {
+			t.Errorf("Expected no error, got: %v", err)
+		}
+		if len(f.resets.tokens) != 0 {
+			t.Error("Expected no reset token to be created")
+		}
+	})
+
+	t.Run("Stores only the hash", func(t *testing.T) {
+		f := newPasswordFixture(t, "password123")
+		notice := f.requestReset(t)
+
+		stored := f.resets.tokens[0]
+		if stored.TokenHash == notice.Token || stored.TokenHash != auth.HashToken(notice.Token) {
+			t.Error("Expected the token to be stored as its hash")
+		}
+		if notice.Link != "https://app.example.com/reset?lang=en&token="+notice.Token {
+			t.Errorf("Unexpected reset link: %s", notice.Link)
+		}
+	})
+
+	t.Run("New request invalidates previous token", func(t *testing.T) {
+		f := newPasswordFixture(t, "password123")
+		first := f.requestReset(t)
+		f.requestReset(t)
+
+		err := f.service.ResetPassword(context.Background(), users.ResetPasswordRequest{Token: first.Token, NewPassword: "newpassword"})
+		if !errors.Is(err, users.ErrInvalidResetToken) {
+			t.Errorf("Expected users.ErrInvalidResetToken, got: %v", err)
+		}
+	})
+}
+
+func TestResetPassword(t *testing.T) {
+	t.Run("Single use", func(t *testing.T) {
+		f := newPasswordFixture(t, "password123")
+		notice := f.requestReset(t)
+
+		req := users.ResetPasswordRequest{Token: notice.Token, NewPassword: "newpassword"}
+		if err := f.service.ResetPassword(context.Background(), req); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(f.user.Password), []byte("newpassword")); err != nil {
+			t.Error("Expected the password to be reset")
+		}
+		if len(f.tokens.revoked) != 1 {
+			t.Error("Expected existing sessions to be revoked")
+		}
+
+		if err := f.service.ResetPassword(context.Background(), req); !errors.Is(err, users.ErrInvalidResetToken) {
+			t.Errorf("Expected users.ErrInvalidResetToken on reuse, got: %v", err)
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		f := newPasswordFixture(t, "password123")
+		notice := f.requestReset(t)
+		f.resets.tokens[0].ExpiresAt = time.Now().Add(-time.Minute)
+
+		err := f.service.ResetPassword(context.Background(), users.ResetPasswordRequest{Token: notice.Token, NewPassword: "newpassword"})
+		if !errors.Is(err, users.ErrInvalidResetToken) {
+			t.Errorf("Expected users.ErrInvalidResetToken, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/ritchie-gr8/7solution-be/internal/users"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fakePostgres answers every Exec with tag and err.
+type fakePostgres struct {
+	tag pgconn.CommandTag
+	err error
+}
+
+func (f *fakePostgres) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return f.tag, f.err
+}
+
+func (f *fakePostgres) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return nil, f.err
+}
+
+func (f *fakePostgres) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return nil
+}
+
+func TestPostgresCreateUserErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"unique violation", &pgconn.PgError{Code: "23505", ConstraintName: "users_email_key"}, users.ErrEmailAlreadyExists},
+		{"other error", errors.New("connection reset"), users.ErrInsertFailed},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := users.NewPostgresUserRepositoryWithDB(&fakePostgres{err: tc.err})
+
+			_, err := repo.CreateUser(context.Background(), users.CreateUserRequest{Name: "Alice", Email: "alice@example.com", Password: "hashed"})
+			if !errors.Is(err, tc.want) {
+				t.Errorf("Expected %v, got: %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestPostgresUpdateMatchingNoRow(t *testing.T) {
+	repo := users.NewPostgresUserRepositoryWithDB(&fakePostgres{tag: pgconn.NewCommandTag("UPDATE 0")})
+
+	if err := repo.ConsumeRecoveryCode(context.Background(), primitive.NewObjectID(), "hash"); !errors.Is(err, users.ErrInvalidMFACode) {
+		t.Errorf("Expected ErrInvalidMFACode, got: %v", err)
+	}
+	if err := repo.UpdatePassword(context.Background(), primitive.NewObjectID(), "hashed"); !errors.Is(err, users.ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got: %v", err)
+	}
+}
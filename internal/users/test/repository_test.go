@@ -6,9 +6,7 @@ import (
 	"testing"
 	"time"
 
-	"github.com/gofiber/fiber/v2"
 	"github.com/ritchie-gr8/7solution-be/internal/users"
-	"github.com/valyala/fasthttp"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -22,6 +20,8 @@ type MockCollection struct {
 	findOneAndUpdateFunc func(ctx context.Context, filter any, update any, opts ...*options.FindOneAndUpdateOptions) *mongo.SingleResult
 	deleteOneFunc        func(ctx context.Context, filter any, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error)
 	countDocumentsFunc   func(ctx context.Context, filter any, opts ...*options.CountOptions) (int64, error)
+	updateManyFunc       func(ctx context.Context, filter any, update any, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+	aggregateFunc        func(ctx context.Context, pipeline any, opts ...*options.AggregateOptions) (*mongo.Cursor, error)
 }
 
 func (m *MockCollection) FindOne(ctx context.Context, filter any, opts ...*options.FindOneOptions) *mongo.SingleResult {
@@ -66,9 +66,28 @@ func (m *MockCollection) CountDocuments(ctx context.Context, filter any, opts ..
 	return 0, nil
 }
 
-func createFiberCtx() *fiber.Ctx {
-	app := fiber.New()
-	return app.AcquireCtx(&fasthttp.RequestCtx{})
+func (m *MockCollection) UpdateMany(ctx context.Context, filter any, update any, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	if m.updateManyFunc != nil {
+		return m.updateManyFunc(ctx, filter, update, opts...)
+	}
+	return &mongo.UpdateResult{}, nil
+}
+
+func (m *MockCollection) Aggregate(ctx context.Context, pipeline any, opts ...*options.AggregateOptions) (*mongo.Cursor, error) {
+	if m.aggregateFunc != nil {
+		return m.aggregateFunc(ctx, pipeline, opts...)
+	}
+	return nil, nil
+}
+
+func defaultListOptions(t *testing.T) *users.ListUsersOptions {
+	t.Helper()
+
+	opts, err := users.ListUsersQuery{}.Parse()
+	if err != nil {
+		t.Fatalf("Failed to parse default query: %v", err)
+	}
+	return opts
 }
 
 func TestGetUsers(t *testing.T) {
@@ -100,33 +119,48 @@ func TestGetUsers(t *testing.T) {
 
 		mockColl := &MockCollection{
 			findFunc: func(ctx context.Context, filter any, opts ...*options.FindOptions) (*mongo.Cursor, error) {
-				filterMap, ok := filter.(bson.D)
+				filterMap, ok := filter.(bson.M)
 				if !ok {
-					t.Fatalf("Expected filter to be bson.D, got %T", filter)
+					t.Fatalf("Expected filter to be bson.M, got %T", filter)
 				}
 
 				if len(filterMap) != 0 {
 					t.Fatalf("Expected empty filter for GetUsers, got: %v", filterMap)
 				}
 
+				if limit := *opts[0].Limit; limit != users.DefaultPageSize+1 {
+					t.Errorf("Expected limit %d, got %d", users.DefaultPageSize+1, limit)
+				}
+
 				return mockCurrsor, nil
 			},
+			countDocumentsFunc: func(ctx context.Context, filter any, opts ...*options.CountOptions) (int64, error) {
+				return 2, nil
+			},
 		}
 
 		repo := users.NewUserRepositoryWithCollection(mockColl)
-		ctx := createFiberCtx()
+		ctx := context.Background()
 
-		result, err := repo.GetUsers(ctx)
+		result, err := repo.GetUsers(ctx, defaultListOptions(t))
 
 		if err != nil {
-			t.Errorf("Expected no error, got: %v", err)
+			t.Fatalf("Expected no error, got: %v", err)
 		}
 
-		if len(result) != len(usersList) {
-			t.Fatalf("Expected %d users, got %d", len(usersList), len(result))
+		if result.Total != 2 {
+			t.Errorf("Expected total 2, got %d", result.Total)
 		}
 
-		for i, user := range result {
+		if result.NextCursor != "" {
+			t.Errorf("Expected no next cursor on the last page, got %s", result.NextCursor)
+		}
+
+		if len(result.Users) != len(usersList) {
+			t.Fatalf("Expected %d users, got %d", len(usersList), len(result.Users))
+		}
+
+		for i, user := range result.Users {
 			if user.Email != usersList[i].Email {
 				t.Errorf("Expected user email %s, got %s", usersList[i].Email, user.Email)
 			}
@@ -136,6 +170,89 @@ func TestGetUsers(t *testing.T) {
 		}
 	})
 
+	t.Run("Returns next cursor when more users exist", func(t *testing.T) {
+		user1 := users.User{ID: primitive.NewObjectID(), Name: "User 1", CreatedAt: time.Now()}
+		user2 := users.User{ID: primitive.NewObjectID(), Name: "User 2", CreatedAt: time.Now()}
+
+		mockColl := &MockCollection{
+			findFunc: func(ctx context.Context, filter any, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+				return mongo.NewCursorFromDocuments(bson.A{user1, user2}, nil, nil)
+			},
+			countDocumentsFunc: func(ctx context.Context, filter any, opts ...*options.CountOptions) (int64, error) {
+				return 5, nil
+			},
+		}
+
+		opts, err := users.ListUsersQuery{Limit: 1, Sort: "-name"}.Parse()
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		repo := users.NewUserRepositoryWithCollection(mockColl)
+		result, err := repo.GetUsers(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		if len(result.Users) != 1 || result.NextCursor == "" {
+			t.Fatalf("Expected one user and a next cursor, got %d users and cursor %q", len(result.Users), result.NextCursor)
+		}
+
+		next, err := users.ListUsersQuery{Limit: 1, Sort: "-name", Cursor: result.NextCursor}.Parse()
+		if err != nil {
+			t.Fatalf("Expected next cursor to parse, got: %v", err)
+		}
+
+		mockColl.findFunc = func(ctx context.Context, filter any, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+			and, ok := filter.(bson.M)["$and"].(bson.A)
+			if !ok || len(and) != 2 {
+				t.Fatalf("Expected base filter combined with cursor filter, got: %v", filter)
+			}
+
+			or := and[1].(bson.M)["$or"].(bson.A)
+			first := or[0].(bson.M)["name"].(bson.M)
+			if first["$lt"] != "User 1" {
+				t.Errorf("Expected descending name to continue below User 1, got: %v", first)
+			}
+			return mongo.NewCursorFromDocuments(bson.A{user2}, nil, nil)
+		}
+
+		if _, err := repo.GetUsers(context.Background(), next); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		if _, err := (users.ListUsersQuery{Sort: "name", Cursor: result.NextCursor}).Parse(); !errors.Is(err, users.ErrInvalidCursor) {
+			t.Errorf("Expected users.ErrInvalidCursor for mismatched sort, got: %v", err)
+		}
+	})
+
+	t.Run("Filters by name and email", func(t *testing.T) {
+		mockColl := &MockCollection{
+			findFunc: func(ctx context.Context, filter any, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+				filterMap := filter.(bson.M)
+
+				name, ok := filterMap["name"].(primitive.Regex)
+				if !ok || name.Pattern != `jo\.n` || name.Options != "i" {
+					t.Errorf("Expected escaped case-insensitive name regex, got: %v", filterMap["name"])
+				}
+
+				email, ok := filterMap["email"].(primitive.Regex)
+				if !ok || email.Pattern != `^john@example\.com$` {
+					t.Errorf("Expected anchored email regex, got: %v", filterMap["email"])
+				}
+
+				return mongo.NewCursorFromDocuments(bson.A{}, nil, nil)
+			},
+		}
+
+		opts, _ := users.ListUsersQuery{Name: "jo.n", Email: "john@example.com"}.Parse()
+
+		repo := users.NewUserRepositoryWithCollection(mockColl)
+		if _, err := repo.GetUsers(context.Background(), opts); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+	})
+
 	t.Run("Error get all users", func(t *testing.T) {
 		mockColl := &MockCollection{
 			findFunc: func(ctx context.Context, filter any, opts ...*options.FindOptions) (*mongo.Cursor, error) {
@@ -144,9 +261,9 @@ func TestGetUsers(t *testing.T) {
 		}
 
 		repo := users.NewUserRepositoryWithCollection(mockColl)
-		ctx := createFiberCtx()
+		ctx := context.Background()
 
-		result, err := repo.GetUsers(ctx)
+		result, err := repo.GetUsers(ctx, defaultListOptions(t))
 
 		if err == nil {
 			t.Error("Expected error, got nil")
@@ -158,6 +275,71 @@ func TestGetUsers(t *testing.T) {
 	})
 }
 
+func TestParseListUsersQuery(t *testing.T) {
+	opts, err := users.ListUsersQuery{Limit: 1000, Sort: "created_at,-name"}.Parse()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if opts.Limit != users.MaxPageSize {
+		t.Errorf("Expected limit to be capped at %d, got %d", users.MaxPageSize, opts.Limit)
+	}
+
+	if len(opts.Sort) != 2 || opts.Sort[0].Field != "created_at" || !opts.Sort[1].Desc {
+		t.Errorf("Expected created_at asc then name desc, got %+v", opts.Sort)
+	}
+
+	if _, err := (users.ListUsersQuery{Sort: "password"}).Parse(); !errors.Is(err, users.ErrInvalidSort) {
+		t.Errorf("Expected users.ErrInvalidSort, got: %v", err)
+	}
+
+	if _, err := (users.ListUsersQuery{Offset: 10, Cursor: "abc"}).Parse(); !errors.Is(err, users.ErrInvalidOffset) {
+		t.Errorf("Expected users.ErrInvalidOffset, got: %v", err)
+	}
+
+	if _, err := (users.ListUsersQuery{Limit: -1}).Parse(); !errors.Is(err, users.ErrInvalidPageSize) {
+		t.Errorf("Expected users.ErrInvalidPageSize, got: %v", err)
+	}
+}
+
+func TestParseSearchUsersQuery(t *testing.T) {
+	opts, err := users.SearchUsersQuery{Q: "  alex  "}.Parse()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if opts.Query != "alex" || opts.Mode != users.SearchModeText || opts.Limit != users.DefaultPageSize {
+		t.Errorf("Expected trimmed text search with default limit, got %+v", opts)
+	}
+
+	if _, err := (users.SearchUsersQuery{}).Parse(); !errors.Is(err, users.ErrInvalidSearchQuery) {
+		t.Errorf("Expected users.ErrInvalidSearchQuery, got: %v", err)
+	}
+
+	if _, err := (users.SearchUsersQuery{Q: "alex", Mode: "fuzzy"}).Parse(); !errors.Is(err, users.ErrInvalidSearchMode) {
+		t.Errorf("Expected users.ErrInvalidSearchMode, got: %v", err)
+	}
+}
+
+func TestSearchResponseHighlights(t *testing.T) {
+	hit := users.UserSearchHit{
+		User:  users.User{ID: primitive.NewObjectID(), Name: "Alex <b>Johnson</b>", Email: "alex@example.com"},
+		Score: 2,
+	}
+
+	res := hit.ToSearchResponse([]string{"ALEX", "john"})
+
+	if res.Highlights["name"] != "<mark>Alex</mark> &lt;b&gt;<mark>John</mark>son&lt;/b&gt;" {
+		t.Errorf("Unexpected name highlight: %s", res.Highlights["name"])
+	}
+	if res.Highlights["email"] != "<mark>alex</mark>@example.com" {
+		t.Errorf("Unexpected email highlight: %s", res.Highlights["email"])
+	}
+	if res.Score != 2 {
+		t.Errorf("Expected score 2, got %v", res.Score)
+	}
+}
+
 func TestGetUserById(t *testing.T) {
 	t.Run("User found", func(t *testing.T) {
 		id := "507f1f77bcf86cd799439011"
@@ -188,7 +370,7 @@ func TestGetUserById(t *testing.T) {
 		}
 
 		repo := users.NewUserRepositoryWithCollection(mockColl)
-		ctx := createFiberCtx()
+		ctx := context.Background()
 		user, err := repo.GetUserById(ctx, id)
 
 		if err != nil {
@@ -234,7 +416,7 @@ func TestGetUserById(t *testing.T) {
 		}
 
 		repo := users.NewUserRepositoryWithCollection(mockColl)
-		ctx := createFiberCtx()
+		ctx := context.Background()
 		user, err := repo.GetUserById(ctx, id)
 
 		if err == nil {
@@ -261,7 +443,7 @@ func TestGetUserById(t *testing.T) {
 		}
 
 		repo := users.NewUserRepositoryWithCollection(mockColl)
-		ctx := createFiberCtx()
+		ctx := context.Background()
 		user, err := repo.GetUserById(ctx, id)
 
 		if err == nil {
@@ -307,7 +489,7 @@ func TestGetUserByEmail(t *testing.T) {
 		}
 
 		repo := users.NewUserRepositoryWithCollection(mockColl)
-		ctx := createFiberCtx()
+		ctx := context.Background()
 
 		user, err := repo.GetUserByEmail(ctx, email)
 
@@ -334,7 +516,7 @@ func TestGetUserByEmail(t *testing.T) {
 		}
 
 		repo := users.NewUserRepositoryWithCollection(mockColl)
-		ctx := createFiberCtx()
+		ctx := context.Background()
 
 		user, err := repo.GetUserByEmail(ctx, email)
 
@@ -380,7 +562,7 @@ func TestCreateUser(t *testing.T) {
 		}
 
 		repo := users.NewUserRepositoryWithCollection(mockColl)
-		ctx := createFiberCtx()
+		ctx := context.Background()
 
 		createReq := users.CreateUserRequest{
 			Name:     "John Doe",
@@ -424,7 +606,7 @@ func TestCreateUser(t *testing.T) {
 		}
 
 		repo := users.NewUserRepositoryWithCollection(mockColl)
-		ctx := createFiberCtx()
+		ctx := context.Background()
 
 		createReq := users.CreateUserRequest{
 			Name:     "John Doe",
@@ -446,6 +628,23 @@ func TestCreateUser(t *testing.T) {
 			t.Errorf("Expected nil user, got: %v", user)
 		}
 	})
+
+	t.Run("Duplicate key from the unique email index", func(t *testing.T) {
+		mockColl := &MockCollection{
+			findOneFunc: func(ctx context.Context, filter any, opts ...*options.FindOneOptions) *mongo.SingleResult {
+				return mongo.NewSingleResultFromDocument(bson.D{}, mongo.ErrNoDocuments, nil)
+			},
+			insertOneFunc: func(ctx context.Context, document any, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+				return nil, mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000}}}
+			},
+		}
+
+		repo := users.NewUserRepositoryWithCollection(mockColl)
+		_, err := repo.CreateUser(context.Background(), users.CreateUserRequest{Name: "John Doe", Email: "john@example.com", Password: "hashedpassword"})
+		if !errors.Is(err, users.ErrEmailAlreadyExists) {
+			t.Errorf("Expected users.ErrEmailAlreadyExists, got: %v", err)
+		}
+	})
 }
 
 func TestUpdateUser(t *testing.T) {
@@ -479,27 +678,32 @@ func TestUpdateUser(t *testing.T) {
 					t.Errorf("Expected filter ID %v, got %v", userID, filterID)
 				}
 
-				updateDoc, ok := update.(bson.M)
-				if !ok {
-					t.Fatalf("Expected update to be bson.M, got %T", update)
+				pipeline, ok := update.(mongo.Pipeline)
+				if !ok || len(pipeline) != 1 || pipeline[0][0].Key != "$set" {
+					t.Fatalf("Expected update to be a $set pipeline, got %v", update)
 				}
 
-				setDoc, ok := updateDoc["$set"].(bson.M)
+				setDoc, ok := pipeline[0][0].Value.(bson.M)
 				if !ok {
-					t.Fatalf("Expected update to contain $set operation, got %T", updateDoc["$set"])
+					t.Fatalf("Expected $set to be bson.M, got %T", pipeline[0][0].Value)
 				}
 
-				if setDoc["name"] != "Updated Name" {
-					t.Errorf("Expected name to be Updated Name, got %v", setDoc["name"])
+				if name := setDoc["name"].(bson.M)["$literal"]; name != "Updated Name" {
+					t.Errorf("Expected name to be Updated Name, got %v", name)
 				}
 
-				if setDoc["email"] != "updated@example.com" {
-					t.Errorf("Expected email to be updated@example.com, got %v", setDoc["email"])
+				if email := setDoc["email"].(bson.M)["$literal"]; email != "updated@example.com" {
+					t.Errorf("Expected email to be updated@example.com, got %v", email)
 				}
 
-				_, hasUpdatedAt := setDoc["updatedAt"]
+				_, hasUpdatedAt := setDoc["updated_at"]
 				if !hasUpdatedAt {
-					t.Error("Expected updatedAt to be set")
+					t.Error("Expected updated_at to be set")
+				}
+
+				_, hasEmailVerified := setDoc["email_verified"]
+				if !hasEmailVerified {
+					t.Error("Expected email_verified to be reset in the same update")
 				}
 
 				return mongo.NewSingleResultFromDocument(updatedUser, nil, nil)
@@ -507,14 +711,14 @@ func TestUpdateUser(t *testing.T) {
 		}
 
 		repo := users.NewUserRepositoryWithCollection(mockColl)
-		ctx := createFiberCtx()
+		ctx := context.Background()
 
 		updateReq := users.UpdateUserRequest{
 			Name:  "Updated Name",
 			Email: "updated@example.com",
 		}
 
-		updatedUserResult, err := repo.UpdateUser(ctx, userID.Hex(), updateReq)
+		updatedUserResult, err := repo.UpdateUser(ctx, userID.Hex(), updateReq, 0)
 
 		if err != nil {
 			t.Errorf("Expected no error, got: %v", err)
@@ -550,14 +754,14 @@ func TestUpdateUser(t *testing.T) {
 		}
 
 		repo := users.NewUserRepositoryWithCollection(mockColl)
-		ctx := createFiberCtx()
+		ctx := context.Background()
 
 		updateReq := users.UpdateUserRequest{
 			Name:  "Updated Name",
 			Email: "updated@example.com",
 		}
 
-		updatedUser, err := repo.UpdateUser(ctx, userID.Hex(), updateReq)
+		updatedUser, err := repo.UpdateUser(ctx, userID.Hex(), updateReq, 0)
 
 		if err == nil {
 			t.Error("Expected error, got nil")
@@ -583,14 +787,14 @@ func TestUpdateUser(t *testing.T) {
 		}
 
 		repo := users.NewUserRepositoryWithCollection(mockColl)
-		ctx := createFiberCtx()
+		ctx := context.Background()
 
 		updateReq := users.UpdateUserRequest{
 			Name:  "Updated Name",
 			Email: "updated@example.com",
 		}
 
-		updatedUser, err := repo.UpdateUser(ctx, invalidID, updateReq)
+		updatedUser, err := repo.UpdateUser(ctx, invalidID, updateReq, 0)
 
 		if err == nil {
 			t.Error("Expected error, got nil")
@@ -626,14 +830,14 @@ func TestUpdateUser(t *testing.T) {
 		}
 
 		repo := users.NewUserRepositoryWithCollection(mockColl)
-		ctx := createFiberCtx()
+		ctx := context.Background()
 
 		updateReq := users.UpdateUserRequest{
 			Name:  "Updated Name",
 			Email: "updated@example.com",
 		}
 
-		updatedUserResult, err := repo.UpdateUser(ctx, userID.Hex(), updateReq)
+		updatedUserResult, err := repo.UpdateUser(ctx, userID.Hex(), updateReq, 0)
 
 		if err == nil {
 			t.Error("Expected error, got nil")
@@ -647,6 +851,34 @@ func TestUpdateUser(t *testing.T) {
 			t.Errorf("Expected nil user, got: %v", updatedUserResult)
 		}
 	})
+
+	t.Run("Stale version", func(t *testing.T) {
+		userID := primitive.NewObjectID()
+
+		mockColl := &MockCollection{
+			findOneFunc: func(ctx context.Context, filter any, opts ...*options.FindOneOptions) *mongo.SingleResult {
+				return mongo.NewSingleResultFromDocument(bson.D{}, mongo.ErrNoDocuments, nil)
+			},
+			findOneAndUpdateFunc: func(ctx context.Context, filter any, update any, opts ...*options.FindOneAndUpdateOptions) *mongo.SingleResult {
+				if version := filter.(bson.M)["version"]; version != int64(3) {
+					t.Errorf("Expected the filter to match version 3, got: %v", version)
+				}
+				if _, ok := update.(mongo.Pipeline)[0][0].Value.(bson.M)["version"]; !ok {
+					t.Error("Expected the version to be incremented")
+				}
+				return mongo.NewSingleResultFromDocument(bson.D{}, mongo.ErrNoDocuments, nil)
+			},
+			countDocumentsFunc: func(ctx context.Context, filter any, opts ...*options.CountOptions) (int64, error) {
+				return 1, nil
+			},
+		}
+
+		repo := users.NewUserRepositoryWithCollection(mockColl)
+		_, err := repo.UpdateUser(context.Background(), userID.Hex(), users.UpdateUserRequest{Name: "Updated Name", Email: "updated@example.com"}, 3)
+		if !errors.Is(err, users.ErrVersionConflict) {
+			t.Errorf("Expected users.ErrVersionConflict, got: %v", err)
+		}
+	})
 }
 
 func TestDeleteUser(t *testing.T) {
@@ -674,9 +906,9 @@ func TestDeleteUser(t *testing.T) {
 		}
 
 		repo := users.NewUserRepositoryWithCollection(mockColl)
-		ctx := createFiberCtx()
+		ctx := context.Background()
 
-		err := repo.DeleteUser(ctx, userID.Hex())
+		err := repo.DeleteUser(ctx, userID.Hex(), 0)
 
 		if err != nil {
 			t.Errorf("Expected no error, got: %v", err)
@@ -693,9 +925,9 @@ func TestDeleteUser(t *testing.T) {
 		}
 
 		repo := users.NewUserRepositoryWithCollection(mockColl)
-		ctx := createFiberCtx()
+		ctx := context.Background()
 
-		err := repo.DeleteUser(ctx, userID.Hex())
+		err := repo.DeleteUser(ctx, userID.Hex(), 0)
 
 		if err == nil {
 			t.Error("Expected error, got nil")
@@ -717,9 +949,9 @@ func TestDeleteUser(t *testing.T) {
 		}
 
 		repo := users.NewUserRepositoryWithCollection(mockColl)
-		ctx := createFiberCtx()
+		ctx := context.Background()
 
-		err := repo.DeleteUser(ctx, invalidID)
+		err := repo.DeleteUser(ctx, invalidID, 0)
 
 		if err == nil {
 			t.Error("Expected error, got nil")
@@ -741,9 +973,9 @@ func TestDeleteUser(t *testing.T) {
 		}
 
 		repo := users.NewUserRepositoryWithCollection(mockColl)
-		ctx := createFiberCtx()
+		ctx := context.Background()
 
-		err := repo.DeleteUser(ctx, userID.Hex())
+		err := repo.DeleteUser(ctx, userID.Hex(), 0)
 
 		if err == nil {
 			t.Error("Expected error, got nil")
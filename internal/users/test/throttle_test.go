@@ -0,0 +1,135 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ritchie-gr8/7solution-be/internal/users"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// newAttemptsCollection keeps login_attempts documents in a map and applies
+// the $inc and $set updates the throttle issues.
+func newAttemptsCollection() *MockCollection {
+	docs := make(map[string]bson.M)
+
+	return &MockCollection{
+		findOneFunc: func(ctx context.Context, filter any, opts ...*options.FindOneOptions) *mongo.SingleResult {
+			doc, ok := docs[filter.(bson.M)["_id"].(string)]
+			if !ok {
+				return mongo.NewSingleResultFromDocument(bson.D{}, mongo.ErrNoDocuments, nil)
+			}
+			return mongo.NewSingleResultFromDocument(doc, nil, nil)
+		},
+		findOneAndUpdateFunc: func(ctx context.Context, filter any, update any, opts ...*options.FindOneAndUpdateOptions) *mongo.SingleResult {
+			key := filter.(bson.M)["_id"].(string)
+			doc, ok := docs[key]
+			if !ok {
+				doc = bson.M{"_id": key, "failures": 0}
+				docs[key] = doc
+			}
+
+			updateDoc := update.(bson.M)
+			if inc, ok := updateDoc["$inc"].(bson.M); ok {
+				for field, delta := range inc {
+					doc[field] = doc[field].(int) + delta.(int)
+				}
+			}
+			if set, ok := updateDoc["$set"].(bson.M); ok {
+				for field, value := range set {
+					doc[field] = value
+				}
+			}
+			return mongo.NewSingleResultFromDocument(doc, nil, nil)
+		},
+		deleteOneFunc: func(ctx context.Context, filter any, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+			key := filter.(bson.M)["_id"].(string)
+			if _, ok := docs[key]; !ok {
+				return &mongo.DeleteResult{}, nil
+			}
+			delete(docs, key)
+			return &mongo.DeleteResult{DeletedCount: 1}, nil
+		},
+	}
+}
+
+func newLoginThrottle() users.ILoginThrottle {
+	return users.NewLoginThrottleWithCollection(newAttemptsCollection(), users.LoginThrottleOptions{
+		MaxAccountFailures: 3,
+		MaxIPFailures:      5,
+		LockoutBase:        time.Minute,
+		LockoutMax:         5 * time.Minute,
+		FailureWindow:      time.Hour,
+	})
+}
+
+func expectLockout(t *testing.T, err, want error, retryAfter time.Duration) {
+	t.Helper()
+
+	var lockout *users.LockoutError
+	if !errors.As(err, &lockout) || !errors.Is(err, want) {
+		t.Fatalf("Expected %v, got: %v", want, err)
+	}
+	if lockout.RetryAfter > retryAfter || lockout.RetryAfter < retryAfter-time.Second {
+		t.Errorf("Expected to retry after about %s, got %s", retryAfter, lockout.RetryAfter)
+	}
+}
+
+func TestLoginThrottle(t *testing.T) {
+	t.Run("Exponential account lockout", func(t *testing.T) {
+		throttle := newLoginThrottle()
+		ctx := context.Background()
+
+		for i := 0; i < 2; i++ {
+			throttle.RecordFailure(ctx, "test@example.com", "10.0.0.1")
+		}
+		if err := throttle.Check(ctx, "test@example.com", "10.0.0.1"); err != nil {
+			t.Fatalf("Expected no lockout below the limit, got: %v", err)
+		}
+
+		throttle.RecordFailure(ctx, "TEST@example.com", "10.0.0.2")
+		expectLockout(t, throttle.Check(ctx, "test@example.com", "10.0.0.3"), users.ErrAccountLocked, time.Minute)
+
+		throttle.RecordFailure(ctx, "test@example.com", "10.0.0.2")
+		expectLockout(t, throttle.Check(ctx, "test@example.com", "10.0.0.3"), users.ErrAccountLocked, 2*time.Minute)
+
+		for i := 0; i < 10; i++ {
+			throttle.RecordFailure(ctx, "test@example.com", "10.0.0.2")
+		}
+		expectLockout(t, throttle.Check(ctx, "test@example.com", "10.0.0.3"), users.ErrAccountLocked, 5*time.Minute)
+	})
+
+	t.Run("IP lockout across accounts", func(t *testing.T) {
+		throttle := newLoginThrottle()
+		ctx := context.Background()
+
+		for _, email := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"} {
+			throttle.RecordFailure(ctx, email, "10.0.0.1")
+		}
+
+		expectLockout(t, throttle.Check(ctx, "f@example.com", "10.0.0.1"), users.ErrTooManyAttempts, time.Minute)
+		if err := throttle.Check(ctx, "f@example.com", "10.0.0.2"); err != nil {
+			t.Errorf("Expected other IP addresses to be unaffected, got: %v", err)
+		}
+	})
+
+	t.Run("Unlock", func(t *testing.T) {
+		throttle := newLoginThrottle()
+		ctx := context.Background()
+
+		for i := 0; i < 3; i++ {
+			throttle.RecordFailure(ctx, "test@example.com", "10.0.0.1")
+		}
+		if err := throttle.Unlock(ctx, "Test@Example.com"); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		if err := throttle.Check(ctx, "test@example.com", "10.0.0.2"); err != nil {
+			t.Errorf("Expected the account to be unlocked, got: %v", err)
+		}
+	})
+}
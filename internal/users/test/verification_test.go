@@ -0,0 +1,119 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ritchie-gr8/7solution-be/internal/auth"
+	"github.com/ritchie-gr8/7solution-be/internal/users"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newVerificationService(user *users.User, notifier *MockNotifier, expiresAt time.Duration, policy users.VerificationPolicy) users.IVerificationService {
+	verifyUrl, _ := url.Parse("https://api.example.com/v1/auth/verify-email")
+	return users.NewVerificationService(
+		&MockUserRepository{user: user},
+		notifier,
+		[]byte("secret"),
+		verifyUrl,
+		expiresAt,
+		policy)
+}
+
+func sendVerification(t *testing.T, service users.IVerificationService, user *users.User, notifier *MockNotifier) *users.Notice {
+	t.Helper()
+
+	if err := service.SendVerification(context.Background(), user); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	select {
+	case notice := <-notifier.verifications:
+		return notice
+	case <-time.After(time.Second):
+		t.Fatal("Expected a verification notice")
+		return nil
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	newUser := func() *users.User {
+		return &users.User{ID: primitive.NewObjectID(), Email: "test@example.com", EmailVerified: new(bool)}
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		user, notifier := newUser(), NewMockNotifier()
+		service := newVerificationService(user, notifier, time.Hour, users.VerificationPolicyRestrict)
+		notice := sendVerification(t, service, user, notifier)
+
+		if !strings.HasPrefix(notice.Link, "https://api.example.com/v1/auth/verify-email?token=") {
+			t.Errorf("Unexpected verification link: %s", notice.Link)
+		}
+
+		if err := service.VerifyEmail(context.Background(), notice.Token); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if !user.IsEmailVerified() {
+			t.Error("Expected the email to be verified")
+		}
+	})
+
+	t.Run("Tampered token", func(t *testing.T) {
+		user, notifier := newUser(), NewMockNotifier()
+		service := newVerificationService(user, notifier, time.Hour, users.VerificationPolicyRestrict)
+		notice := sendVerification(t, service, user, notifier)
+
+		forged := strings.Replace(notice.Token, ".", "x.", 1)
+		if err := service.VerifyEmail(context.Background(), forged); !errors.Is(err, users.ErrInvalidVerifyToken) {
+			t.Errorf("Expected users.ErrInvalidVerifyToken, got: %v", err)
+		}
+	})
+
+	t.Run("Expired token", func(t *testing.T) {
+		user, notifier := newUser(), NewMockNotifier()
+		service := newVerificationService(user, notifier, -time.Minute, users.VerificationPolicyRestrict)
+		notice := sendVerification(t, service, user, notifier)
+
+		if err := service.VerifyEmail(context.Background(), notice.Token); !errors.Is(err, users.ErrInvalidVerifyToken) {
+			t.Errorf("Expected users.ErrInvalidVerifyToken, got: %v", err)
+		}
+	})
+
+	t.Run("Email changed since", func(t *testing.T) {
+		user, notifier := newUser(), NewMockNotifier()
+		service := newVerificationService(user, notifier, time.Hour, users.VerificationPolicyRestrict)
+		notice := sendVerification(t, service, user, notifier)
+		user.Email = "new@example.com"
+
+		if err := service.VerifyEmail(context.Background(), notice.Token); !errors.Is(err, users.ErrInvalidVerifyToken) {
+			t.Errorf("Expected users.ErrInvalidVerifyToken, got: %v", err)
+		}
+	})
+}
+
+func TestVerificationPolicy(t *testing.T) {
+	unverified := &users.User{Roles: []string{auth.RoleAdmin}, EmailVerified: new(bool)}
+	legacy := &users.User{Roles: []string{auth.RoleAdmin}}
+
+	restrict := newVerificationService(nil, NewMockNotifier(), time.Hour, users.VerificationPolicyRestrict)
+	if roles, _ := restrict.TokenRoles(unverified); !slices.Equal(roles, []string{auth.RoleUnverified}) {
+		t.Errorf("Expected restricted roles, got %v", roles)
+	}
+	if roles, _ := restrict.TokenRoles(legacy); !slices.Equal(roles, []string{auth.RoleAdmin}) {
+		t.Errorf("Expected accounts without email_verified to keep their roles, got %v", roles)
+	}
+
+	block := newVerificationService(nil, NewMockNotifier(), time.Hour, users.VerificationPolicyBlock)
+	if _, err := block.TokenRoles(unverified); !errors.Is(err, users.ErrEmailNotVerified) {
+		t.Errorf("Expected users.ErrEmailNotVerified, got: %v", err)
+	}
+
+	if _, err := users.ParseVerificationPolicy("strict"); err == nil {
+		t.Error("Expected an unknown policy to be rejected")
+	}
+}
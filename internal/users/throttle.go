@@ -0,0 +1,283 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	databases "github.com/ritchie-gr8/7solution-be/internal/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	accountAttemptsPrefix = "account:"
+	ipAttemptsPrefix      = "ip:"
+
+	// maxBackoffShift keeps the exponential backoff from overflowing.
+	maxBackoffShift = 30
+)
+
+// LockoutError is returned while logins are throttled. It wraps
+// ErrAccountLocked or ErrTooManyAttempts.
+type LockoutError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("%v, retry after %s", e.Err, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LockoutError) Unwrap() error {
+	return e.Err
+}
+
+type LoginThrottleOptions struct {
+	// MaxAccountFailures and MaxIPFailures are the failed logins allowed
+	// before every further failure locks the account or IP address.
+	MaxAccountFailures int
+	MaxIPFailures      int
+	// LockoutBase is the first lockout, it doubles with every further
+	// failure up to LockoutMax.
+	LockoutBase time.Duration
+	LockoutMax  time.Duration
+	// FailureWindow is how long failures are remembered after the last one.
+	FailureWindow time.Duration
+}
+
+// LoginAttempts counts the recent failed logins of one account or IP address.
+type LoginAttempts struct {
+	Key         string     `bson:"_id"`
+	Failures    int        `bson:"failures"`
+	LockedUntil *time.Time `bson:"locked_until,omitempty"`
+	ExpiresAt   time.Time  `bson:"expires_at"`
+}
+
+type ILoginThrottle interface {
+	// Check returns a *LockoutError while the account or the IP address is
+	// locked.
+	Check(ctx context.Context, email, ip string) error
+	RecordFailure(ctx context.Context, email, ip string) error
+	// RecordSuccess clears the failures of the account. The IP address keeps
+	// its failures, so logging into one account does not reset the budget for
+	// guessing others.
+	RecordSuccess(ctx context.Context, email string) error
+	Unlock(ctx context.Context, email string) error
+}
+
+type loginThrottle struct {
+	collection MongoCollection
+	opts       LoginThrottleOptions
+}
+
+// NewLoginThrottle keeps counters in Mongo so the limits hold across
+// instances.
+func NewLoginThrottle(db *mongo.Client, opts LoginThrottleOptions) ILoginThrottle {
+	database := db.Database("userdb")
+	collection := database.Collection("login_attempts")
+	databases.EnsureIndexes(collection, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return &loginThrottle{collection: newInstrumentedCollection(collection), opts: opts}
+}
+
+func NewLoginThrottleWithCollection(collection MongoCollection, opts LoginThrottleOptions) ILoginThrottle {
+	return &loginThrottle{collection: collection, opts: opts}
+}
+
+func (t *loginThrottle) Check(ctx context.Context, email, ip string) error {
+	now := time.Now()
+	type lockCheck struct {
+		key string
+		err error
+	}
+	checks := []lockCheck{{accountKey(email), ErrAccountLocked}}
+	// Callers outside of HTTP have no address, and must not all share one.
+	if ip != "" {
+		checks = append(checks, lockCheck{ipAttemptsPrefix + ip, ErrTooManyAttempts})
+	}
+
+	for _, check := range checks {
+		var attempts LoginAttempts
+		err := t.collection.FindOne(ctx, bson.M{"_id": check.key}).Decode(&attempts)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				continue
+			}
+			return err
+		}
+
+		if attempts.LockedUntil != nil && attempts.LockedUntil.After(now) {
+			return &LockoutError{Err: check.err, RetryAfter: attempts.LockedUntil.Sub(now)}
+		}
+	}
+	return nil
+}
+
+func (t *loginThrottle) RecordFailure(ctx context.Context, email, ip string) error {
+	if err := t.recordFailure(ctx, accountKey(email), t.opts.MaxAccountFailures); err != nil {
+		return err
+	}
+	if ip == "" {
+		return nil
+	}
+	return t.recordFailure(ctx, ipAttemptsPrefix+ip, t.opts.MaxIPFailures)
+}
+
+// recordFailure counts the failure atomically, so concurrent attempts from
+// several instances cannot slip past the limit.
+func (t *loginThrottle) recordFailure(ctx context.Context, key string, maxFailures int) error {
+	now := time.Now()
+
+	var attempts LoginAttempts
+	err := t.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": key},
+		bson.M{
+			"$inc": bson.M{"failures": 1},
+			"$set": bson.M{"expires_at": now.Add(t.opts.FailureWindow)},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&attempts)
+	if err != nil {
+		return err
+	}
+
+	if attempts.Failures < maxFailures {
+		return nil
+	}
+
+	lockedUntil := now.Add(t.opts.lockout(attempts.Failures - maxFailures))
+	return t.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": key},
+		bson.M{"$set": bson.M{
+			"locked_until": lockedUntil,
+			"expires_at":   lockedUntil.Add(t.opts.FailureWindow),
+		}},
+	).Err()
+}
+
+func (t *loginThrottle) RecordSuccess(ctx context.Context, email string) error {
+	return t.Unlock(ctx, email)
+}
+
+func (t *loginThrottle) Unlock(ctx context.Context, email string) error {
+	_, err := t.collection.DeleteOne(ctx, bson.M{"_id": accountKey(email)})
+	return err
+}
+
+// lockout doubles LockoutBase for every failure past the limit.
+func (o LoginThrottleOptions) lockout(excess int) time.Duration {
+	lockout := o.LockoutBase << min(excess, maxBackoffShift)
+	if lockout <= 0 || lockout > o.LockoutMax {
+		return o.LockoutMax
+	}
+	return lockout
+}
+
+type memoryLoginThrottle struct {
+	mu       sync.Mutex
+	attempts map[string]*LoginAttempts
+	opts     LoginThrottleOptions
+	now      func() time.Time
+}
+
+// NewMemoryLoginThrottle keeps counters in process, so every instance has
+// limits of its own.
+func NewMemoryLoginThrottle(opts LoginThrottleOptions) ILoginThrottle {
+	return NewMemoryLoginThrottleWithClock(opts, time.Now)
+}
+
+// NewMemoryLoginThrottleWithClock lets tests control time.
+func NewMemoryLoginThrottleWithClock(opts LoginThrottleOptions, now func() time.Time) ILoginThrottle {
+	return &memoryLoginThrottle{attempts: make(map[string]*LoginAttempts), opts: opts, now: now}
+}
+
+func (t *memoryLoginThrottle) Check(ctx context.Context, email, ip string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	if err := t.check(accountKey(email), ErrAccountLocked, now); err != nil {
+		return err
+	}
+	if ip == "" {
+		return nil
+	}
+	return t.check(ipAttemptsPrefix+ip, ErrTooManyAttempts, now)
+}
+
+func (t *memoryLoginThrottle) check(key string, lockedErr error, now time.Time) error {
+	attempts, ok := t.attempts[key]
+	if !ok || !attempts.ExpiresAt.After(now) {
+		return nil
+	}
+	if attempts.LockedUntil != nil && attempts.LockedUntil.After(now) {
+		return &LockoutError{Err: lockedErr, RetryAfter: attempts.LockedUntil.Sub(now)}
+	}
+	return nil
+}
+
+func (t *memoryLoginThrottle) RecordFailure(ctx context.Context, email, ip string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	t.sweep(now)
+	t.recordFailure(accountKey(email), t.opts.MaxAccountFailures, now)
+	if ip != "" {
+		t.recordFailure(ipAttemptsPrefix+ip, t.opts.MaxIPFailures, now)
+	}
+	return nil
+}
+
+func (t *memoryLoginThrottle) recordFailure(key string, maxFailures int, now time.Time) {
+	attempts, ok := t.attempts[key]
+	if !ok || !attempts.ExpiresAt.After(now) {
+		attempts = &LoginAttempts{Key: key}
+		t.attempts[key] = attempts
+	}
+
+	attempts.Failures++
+	attempts.ExpiresAt = now.Add(t.opts.FailureWindow)
+	if attempts.Failures < maxFailures {
+		return
+	}
+
+	lockedUntil := now.Add(t.opts.lockout(attempts.Failures - maxFailures))
+	attempts.LockedUntil = &lockedUntil
+	attempts.ExpiresAt = lockedUntil.Add(t.opts.FailureWindow)
+}
+
+// sweep drops expired counters, which the TTL index does in MongoDB.
+func (t *memoryLoginThrottle) sweep(now time.Time) {
+	for key, attempts := range t.attempts {
+		if !attempts.ExpiresAt.After(now) {
+			delete(t.attempts, key)
+		}
+	}
+}
+
+func (t *memoryLoginThrottle) RecordSuccess(ctx context.Context, email string) error {
+	return t.Unlock(ctx, email)
+}
+
+func (t *memoryLoginThrottle) Unlock(ctx context.Context, email string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.attempts, accountKey(email))
+	return nil
+}
+
+// accountKey ignores case, so varying the case of the email does not buy an
+// attacker extra attempts.
+func accountKey(email string) string {
+	return accountAttemptsPrefix + strings.ToLower(strings.TrimSpace(email))
+}
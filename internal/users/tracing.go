@@ -0,0 +1,94 @@
+package users
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ritchie-gr8/7solution-be/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedUserService wraps every IUserService method in a span. The method
+// gets the span's context, so the repository's Mongo spans nest under it.
+type tracedUserService struct {
+	next IUserService
+}
+
+func newTracedUserService(next IUserService) IUserService {
+	return &tracedUserService{next: next}
+}
+
+func (t *tracedUserService) start(ctx context.Context, method string) (context.Context, func(err error)) {
+	ctx, span := tracing.Start(ctx, "userService."+method, trace.SpanKindInternal)
+
+	return ctx, func(err error) {
+		// An MFA challenge is the expected answer to a valid password.
+		if errors.Is(err, ErrMFARequired) {
+			err = nil
+		}
+		tracing.End(span, err)
+	}
+}
+
+func (t *tracedUserService) GetUsers(ctx context.Context, query ListUsersQuery) (*UserPageResponse, error) {
+	ctx, done := t.start(ctx, "GetUsers")
+	res, err := t.next.GetUsers(ctx, query)
+	done(err)
+	return res, err
+}
+
+func (t *tracedUserService) GetUserById(ctx context.Context, id string) (*UserResponse, error) {
+	ctx, done := t.start(ctx, "GetUserById")
+	res, err := t.next.GetUserById(ctx, id)
+	done(err)
+	return res, err
+}
+
+func (t *tracedUserService) SearchUsers(ctx context.Context, query SearchUsersQuery) ([]*UserSearchResponse, error) {
+	ctx, done := t.start(ctx, "SearchUsers")
+	res, err := t.next.SearchUsers(ctx, query)
+	done(err)
+	return res, err
+}
+
+func (t *tracedUserService) Login(ctx context.Context, user LoginUserRequest) (*UserResponseWithToken, error) {
+	ctx, done := t.start(ctx, "Login")
+	res, err := t.next.Login(ctx, user)
+	done(err)
+	return res, err
+}
+
+func (t *tracedUserService) CreateUser(ctx context.Context, user CreateUserRequest) (*UserResponseWithToken, error) {
+	ctx, done := t.start(ctx, "CreateUser")
+	res, err := t.next.CreateUser(ctx, user)
+	done(err)
+	return res, err
+}
+
+func (t *tracedUserService) UpdateUser(ctx context.Context, id string, user UpdateUserRequest, version int64) (*UserResponseWithMessage, error) {
+	ctx, done := t.start(ctx, "UpdateUser")
+	res, err := t.next.UpdateUser(ctx, id, user, version)
+	done(err)
+	return res, err
+}
+
+func (t *tracedUserService) DeleteUser(ctx context.Context, id string, version int64) error {
+	ctx, done := t.start(ctx, "DeleteUser")
+	err := t.next.DeleteUser(ctx, id, version)
+	done(err)
+	return err
+}
+
+func (t *tracedUserService) UnlockUser(ctx context.Context, id string) error {
+	ctx, done := t.start(ctx, "UnlockUser")
+	err := t.next.UnlockUser(ctx, id)
+	done(err)
+	return err
+}
+
+func (t *tracedUserService) CountUsers(ctx context.Context) (int64, error) {
+	ctx, span := tracing.Start(ctx, "userService.CountUsers", trace.SpanKindInternal)
+	count, err := t.next.CountUsers(ctx)
+	tracing.End(span, err)
+	return count, err
+}
@@ -0,0 +1,159 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/ritchie-gr8/7solution-be/internal/auth"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// VerificationPolicy decides what an account with an unverified email address
+// may do.
+type VerificationPolicy string
+
+const (
+	// VerificationPolicyNone treats unverified accounts like verified ones.
+	VerificationPolicyNone VerificationPolicy = "none"
+	// VerificationPolicyRestrict issues tokens with auth.RoleUnverified only.
+	VerificationPolicyRestrict VerificationPolicy = "restrict"
+	// VerificationPolicyBlock refuses to issue tokens at all.
+	VerificationPolicyBlock VerificationPolicy = "block"
+)
+
+func ParseVerificationPolicy(policy string) (VerificationPolicy, error) {
+	switch p := VerificationPolicy(policy); p {
+	case "":
+		return VerificationPolicyRestrict, nil
+	case VerificationPolicyNone, VerificationPolicyRestrict, VerificationPolicyBlock:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown email verification policy %q", policy)
+	}
+}
+
+type IVerificationService interface {
+	// TokenRoles returns the roles to put into the tokens of user, or
+	// ErrEmailNotVerified when the policy does not allow tokens yet.
+	TokenRoles(user *User) ([]string, error)
+	SendVerification(ctx context.Context, user *User) error
+	VerifyEmail(ctx context.Context, token string) error
+	ResendVerification(ctx context.Context, req ResendVerificationRequest) error
+}
+
+type verificationService struct {
+	repo      IUserRepository
+	notifier  INotifier
+	signer    *tokenSigner
+	verifyUrl *url.URL
+	expiresAt time.Duration
+	policy    VerificationPolicy
+}
+
+func NewVerificationService(repo IUserRepository, notifier INotifier, secret []byte, verifyUrl *url.URL, expiresAt time.Duration, policy VerificationPolicy) IVerificationService {
+	return &verificationService{
+		repo:      repo,
+		notifier:  notifier,
+		signer:    newTokenSigner(secret, "email_verification"),
+		verifyUrl: verifyUrl,
+		expiresAt: expiresAt,
+		policy:    policy,
+	}
+}
+
+// verificationClaims is the payload of a verification token. Binding the
+// email means a link stops working once the address changes again.
+type verificationClaims struct {
+	UserID string `json:"sub"`
+	Email  string `json:"email"`
+	Exp    int64  `json:"exp"`
+}
+
+func (s *verificationService) TokenRoles(user *User) ([]string, error) {
+	if user.IsEmailVerified() {
+		return user.Roles, nil
+	}
+
+	switch s.policy {
+	case VerificationPolicyBlock:
+		return nil, ErrEmailNotVerified
+	case VerificationPolicyRestrict:
+		return []string{auth.RoleUnverified}, nil
+	default:
+		return user.Roles, nil
+	}
+}
+
+func (s *verificationService) SendVerification(ctx context.Context, user *User) error {
+	expiresAt := time.Now().Add(s.expiresAt)
+	token, err := s.signer.sign(verificationClaims{
+		UserID: user.ID.Hex(),
+		Email:  user.Email,
+		Exp:    expiresAt.Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	notifyAsync("email verification", &Notice{
+		User:      user,
+		Token:     token,
+		Link:      linkWithToken(s.verifyUrl, token),
+		ExpiresAt: expiresAt,
+		Locale:    clientFrom(ctx).Locale,
+	}, s.notifier.SendEmailVerification)
+	return nil
+}
+
+// VerifyEmail is idempotent, verifying an already verified address succeeds.
+func (s *verificationService) VerifyEmail(ctx context.Context, token string) error {
+	claims, err := s.verify(token)
+	if err != nil {
+		return err
+	}
+
+	userId, err := primitive.ObjectIDFromHex(claims.UserID)
+	if err != nil {
+		return ErrInvalidVerifyToken
+	}
+
+	if err := s.repo.SetEmailVerified(ctx, userId, claims.Email, true); err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return ErrInvalidVerifyToken
+		}
+		return err
+	}
+	return nil
+}
+
+// ResendVerification does not reveal whether the email belongs to an account
+// or is already verified.
+func (s *verificationService) ResendVerification(ctx context.Context, req ResendVerificationRequest) error {
+	user, err := s.repo.GetUserByEmail(ctx, req.Email)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if user.IsEmailVerified() {
+		return nil
+	}
+	return s.SendVerification(ctx, user)
+}
+
+func (s *verificationService) verify(token string) (*verificationClaims, error) {
+	var claims verificationClaims
+	if err := s.signer.verify(token, &claims); err != nil {
+		return nil, ErrInvalidVerifyToken
+	}
+
+	if time.Now().Unix() >= claims.Exp {
+		return nil, ErrInvalidVerifyToken
+	}
+	return &claims, nil
+}
@@ -0,0 +1,96 @@
+package response
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// MIMEProblemJSON is the media type of RFC 7807 problem details.
+const MIMEProblemJSON = "application/problem+json"
+
+// problemTypeBase prefixes the code of a catalog problem to form its type.
+// Problems derived from a bare status use about:blank, as RFC 7807 suggests.
+const problemTypeBase = "urn:7solution-be:problem:"
+
+const (
+	CodeValidationFailed = "validation_failed"
+	CodeInternalError    = "internal_error"
+)
+
+// Problem is how an error is shown to clients. Title defaults to the status
+// text and Detail to the title.
+type Problem struct {
+	Err    error
+	Status int
+	Code   string
+	Title  string
+	Detail string
+}
+
+func (p Problem) title() string {
+	if p.Title != "" {
+		return p.Title
+	}
+	return utils.StatusMessage(p.Status)
+}
+
+func (p Problem) detail() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	return p.title()
+}
+
+// Catalog maps the errors handlers return to problems. The first entry whose
+// Err matches with errors.Is wins.
+type Catalog []Problem
+
+func (c Catalog) Lookup(err error) (Problem, bool) {
+	for _, p := range c {
+		if errors.Is(err, p.Err) {
+			return p, true
+		}
+	}
+	return Problem{}, false
+}
+
+// ErrorHandler is the Fiber error handler that renders errors returned by
+// handlers as problem details. An error missing from every catalog becomes a
+// 500 that does not leak its message; the access log records it instead.
+func ErrorHandler(catalogs ...Catalog) fiber.ErrorHandler {
+	return func(c *fiber.Ctx, err error) error {
+		for _, catalog := range catalogs {
+			if p, ok := catalog.Lookup(err); ok {
+				return NewResponse(c).Problem(p).Response()
+			}
+		}
+
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			return NewResponse(c).Error(fiberErr.Code, fiberErr.Message).Response()
+		}
+		return NewResponse(c).Problem(Problem{
+			Status: fiber.StatusInternalServerError,
+			Code:   CodeInternalError,
+			Detail: "An unexpected error occurred.",
+		}).Response()
+	}
+}
+
+func problemType(p Problem) string {
+	if p.Code == statusCode(p.Status) {
+		return "about:blank"
+	}
+	return problemTypeBase + p.Code
+}
+
+// statusCode turns a status into a code, e.g. 404 into "not_found".
+func statusCode(status int) string {
+	text := strings.ReplaceAll(strings.ToLower(utils.StatusMessage(status)), "'", "")
+	return strings.Join(strings.FieldsFunc(text, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	}), "_")
+}
@@ -2,11 +2,15 @@ package response
 
 import (
 	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type IResponse interface {
 	Success(code int, data any) IResponse
-	Error(code int, traceId, msg string) IResponse
+	Paginated(code int, data any, meta PageMeta) IResponse
+	Error(code int, msg string) IResponse
+	Problem(p Problem) IResponse
+	ValidationError(code int, msg string, fields []FieldError) IResponse
 	Response() error
 }
 
@@ -18,11 +22,40 @@ type Response struct {
 	IsError    bool
 }
 
+// ErrorResponse is an RFC 7807 problem details object. Code is stable and
+// meant for clients to switch on, unlike Title and Detail.
 type ErrorResponse struct {
-	TraceId string `json:"trace_id"`
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail"`
+	Instance string       `json:"instance"`
+	Code     string       `json:"code"`
+	TraceId  string       `json:"trace_id"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError is one failed validation rule. Field is the JSON path of the
+// field, e.g. "email" or "address.street".
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
 	Message string `json:"message"`
 }
 
+type PageMeta struct {
+	Total      int64  `json:"total"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type Page struct {
+	Data any      `json:"data"`
+	Meta PageMeta `json:"meta"`
+}
+
 func NewResponse(ctx *fiber.Ctx) *Response {
 	return &Response{
 		Context: ctx,
@@ -35,21 +68,56 @@ func (r *Response) Success(code int, data any) IResponse {
 	return r
 }
 
-func (r *Response) Error(code int, traceId, msg string) IResponse {
+func (r *Response) Paginated(code int, data any, meta PageMeta) IResponse {
 	r.StatusCode = code
+	r.Data = &Page{
+		Data: data,
+		Meta: meta,
+	}
+	return r
+}
+
+// Error answers with a problem that has no entry in a catalog. Its code is
+// derived from the status, e.g. "bad_request", and msg is the detail.
+func (r *Response) Error(code int, msg string) IResponse {
+	return r.Problem(Problem{Status: code, Code: statusCode(code), Detail: msg})
+}
+
+// ValidationError is Error with the list of fields that failed validation.
+func (r *Response) ValidationError(code int, msg string, fields []FieldError) IResponse {
+	r.Problem(Problem{Status: code, Code: CodeValidationFailed, Title: "Validation Failed", Detail: msg})
+	r.ErrorRes.Errors = fields
+	return r
+}
+
+// Problem fills trace_id from the span in the request's user context, so a
+// client can hand it over to find the request in the tracing backend.
+func (r *Response) Problem(p Problem) IResponse {
+	r.StatusCode = p.Status
 	r.ErrorRes = &ErrorResponse{
-		TraceId: traceId,
-		Message: msg,
+		Type:     problemType(p),
+		Title:    p.title(),
+		Status:   p.Status,
+		Detail:   p.detail(),
+		Instance: r.Context.Path(),
+		Code:     p.Code,
+		TraceId:  traceId(r.Context),
 	}
 	r.IsError = true
 	return r
 }
 
 func (r *Response) Response() error {
-	return r.Context.Status(r.StatusCode).JSON(func() any {
-		if r.IsError {
-			return &r.ErrorRes
-		}
-		return &r.Data
-	}())
+	if r.IsError {
+		return r.Context.Status(r.StatusCode).JSON(&r.ErrorRes, MIMEProblemJSON)
+	}
+	return r.Context.Status(r.StatusCode).JSON(&r.Data)
+}
+
+func traceId(c *fiber.Ctx) string {
+	spanCtx := trace.SpanContextFromContext(c.UserContext())
+	if !spanCtx.HasTraceID() {
+		return ""
+	}
+	return spanCtx.TraceID().String()
 }
@@ -0,0 +1,87 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ritchie-gr8/7solution-be/pkg/response"
+)
+
+var errWidgetNotFound = errors.New("widget: not found")
+
+func newApp() *fiber.App {
+	app := fiber.New(fiber.Config{
+		ErrorHandler: response.ErrorHandler(response.Catalog{
+			{Err: errWidgetNotFound, Status: fiber.StatusNotFound, Code: "widget_not_found", Title: "Widget Not Found", Detail: "The widget was not found."},
+		}),
+	})
+	app.Get("/widgets/:id", func(c *fiber.Ctx) error {
+		return fmt.Errorf("find widget %s: %w", c.Params("id"), errWidgetNotFound)
+	})
+	app.Get("/broken", func(c *fiber.Ctx) error {
+		return errors.New("connection refused by 10.0.0.5")
+	})
+	return app
+}
+
+func problem(t *testing.T, app *fiber.App, path string) response.ErrorResponse {
+	t.Helper()
+
+	res, err := app.Test(httptest.NewRequest(fiber.MethodGet, path, nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if ct := res.Header.Get(fiber.HeaderContentType); !strings.HasPrefix(ct, response.MIMEProblemJSON) {
+		t.Errorf("Expected content type %s, got: %s", response.MIMEProblemJSON, ct)
+	}
+
+	var body response.ErrorResponse
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if body.Status != res.StatusCode {
+		t.Errorf("Expected status %d in the body, got: %d", res.StatusCode, body.Status)
+	}
+	return body
+}
+
+func TestCatalogError(t *testing.T) {
+	body := problem(t, newApp(), "/widgets/42")
+
+	want := response.ErrorResponse{
+		Type:     "urn:7solution-be:problem:widget_not_found",
+		Title:    "Widget Not Found",
+		Status:   fiber.StatusNotFound,
+		Detail:   "The widget was not found.",
+		Instance: "/widgets/42",
+		Code:     "widget_not_found",
+	}
+	if body.Type != want.Type || body.Title != want.Title || body.Status != want.Status ||
+		body.Detail != want.Detail || body.Instance != want.Instance || body.Code != want.Code {
+		t.Errorf("Expected %+v, got: %+v", want, body)
+	}
+}
+
+func TestUnknownErrorIsNotLeaked(t *testing.T) {
+	body := problem(t, newApp(), "/broken")
+
+	if body.Status != fiber.StatusInternalServerError || body.Code != response.CodeInternalError {
+		t.Errorf("Expected a 500 internal_error, got: %+v", body)
+	}
+	if strings.Contains(body.Detail, "10.0.0.5") {
+		t.Errorf("Expected the detail not to contain the error message, got: %s", body.Detail)
+	}
+}
+
+func TestStatusOnlyError(t *testing.T) {
+	body := problem(t, newApp(), "/no-such-route")
+
+	if body.Type != "about:blank" || body.Title != "Not Found" || body.Code != "not_found" {
+		t.Errorf("Expected an about:blank not_found problem, got: %+v", body)
+	}
+}